@@ -0,0 +1,125 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/idempotency"
+)
+
+func TestIdempotencyPostgresStore(t *testing.T) {
+	pool := openTestDB(t)
+	store := idempotency.NewPostgresStore(pool)
+	ctx := context.Background()
+
+	begin := func(t *testing.T, key, fingerprint string) (*idempotency.Response, error) {
+		t.Helper()
+		return store.Begin(ctx, key, fingerprint, time.Hour)
+	}
+
+	exec := func(t *testing.T, query string, args ...any) {
+		t.Helper()
+		if _, err := pool.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("should claim a new key and report it in progress", func(t *testing.T) {
+		if response, err := begin(t, "claim", "f1"); response != nil || err != nil {
+			t.Fatalf("expected the key to be claimed, got %v, %v", response, err)
+		}
+
+		if _, err := begin(t, "claim", "f1"); !errors.Is(err, idempotency.ErrInProgress) {
+			t.Errorf("expected %v, got %v", idempotency.ErrInProgress, err)
+		}
+	})
+
+	t.Run("should replay the completed response", func(t *testing.T) {
+		want := idempotency.Response{
+			Status: http.StatusCreated,
+			Header: http.Header{"Location": {"/v1/books/1"}},
+			Body:   []byte(`{"id":"1"}`),
+		}
+
+		if _, err := begin(t, "replay", "f1"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Complete(ctx, "replay", want); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := begin(t, "replay", "f1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got == nil || !reflect.DeepEqual(*got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("should reject a key reused with a different fingerprint", func(t *testing.T) {
+		if _, err := begin(t, "mismatch", "f1"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := begin(t, "mismatch", "f2"); !errors.Is(err, idempotency.ErrKeyReused) {
+			t.Errorf("expected %v, got %v", idempotency.ErrKeyReused, err)
+		}
+	})
+
+	t.Run("should take over an abandoned claim once its lock expires", func(t *testing.T) {
+		if _, err := begin(t, "abandoned", "f1"); err != nil {
+			t.Fatal(err)
+		}
+
+		exec(t, "UPDATE idempotency_keys SET locked_until = now() - interval '1 second' WHERE key = $1", "abandoned")
+
+		if response, err := begin(t, "abandoned", "f2"); response != nil || err != nil {
+			t.Errorf("expected the abandoned key to be claimed again, got %v, %v", response, err)
+		}
+	})
+
+	t.Run("should not take over a completed key before it expires", func(t *testing.T) {
+		if _, err := begin(t, "completed", "f1"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Complete(ctx, "completed", idempotency.Response{Status: http.StatusCreated}); err != nil {
+			t.Fatal(err)
+		}
+
+		exec(t, "UPDATE idempotency_keys SET locked_until = now() - interval '1 second' WHERE key = $1", "completed")
+
+		if response, err := begin(t, "completed", "f1"); response == nil || err != nil {
+			t.Errorf("expected the stored response, got %v, %v", response, err)
+		}
+
+		exec(t, "UPDATE idempotency_keys SET expires_at = now() - interval '1 second' WHERE key = $1", "completed")
+
+		if response, err := begin(t, "completed", "f2"); response != nil || err != nil {
+			t.Errorf("expected the expired key to be claimed again, got %v, %v", response, err)
+		}
+	})
+
+	t.Run("should release an unfinished claim", func(t *testing.T) {
+		if _, err := begin(t, "released", "f1"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Release(ctx, "released"); err != nil {
+			t.Fatal(err)
+		}
+
+		if response, err := begin(t, "released", "f2"); response != nil || err != nil {
+			t.Errorf("expected the released key to be claimed again, got %v, %v", response, err)
+		}
+	})
+}
@@ -0,0 +1,350 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/cmd/migrate/migrations"
+	"github.com/gfteix/book_loan_system/internal/emails"
+	"github.com/gfteix/book_loan_system/internal/reminders"
+	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/events"
+	"github.com/gfteix/book_loan_system/pkg/metrics"
+	"github.com/gfteix/book_loan_system/pkg/mq"
+	"github.com/gfteix/book_loan_system/pkg/smtptest"
+	"github.com/gfteix/book_loan_system/types"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// openTestDB migrates the database at INTEGRATION_DB_DSN and empties every
+// table. The test is skipped when the variable is unset.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("INTEGRATION_DB_DSN")
+	if dsn == "" {
+		t.Skip("INTEGRATION_DB_DSN is not set")
+	}
+
+	pool, err := db.NewPostgreSQLStorage(db.DBConfig{DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Close() })
+
+	driver, err := postgres.WithInstance(pool, &postgres.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatal(err)
+	}
+
+	if err := db.CheckSchema(context.Background(), pool, migrations.Latest()); err != nil {
+		t.Fatal(err)
+	}
+
+	var tables []string
+	rows, err := pool.Query("SELECT quote_ident(tablename) FROM pg_tables WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	if _, err := pool.Exec("TRUNCATE " + strings.Join(tables, ", ") + " CASCADE"); err != nil {
+		t.Fatal(err)
+	}
+
+	return pool
+}
+
+// apiClient sends JSON requests to the API and decodes the responses.
+type apiClient struct {
+	t   *testing.T
+	url string
+}
+
+func (c apiClient) do(method, path string, payload any, status int, v any) {
+	c.t.Helper()
+
+	var body bytes.Buffer
+	if payload != nil {
+		if err := json.NewEncoder(&body).Encode(payload); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, c.url+"/v1"+path, &body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != status {
+		var problem types.Problem
+		json.NewDecoder(res.Body).Decode(&problem)
+		c.t.Fatalf("%s %s: expected %d, got %d: %+v", method, path, status, res.StatusCode, problem)
+	}
+
+	if v != nil {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	pool := openTestDB(t)
+
+	handler, _, err := NewAPIServer("", pool).handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	api := apiClient{t: t, url: server.URL}
+
+	var book types.Book
+	api.do(http.MethodPost, "/books", types.CreateBookPayload{
+		Title:   "The Left Hand of Darkness",
+		ISBN:    "9780441478125",
+		Authors: []string{"Ursula K. Le Guin"},
+	}, http.StatusCreated, &book)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	// Each borrower has one loan; only the first two are due.
+	borrowers := []struct {
+		email    string
+		expiring time.Time
+	}{
+		{"expired@example.com", today},
+		{"expiring@example.com", today.AddDate(0, 0, 2)},
+		{"later@example.com", today.AddDate(0, 0, 5)},
+	}
+
+	var users []types.User
+	var loans []types.Loan
+
+	for _, b := range borrowers {
+		var user types.User
+		api.do(http.MethodPost, "/users", types.CreateUserPayload{Name: b.email, Email: b.email}, http.StatusCreated, &user)
+
+		var bookCopy types.BookCopy
+		api.do(http.MethodPost, "/books/"+book.Id+"/items", types.CreateBookCopyPayload{BookId: book.Id}, http.StatusCreated, &bookCopy)
+
+		var loan types.Loan
+		api.do(http.MethodPost, "/loans", types.CreateLoanPayload{
+			UserId:       user.Id,
+			BookCopyId:   bookCopy.Id,
+			Status:       "active",
+			LoanDate:     today.AddDate(0, 0, -14),
+			ExpiringDate: b.expiring,
+		}, http.StatusCreated, &loan)
+
+		var userLoans []types.Loan
+		api.do(http.MethodGet, "/loans?userId="+user.Id, nil, http.StatusOK, &userLoans)
+
+		if len(userLoans) != 1 || userLoans[0].Id != loan.Id {
+			t.Fatalf("expected the user's loan %s, got %+v", loan.Id, userLoans)
+		}
+
+		users = append(users, user)
+		loans = append(loans, loan)
+	}
+
+	t.Run("should count the lent copies as unavailable", func(t *testing.T) {
+		var got types.Book
+		api.do(http.MethodGet, "/books/"+book.Id, nil, http.StatusOK, &got)
+
+		availability := got.Availability
+		if availability == nil || availability.TotalCopies != 3 || availability.OnLoanCopies != 3 || availability.AvailableCopies != 0 {
+			t.Fatalf("expected 3 copies, all on loan, got %+v", availability)
+		}
+
+		if availability.ExpectedReturnDate == nil || !availability.ExpectedReturnDate.Equal(today) {
+			t.Errorf("expected the earliest return on %v, got %v", today, availability.ExpectedReturnDate)
+		}
+
+		var available []types.Book
+		api.do(http.MethodGet, "/books?available=true", nil, http.StatusOK, &available)
+
+		if len(available) != 0 {
+			t.Errorf("expected no available books, got %+v", available)
+		}
+	})
+
+	t.Run("should find the loans that are due", func(t *testing.T) {
+		due, err := reminders.DueLoans(context.Background(), pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(due) != 2 {
+			t.Errorf("expected 2 due loans, got %+v", due)
+		}
+	})
+
+	brokers := map[string]func() mq.Broker{
+		"memory": func() mq.Broker { return mq.NewMemoryBroker(events.Topology) },
+		"postgres": func() mq.Broker {
+			return mq.NewPostgresBroker(pool, mq.PostgresConfig{PollInterval: 50 * time.Millisecond}, events.Topology)
+		},
+	}
+
+	for name, newBroker := range brokers {
+		t.Run(fmt.Sprintf("should email due borrowers through the %s broker", name), func(t *testing.T) {
+			broker := newBroker()
+			defer broker.Close()
+
+			smtpServer := smtptest.NewServer()
+			defer smtpServer.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			due, err := reminders.DueLoans(ctx, pool)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := reminders.Publish(ctx, broker, due, metrics.NewReminders(metrics.NewRegistry())); err != nil {
+				t.Fatal(err)
+			}
+
+			worker := emails.NewWorker(pool, &emails.SMTP{Addr: smtpServer.Addr, From: "library@example.com"}, metrics.NewEmailWorker(metrics.NewRegistry()))
+
+			consumeCtx, stop := context.WithCancel(ctx)
+			consumed := make(chan error, 1)
+			go func() {
+				consumed <- broker.Consume(consumeCtx, events.EmailsQueue, mq.ConsumeOptions{Workers: 2}, worker.HandleMessage)
+			}()
+
+			messages, err := smtpServer.Wait(ctx, 2)
+			stop()
+
+			if err := <-consumed; err != nil {
+				t.Fatal(err)
+			}
+
+			if err != nil {
+				t.Fatalf("expected 2 emails, got %d: %v", len(messages), err)
+			}
+
+			got := map[string]string{}
+			for _, m := range messages {
+				got[m.To[0]] = m.Subject()
+
+				if !strings.Contains(m.Body(), book.Title) {
+					t.Errorf("expected the email to %s to name %q, got %q", m.To[0], book.Title, m.Body())
+				}
+			}
+
+			want := map[string]string{
+				"expired@example.com":  "Loan Expired",
+				"expiring@example.com": "Loan Expiring",
+			}
+
+			for to, subject := range want {
+				if got[to] != subject {
+					t.Errorf("expected %q to %s, got %q", subject, to, got[to])
+				}
+			}
+
+			if len(got) != len(want) {
+				t.Errorf("expected emails to %v only, got %v", slices.Sorted(maps.Keys(want)), got)
+			}
+		})
+	}
+
+	// Runs last, since returning a loan changes which loans are due.
+	t.Run("should pass a returned copy to the waiting hold", func(t *testing.T) {
+		holder := users[2]
+
+		var hold types.Hold
+		api.do(http.MethodPost, "/holds", types.PlaceHoldPayload{UserId: holder.Id, BookId: book.Id}, http.StatusCreated, &hold)
+
+		if hold.Status != types.HoldStatusWaiting {
+			t.Fatalf("expected a waiting hold while every copy is lent, got %+v", hold)
+		}
+
+		api.do(http.MethodPost, "/holds", types.PlaceHoldPayload{UserId: holder.Id, BookId: book.Id}, http.StatusConflict, nil)
+
+		var returned types.Loan
+		api.do(http.MethodPost, "/loans/"+loans[0].Id+"/return", nil, http.StatusOK, &returned)
+
+		if returned.ReturnDate == nil {
+			t.Errorf("expected a return date, got %+v", returned)
+		}
+
+		api.do(http.MethodPost, "/loans/"+loans[0].Id+"/return", nil, http.StatusUnprocessableEntity, nil)
+
+		api.do(http.MethodGet, "/holds/"+hold.Id, nil, http.StatusOK, &hold)
+
+		if hold.Status != types.HoldStatusReady || hold.BookCopyId == nil || *hold.BookCopyId != loans[0].BookCopyId {
+			t.Fatalf("expected the hold to be ready with copy %s, got %+v", loans[0].BookCopyId, hold)
+		}
+
+		payload := types.CreateLoanPayload{
+			UserId:       users[0].Id,
+			BookCopyId:   *hold.BookCopyId,
+			Status:       "active",
+			LoanDate:     today,
+			ExpiringDate: today.AddDate(0, 0, 14),
+		}
+
+		api.do(http.MethodPost, "/loans", payload, http.StatusUnprocessableEntity, nil)
+
+		payload.UserId = holder.Id
+		api.do(http.MethodPost, "/loans", payload, http.StatusCreated, nil)
+
+		api.do(http.MethodGet, "/holds/"+hold.Id, nil, http.StatusOK, &hold)
+
+		if hold.Status != types.HoldStatusFulfilled {
+			t.Errorf("expected the hold to be fulfilled, got %+v", hold)
+		}
+	})
+}
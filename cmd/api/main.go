@@ -9,44 +9,98 @@
 //	@license.url	https://opensource.org/licenses/MIT
 
 // @host						localhost:8080
-// @BasePath					/
+// @BasePath					/v1
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/gfteix/book_loan_system/cmd/migrate/migrations"
+	"github.com/gfteix/book_loan_system/pkg/apiversion"
 	"github.com/gfteix/book_loan_system/pkg/config"
 	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/idempotency"
+	"github.com/gfteix/book_loan_system/pkg/logging"
+	"github.com/gfteix/book_loan_system/pkg/metrics"
+	"github.com/gfteix/book_loan_system/pkg/mq"
+	"github.com/gfteix/book_loan_system/pkg/openlibrary"
+	"github.com/gfteix/book_loan_system/pkg/ratelimit"
+	"github.com/gfteix/book_loan_system/pkg/tracing"
 	httpSwagger "github.com/swaggo/http-swagger"
 
+	"github.com/gfteix/book_loan_system/internal/authors"
 	"github.com/gfteix/book_loan_system/internal/books"
+	"github.com/gfteix/book_loan_system/internal/export"
+	"github.com/gfteix/book_loan_system/internal/health"
+	"github.com/gfteix/book_loan_system/internal/holds"
 	"github.com/gfteix/book_loan_system/internal/loans"
+	"github.com/gfteix/book_loan_system/internal/subjects"
 	"github.com/gfteix/book_loan_system/internal/users"
+	"github.com/gfteix/book_loan_system/internal/works"
+	"github.com/gfteix/book_loan_system/types"
 
-	_ "github.com/gfteix/book_loan_system/docs" // Import the generated Swagger docs
+	"github.com/gfteix/book_loan_system/docs"
 )
 
 func main() {
-	db, err := db.NewPostgreSQLStorage(db.DBConfig{
-		DBHost:     config.Envs.DBHost,
-		DBPort:     config.Envs.DBPort,
-		DBUser:     config.Envs.DBUser,
-		DBName:     config.Envs.DBName,
-		DBPassword: config.Envs.DBPassword,
+	logging.Setup("api", config.Envs.LogLevel)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "api", tracing.Config{Exporter: config.Envs.TraceExporter})
+	if err != nil {
+		slog.Error("error starting tracing", "error", err)
+		os.Exit(1)
+	}
+
+	pool, err := db.NewPostgreSQLStorage(db.DBConfig{
+		DSN:             config.Envs.DBDSN,
+		DBHost:          config.Envs.DBHost,
+		DBPort:          config.Envs.DBPort,
+		DBUser:          config.Envs.DBUser,
+		DBName:          config.Envs.DBName,
+		DBPassword:      config.Envs.DBPassword,
+		SSLMode:         config.Envs.DBSSLMode,
+		MaxOpenConns:    config.Envs.DBMaxOpenConns,
+		MaxIdleConns:    config.Envs.DBMaxIdleConns,
+		ConnMaxLifetime: config.Envs.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.Envs.DBConnMaxIdleTime,
 	})
 
 	if err != nil {
-		log.Fatalf("error starting db: %v", err)
+		slog.Error("error starting db", "error", err)
+		os.Exit(1)
 	}
 
+	defer pool.Close()
+
+	if err := db.CheckSchema(context.Background(), pool, migrations.Latest()); err != nil {
+		slog.Error("refusing to start against this schema", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	addr := fmt.Sprintf(":%v", config.Envs.Port)
-	server := NewAPIServer(addr, db)
+	server := NewAPIServer(addr, pool)
+
+	err = server.Run(ctx)
 
-	if err := server.Run(); err != nil {
-		log.Fatalf("error running server: %v", err)
+	if err := shutdownTracing(context.Background()); err != nil {
+		slog.Error("error flushing traces", "error", err)
+	}
+
+	if err != nil {
+		slog.Error("error running server", "error", err)
+		os.Exit(1)
 	}
 }
 
@@ -62,24 +116,233 @@ func NewAPIServer(addr string, db *sql.DB) *APIServer {
 	}
 }
 
-func (s *APIServer) Run() error {
+// Run serves until ctx is cancelled, then stops accepting connections and
+// waits up to the configured shutdown timeout for in-flight requests.
+func (s *APIServer) Run(ctx context.Context) error {
+	handler, healthHandler, err := s.handler()
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              s.addr,
+		Handler:           handler,
+		ReadTimeout:       config.Envs.ReadTimeout,
+		ReadHeaderTimeout: config.Envs.ReadHeaderTimeout,
+		WriteTimeout:      config.Envs.WriteTimeout,
+		IdleTimeout:       config.Envs.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		slog.Info("listening", "addr", s.addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down gracefully")
+	healthHandler.Drain()
+	time.Sleep(config.Envs.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Envs.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down server: %w", err)
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	slog.Info("server stopped")
+
+	return nil
+}
+
+// handler returns every route wrapped in the middleware chain, and the health
+// handler to drain on shutdown.
+func (s *APIServer) handler() (http.Handler, *health.Handler, error) {
 	router := http.NewServeMux()
 
+	reg := metrics.NewRegistry()
+	metrics.RegisterDB(reg, s.db, config.Envs.DBName)
+	reg.MustRegister(metrics.NewLibraryCollector(s.db, config.Envs.MetricsQueryTimeout))
+	httpMetrics := metrics.NewHTTP(reg)
+
+	router.Handle("GET /metrics", metrics.Handler(reg))
+
+	healthHandler := s.registerRoutes(router)
+
+	limiter, err := s.rateLimiter()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idempotent, err := s.idempotency()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return chain(router, limiter, idempotent, httpMetrics), healthHandler, nil
+}
+
+// chain wraps router in the middleware every request goes through. limiter
+// and idempotent may be nil when disabled. Rate limiting wraps idempotency so
+// replayed responses count too.
+func chain(router *http.ServeMux, limiter *ratelimit.Limiter, idempotent *idempotency.Middleware, httpMetrics *metrics.HTTP) http.Handler {
+	var handler http.Handler = router
+
+	if idempotent != nil {
+		handler = idempotent.Wrap(handler)
+	}
+
+	if limiter != nil {
+		handler = limiter.Middleware(router, handler)
+	}
+
+	handler = withPattern(router, handler)
+
+	return apiversion.Middleware(logging.Middleware(tracing.Middleware(httpMetrics.Middleware(handler))), unversionedPaths...)
+}
+
+// withPattern records the route pattern router would match on the request
+// before serving it with next, as the mux itself does. Responses written
+// before the mux is reached, such as 429s and idempotent replays, are then
+// still labelled by route in metrics and traces.
+func withPattern(router *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, r.Pattern = router.Handler(r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unversionedPaths are served as is rather than under apiversion.Prefix.
+var unversionedPaths = []string{"/healthz", "/readyz", "/metrics", "/swagger/"}
+
+// unlimitedRoutes are never rate limited.
+var unlimitedRoutes = []string{"GET /healthz", "GET /readyz", "GET /metrics", "/swagger/"}
+
+// rateLimiter returns the configured rate limiter, or nil when it is off.
+func (s *APIServer) rateLimiter() (*ratelimit.Limiter, error) {
+	var store ratelimit.Store
+
+	switch config.Envs.RateLimitBackend {
+	case "none":
+		return nil, nil
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		store = ratelimit.NewPostgresStore(s.db)
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", config.Envs.RateLimitBackend)
+	}
+
+	policy, err := ratelimit.ParsePolicy(config.Envs.RateLimitDefault, config.Envs.RateLimitRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range unlimitedRoutes {
+		policy.Routes[pattern] = ratelimit.Rule{}
+	}
+
+	return ratelimit.New(store, policy, config.Envs.TrustProxy), nil
+}
+
+// idempotency returns the configured Idempotency-Key handling, or nil when it
+// is off.
+func (s *APIServer) idempotency() (*idempotency.Middleware, error) {
+	var store idempotency.Store
+
+	switch config.Envs.IdempotencyBackend {
+	case "none":
+		return nil, nil
+	case "memory":
+		store = idempotency.NewMemoryStore()
+	case "postgres":
+		store = idempotency.NewPostgresStore(s.db)
+	default:
+		return nil, fmt.Errorf("unknown idempotency backend %q", config.Envs.IdempotencyBackend)
+	}
+
+	return idempotency.New(store, config.Envs.IdempotencyTTL, config.Envs.TrustProxy), nil
+}
+
+// registerRoutes registers the probes, the docs and every API route, without
+// the version prefix. The returned health handler is drained on shutdown.
+func (s *APIServer) registerRoutes(router types.Router) *health.Handler {
+	checks := map[string]health.Check{
+		"postgres": s.db.PingContext,
+	}
+
+	if config.Envs.ReadinessCheckMQ {
+		checks["rabbitmq"] = func(ctx context.Context) error {
+			timeout := config.Envs.ReadinessTimeout
+			if deadline, ok := ctx.Deadline(); ok {
+				timeout = time.Until(deadline)
+			}
+
+			return mq.Ping(mq.MQConfig{
+				Username: config.Envs.MQUsername,
+				Password: config.Envs.MQPassword,
+				Host:     config.Envs.MQHost,
+				Port:     config.Envs.MQPort,
+			}, timeout)
+		}
+	}
+
+	healthHandler := health.NewHandler(checks, config.Envs.ReadinessTimeout)
+	healthHandler.RegisterRoutes(router)
+
 	router.Handle("/swagger/", httpSwagger.WrapHandler)
+	router.HandleFunc("GET /openapi.json", handleOpenAPI)
+
+	timeout := config.Envs.DBQueryTimeout
 
-	userRepository := users.NewRepository(s.db)
+	userRepository := users.NewRepository(s.db, timeout)
 	userHandler := users.NewHandler(userRepository)
 	userHandler.RegisterRoutes(router)
 
-	bookRepository := books.NewRepository(s.db)
-	bookHandler := books.NewHandler(bookRepository)
+	bookRepository := books.NewRepository(s.db, timeout)
+	bookLookup := openlibrary.NewClient(openlibrary.Config{
+		BaseURL:   config.Envs.OpenLibraryURL,
+		Timeout:   config.Envs.OpenLibraryTimeout,
+		CacheTTL:  config.Envs.OpenLibraryCacheTTL,
+		CacheSize: config.Envs.OpenLibraryCacheSize,
+	})
+	bookHandler := books.NewHandler(bookRepository, bookLookup)
 	bookHandler.RegisterRoutes(router)
 
-	loanRepository := loans.NewRepository(s.db)
+	authorHandler := authors.NewHandler(authors.NewRepository(s.db, timeout), bookRepository)
+	authorHandler.RegisterRoutes(router)
+
+	subjectHandler := subjects.NewHandler(subjects.NewRepository(s.db, timeout), bookRepository)
+	subjectHandler.RegisterRoutes(router)
+
+	workHandler := works.NewHandler(works.NewRepository(s.db, timeout), bookRepository)
+	workHandler.RegisterRoutes(router)
+
+	holdHandler := holds.NewHandler(holds.NewRepository(s.db, timeout))
+	holdHandler.RegisterRoutes(router)
+
+	loanRepository := loans.NewRepository(s.db, timeout)
 	loanHandler := loans.NewHandler(loanRepository)
 	loanHandler.RegisterRoutes(router)
 
-	log.Printf("Listening on %v", s.addr)
+	exportHandler := export.NewHandler(export.NewExporter(bookRepository, userRepository, loanRepository))
+	exportHandler.RegisterRoutes(router)
+
+	return healthHandler
+}
 
-	return http.ListenAndServe(s.addr, router)
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(docs.OpenAPI)
 }
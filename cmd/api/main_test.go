@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/docs"
+	"github.com/gfteix/book_loan_system/pkg/apiversion"
+	"github.com/gfteix/book_loan_system/pkg/idempotency"
+	"github.com/gfteix/book_loan_system/pkg/metrics"
+	"github.com/gfteix/book_loan_system/pkg/openapi"
+	"github.com/gfteix/book_loan_system/pkg/ratelimit"
+)
+
+// undocumentedRoutes are registered by registerRoutes but are not part of
+// the API the spec describes.
+var undocumentedRoutes = []string{"/swagger/", "GET /openapi.json"}
+
+// successStatuses are the 2xx codes handlers use.
+var successStatuses = map[string]string{
+	"StatusOK":        "200",
+	"StatusCreated":   "201",
+	"StatusAccepted":  "202",
+	"StatusNoContent": "204",
+}
+
+// successWriters are the utils helpers that write a fixed 2xx status.
+var successWriters = map[string]string{
+	"WriteCreated": "201",
+}
+
+var routerAnnotation = regexp.MustCompile(`@Router\s+(\S+)\s+\[(\w+)\]`)
+
+type recordingRouter struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (r *recordingRouter) Handle(pattern string, handler http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+	r.ServeMux.Handle(pattern, handler)
+}
+
+func (r *recordingRouter) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	r.patterns = append(r.patterns, pattern)
+	r.ServeMux.HandleFunc(pattern, handler)
+}
+
+func parseSpec(t *testing.T) *openapi.Document {
+	t.Helper()
+
+	spec, err := openapi.Parse(docs.OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return spec
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	spec := parseSpec(t)
+
+	t.Run("should be generated from the current swagger document", func(t *testing.T) {
+		swagger, err := os.ReadFile("../../docs/swagger.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := openapi.Convert(swagger)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(want, docs.OpenAPI) {
+			t.Error("docs/openapi.json is out of date, run make docs")
+		}
+	})
+
+	t.Run("should be served under the version prefix", func(t *testing.T) {
+		if len(spec.Servers) != 1 || spec.Servers[0].URL != apiversion.Prefix {
+			t.Errorf("expected a single server %q, got %+v", apiversion.Prefix, spec.Servers)
+		}
+	})
+
+	t.Run("should document exactly the registered routes", func(t *testing.T) {
+		router := &recordingRouter{ServeMux: http.NewServeMux()}
+		NewAPIServer(":0", nil).registerRoutes(router)
+
+		var registered []string
+		for _, pattern := range router.patterns {
+			if !slices.Contains(undocumentedRoutes, pattern) {
+				registered = append(registered, pattern)
+			}
+		}
+
+		sort.Strings(registered)
+		documented := spec.Routes()
+
+		for _, route := range registered {
+			if !slices.Contains(documented, route) {
+				t.Errorf("route %q is registered but not documented", route)
+			}
+		}
+
+		for _, route := range documented {
+			if !slices.Contains(registered, route) {
+				t.Errorf("route %q is documented but not registered", route)
+			}
+		}
+	})
+
+	t.Run("should document the success statuses handlers write", func(t *testing.T) {
+		files, err := filepath.Glob("../../internal/*/handler.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fset := token.NewFileSet()
+
+		for _, file := range files {
+			f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, decl := range f.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Doc == nil {
+					continue
+				}
+
+				m := routerAnnotation.FindStringSubmatch(fn.Doc.Text())
+				if m == nil {
+					continue
+				}
+
+				op, ok := spec.Paths[m[1]][m[2]]
+				if !ok {
+					t.Errorf("%s: %s %s is not in the spec", fn.Name.Name, m[2], m[1])
+					continue
+				}
+
+				var documented []string
+				for code := range op.Responses {
+					if strings.HasPrefix(code, "2") {
+						documented = append(documented, code)
+					}
+				}
+
+				written := writtenStatuses(fn)
+
+				sort.Strings(documented)
+				if !slices.Equal(documented, written) {
+					t.Errorf("%s: documents %v but writes %v", fn.Name.Name, documented, written)
+				}
+			}
+		}
+	})
+
+	t.Run("should describe the json fields of each type", func(t *testing.T) {
+		structs := parseStructs(t, "../../types/types.go")
+
+		for name, schema := range spec.Components.Schemas {
+			typeName, ok := strings.CutPrefix(name, "types.")
+			if !ok {
+				t.Errorf("schema %q is not a types struct", name)
+				continue
+			}
+
+			st, ok := structs[typeName]
+			if !ok {
+				t.Errorf("schema %q has no matching struct", name)
+				continue
+			}
+
+			var documented []string
+			for property := range schema.Properties {
+				documented = append(documented, property)
+			}
+
+			sort.Strings(documented)
+			fields := jsonFields(st, structs)
+
+			if !slices.Equal(documented, fields) {
+				t.Errorf("schema %q has properties %v, struct has %v", name, documented, fields)
+			}
+		}
+	})
+}
+
+// writtenStatuses returns the 2xx statuses fn refers to, sorted.
+func writtenStatuses(fn *ast.FuncDecl) []string {
+	seen := map[string]bool{}
+
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "http" {
+			if code, ok := successStatuses[sel.Sel.Name]; ok {
+				seen[code] = true
+			}
+		}
+
+		if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "utils" {
+			if code, ok := successWriters[sel.Sel.Name]; ok {
+				seen[code] = true
+			}
+		}
+
+		return true
+	})
+
+	var codes []string
+	for code := range seen {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	return codes
+}
+
+func parseStructs(t *testing.T, path string) map[string]*ast.StructType {
+	t.Helper()
+
+	f, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	structs := map[string]*ast.StructType{}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		if spec, ok := n.(*ast.TypeSpec); ok {
+			if st, ok := spec.Type.(*ast.StructType); ok {
+				structs[spec.Name.Name] = st
+			}
+		}
+
+		return true
+	})
+
+	return structs
+}
+
+// jsonFields returns the names encoding/json uses for the fields of st,
+// sorted. Untagged embedded structs from the same file are flattened.
+func jsonFields(st *ast.StructType, structs map[string]*ast.StructType) []string {
+	var fields []string
+
+	for _, field := range st.Fields.List {
+		var tag string
+		if field.Tag != nil {
+			raw, _ := strconv.Unquote(field.Tag.Value)
+			tag, _, _ = strings.Cut(reflect.StructTag(raw).Get("json"), ",")
+		}
+
+		if tag == "-" {
+			continue
+		}
+
+		if len(field.Names) == 0 {
+			if ident, ok := field.Type.(*ast.Ident); ok && tag == "" {
+				if embedded, ok := structs[ident.Name]; ok {
+					fields = append(fields, jsonFields(embedded, structs)...)
+				}
+			}
+
+			continue
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+
+			if tag != "" {
+				fields = append(fields, tag)
+			} else {
+				fields = append(fields, name.Name)
+			}
+		}
+	}
+
+	sort.Strings(fields)
+
+	return fields
+}
+
+func TestChain(t *testing.T) {
+	t.Run("should label every response with its route through the full chain", func(t *testing.T) {
+		served := 0
+
+		router := http.NewServeMux()
+		router.HandleFunc("POST /books", func(w http.ResponseWriter, r *http.Request) {
+			served++
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		reg := metrics.NewRegistry()
+		limiter := ratelimit.New(ratelimit.NewMemoryStore(), ratelimit.Policy{
+			Routes: map[string]ratelimit.Rule{"POST /books": {Limit: 2, Period: time.Hour}},
+		}, false)
+		idempotent := idempotency.New(idempotency.NewMemoryStore(), time.Hour, false)
+
+		handler := chain(router, limiter, idempotent, metrics.NewHTTP(reg))
+
+		// Created, replayed, then limited.
+		for _, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests} {
+			req := httptest.NewRequest(http.MethodPost, apiversion.Prefix+"/books", strings.NewReader(`{}`))
+			req.Header.Set(idempotency.KeyHeader, "key")
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != want {
+				t.Fatalf("expected status code %d, got %d", want, rr.Code)
+			}
+		}
+
+		if served != 1 {
+			t.Errorf("expected the handler to run once, got %d", served)
+		}
+
+		rr := httptest.NewRecorder()
+		metrics.Handler(reg).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		for _, line := range []string{
+			`http_requests_total{code="201",route="POST /books"} 2`,
+			`http_requests_total{code="429",route="POST /books"} 1`,
+		} {
+			if !strings.Contains(rr.Body.String(), line) {
+				t.Errorf("expected %s in:\n%s", line, rr.Body)
+			}
+		}
+
+		if strings.Contains(rr.Body.String(), `route="unmatched"`) {
+			t.Errorf("expected no unmatched requests, got:\n%s", rr.Body)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/mq"
+)
+
+func TestPostgresBroker(t *testing.T) {
+	pool := openTestDB(t)
+
+	topology := mq.Topology{
+		Exchanges: []string{"test.events"},
+		Queues:    []string{"test.retries"},
+		Bindings:  []mq.Binding{{Exchange: "test.events", Queue: "test.retries", Key: "#"}},
+	}
+
+	broker := mq.NewPostgresBroker(pool, mq.PostgresConfig{PollInterval: 10 * time.Millisecond}, topology)
+	defer broker.Close()
+
+	opts := mq.ConsumeOptions{MaxAttempts: 3, RetryDelay: 100 * time.Millisecond}
+
+	// consume handles messages with handle until it has seen want
+	// deliveries, and returns them.
+	consume := func(t *testing.T, want int, handle mq.Handler) []mq.Delivery {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		deliveries := make(chan mq.Delivery, want+1)
+		done := make(chan error, 1)
+
+		go func() {
+			done <- broker.Consume(ctx, "test.retries", opts, func(ctx context.Context, d mq.Delivery) error {
+				deliveries <- d
+				return handle(ctx, d)
+			})
+		}()
+
+		var got []mq.Delivery
+		for range want {
+			select {
+			case d := <-deliveries:
+				got = append(got, d)
+			case <-ctx.Done():
+				t.Fatalf("expected %d deliveries, got %d", want, len(got))
+			}
+		}
+
+		// Leave time for a delivery that should not happen.
+		time.Sleep(500 * time.Millisecond)
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+
+		if len(deliveries) != 0 {
+			t.Errorf("expected %d deliveries, got more", want)
+		}
+
+		return got
+	}
+
+	publish := func(t *testing.T, id string) {
+		t.Helper()
+
+		if _, err := pool.Exec("DELETE FROM mq_messages WHERE queue = 'test.retries'"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := broker.Publish(context.Background(), "test.events", "test", mq.Message{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("should retry with backoff and keep the message once dead", func(t *testing.T) {
+		publish(t, "retried")
+
+		var times []time.Time
+		got := consume(t, 3, func(ctx context.Context, d mq.Delivery) error {
+			times = append(times, time.Now())
+			return errors.New("smtp unavailable")
+		})
+
+		for i, d := range got {
+			if d.Attempt != i+1 || d.Redelivered != (i > 0) {
+				t.Errorf("expected attempt %d, got %d (redelivered %v)", i+1, d.Attempt, d.Redelivered)
+			}
+		}
+
+		for i, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+			if wait := times[i+1].Sub(times[i]); wait < want {
+				t.Errorf("expected attempt %d to wait at least %v, waited %v", i+2, want, wait)
+			}
+		}
+
+		var attempts int
+		var lastError string
+		var dead bool
+		err := pool.QueryRow("SELECT attempts, last_error, dead_at IS NOT NULL FROM mq_messages WHERE message_id = 'retried'").
+			Scan(&attempts, &lastError, &dead)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if attempts != 3 || lastError != "smtp unavailable" || !dead {
+			t.Errorf("expected a dead message after 3 attempts, got %d attempts, %q, dead %v", attempts, lastError, dead)
+		}
+	})
+
+	t.Run("should delete a message with a permanent error", func(t *testing.T) {
+		publish(t, "dropped")
+
+		consume(t, 1, func(ctx context.Context, d mq.Delivery) error {
+			return mq.Permanent(mq.ErrDecode)
+		})
+
+		var count int
+		if err := pool.QueryRow("SELECT COUNT(*) FROM mq_messages WHERE message_id = 'dropped'").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+
+		if count != 0 {
+			t.Errorf("expected the message to be deleted, got %d rows", count)
+		}
+	})
+}
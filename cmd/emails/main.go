@@ -1,176 +1,135 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/smtp"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
-	"slices"
-	"sync"
 	"syscall"
 	"time"
 
+	"github.com/gfteix/book_loan_system/cmd/migrate/migrations"
+	"github.com/gfteix/book_loan_system/internal/emails"
 	"github.com/gfteix/book_loan_system/pkg/config"
 	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/events"
+	"github.com/gfteix/book_loan_system/pkg/logging"
+	"github.com/gfteix/book_loan_system/pkg/metrics"
 	"github.com/gfteix/book_loan_system/pkg/mq"
-	"github.com/gfteix/book_loan_system/types"
-	"github.com/rabbitmq/amqp091-go"
+	"github.com/gfteix/book_loan_system/pkg/tracing"
 )
 
-type LoanData struct {
-	Email         string
-	Expiring_date time.Time
-	BookTitle     string
-}
-
 func main() {
-	conn, ch, err := mq.NewRabbitMQClient(mq.MQConfig{
-		Username: config.Envs.MQUsername,
-		Password: config.Envs.MQPassword,
-		Host:     config.Envs.MQHost,
-		Port:     config.Envs.MQPort,
-	})
-
-	if err != nil {
-		log.Fatalf("error creating mq client: %v", err)
-	}
-
-	defer conn.Close()
-	defer ch.Close()
-
-	log.Print("successfully connected to rabbit mq client")
-
-	_, err = mq.DeclareQueue(ch, "LoanEvents")
-	if err != nil {
-		log.Fatalf("error declaring queue: %v", err)
-	}
+	logging.Setup("emails", config.Envs.LogLevel)
 
-	messages, err := ch.Consume("LoanEvents", "", false, false, false, false, nil)
+	shutdownTracing, err := tracing.Setup(context.Background(), "emails", tracing.Config{Exporter: config.Envs.TraceExporter})
 	if err != nil {
-		log.Fatalf("error consuming LoanEvents: %v", err)
+		slog.Error("error starting tracing", "error", err)
+		os.Exit(1)
 	}
+	defer shutdownTracing(context.Background())
 
-	var wg sync.WaitGroup
+	reg := metrics.NewRegistry()
+	workerMetrics := metrics.NewEmailWorker(reg)
 
+	metricsServer := &http.Server{Addr: config.Envs.MetricsAddr, Handler: metrics.Handler(reg)}
 	go func() {
-		for d := range messages {
-			wg.Add(1)
-			go func(d amqp091.Delivery) {
-				defer wg.Done()
-				processMessage(d)
-			}(d)
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("error serving metrics", "error", err)
 		}
 	}()
-
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
-	<-stopChan
-
-	log.Println("Shutting down gracefully...")
-	ch.Close() // Stop receiving new messages
-	wg.Wait()  // Wait for all in-flight messages to be processed
-	log.Println("All workers finished")
-}
-
-func processMessage(d amqp091.Delivery) {
-	log.Printf("Received message from LoanEVents: %s", d.Body)
-
-	var body types.Event
-
-	err := json.Unmarshal(d.Body, &body)
-
-	if err != nil {
-		log.Printf("fail to unmarshal message body %v", err)
-	}
-
-	validTypes := []string{"LoanExpired", "LoanExpiring"}
-
-	if !slices.Contains(validTypes, body.Type) {
-		log.Printf("Unrecognized event type: %s", body.Type)
-		return
-	}
-
-	data, err := getDataForEmail(body.Payload.LoanId)
-
+	defer metricsServer.Close()
+
+	pool, err := db.NewPostgreSQLStorage(db.DBConfig{
+		DSN:             config.Envs.DBDSN,
+		DBHost:          config.Envs.DBHost,
+		DBPort:          config.Envs.DBPort,
+		DBUser:          config.Envs.DBUser,
+		DBName:          config.Envs.DBName,
+		DBPassword:      config.Envs.DBPassword,
+		SSLMode:         config.Envs.DBSSLMode,
+		MaxOpenConns:    config.Envs.DBMaxOpenConns,
+		MaxIdleConns:    config.Envs.DBMaxIdleConns,
+		ConnMaxLifetime: config.Envs.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.Envs.DBConnMaxIdleTime,
+	})
 	if err != nil {
-		log.Printf("error on getDataForEmail: %v", err)
-		return
+		slog.Error("error starting db", "error", err)
+		os.Exit(1)
 	}
+	defer pool.Close()
 
-	var subject string
-	var message string
-
-	switch body.Type {
-	case "LoanExpired":
-		subject = "Loan Expired"
-		message = fmt.Sprintf("Your loan of the book %v expired on %v, please return the book to the library.",
-			data.BookTitle, data.Expiring_date.Format("2006-01-02"))
-	case "LoanExpiring":
-		subject = "Loan Expiring"
-		message = fmt.Sprintf(
-			"Your loan of the book %v will expire on %v, please remember to return the book to the library until the expiration date.",
-			data.BookTitle, data.Expiring_date.Format("2006-01-02"))
+	if err := db.CheckSchema(context.Background(), pool, migrations.Latest()); err != nil {
+		slog.Error("refusing to start against this schema", "error", err)
+		os.Exit(1)
 	}
 
-	err = sendEmail([]string{data.Email}, subject, message)
+	w := emails.NewWorker(pool, &emails.SMTP{
+		Addr:     net.JoinHostPort(config.Envs.SMTPHost, config.Envs.SMTPPort),
+		From:     "book_loan_system@email.com",
+		Username: config.Envs.SMTPUsername,
+		Password: config.Envs.SMTPPassword,
+	}, workerMetrics)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Connecting to RabbitMQ is retried until the process is stopped.
+	broker, err := mq.Open(ctx, mq.Config{
+		Backend: config.Envs.MQBackend,
+		RabbitMQ: mq.MQConfig{
+			Username: config.Envs.MQUsername,
+			Password: config.Envs.MQPassword,
+			Host:     config.Envs.MQHost,
+			Port:     config.Envs.MQPort,
+		},
+		Postgres: mq.PostgresConfig{
+			PollInterval: config.Envs.MQPollInterval,
+			LockTimeout:  config.Envs.MQLockTimeout,
+		},
+	}, pool, events.Topology)
 
 	if err != nil {
-		log.Printf("error processing message %v", err)
-		return
+		slog.Error("error connecting to broker", "backend", config.Envs.MQBackend, "error", err)
+		os.Exit(1)
 	}
-	d.Ack(false)
-}
+	defer broker.Close()
 
-func getDataForEmail(loanId string) (LoanData, error) {
-	db, err := db.NewPostgreSQLStorage(db.DBConfig{
-		DBHost:     config.Envs.DBHost,
-		DBPort:     config.Envs.DBPort,
-		DBUser:     config.Envs.DBUser,
-		DBName:     config.Envs.DBName,
-		DBPassword: config.Envs.DBPassword,
-	})
-	if err != nil {
-		return LoanData{}, err
-	}
-	defer db.Close()
-
-	rows, err := db.Query("SELECT u.email, l.expiring_date, b.title FROM loans l INNER JOIN users u ON l.user_id = u.id INNER JOIN book_copies bi ON bi.id = l.book_item_id INNER JOIN books b ON b.id = bi.book_id WHERE l.id = $1", loanId)
+	slog.Info("consuming "+events.EmailsQueue, "workers", config.Envs.EmailConcurrency, "prefetch", config.Envs.EmailPrefetch)
 
-	if err != nil {
-		return LoanData{}, err
-	}
-
-	defer rows.Close()
-
-	var data LoanData
+	// Prefetch caps the unacknowledged messages in this process, so the rest
+	// of a burst stays queued in the broker.
+	consumed := make(chan error, 1)
+	go func() {
+		consumed <- broker.Consume(ctx, events.EmailsQueue, mq.ConsumeOptions{
+			Workers:     config.Envs.EmailConcurrency,
+			Prefetch:    config.Envs.EmailPrefetch,
+			MaxAttempts: config.Envs.MQMaxAttempts,
+			RetryDelay:  config.Envs.MQRetryDelay,
+		}, w.HandleMessage)
+	}()
 
-	if rows.Next() {
-		err := rows.Scan(
-			&data.Email,
-			&data.Expiring_date,
-			&data.BookTitle,
-		)
-		if err != nil {
-			return data, err
-		}
+	select {
+	case err := <-consumed:
+		slog.Error("emails consumer stopped", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
 	}
 
-	return data, nil
-}
-
-func sendEmail(to []string, subject string, body string) error {
-	from := "book_loan_system@email.com"
-	msg := fmt.Sprintf("Subject: %s\r\n\r\n%s", subject, body)
-
-	auth := smtp.PlainAuth("", "", "", config.Envs.SMTPHost)
-	err := smtp.SendMail(fmt.Sprintf("%s:%s", config.Envs.SMTPHost, config.Envs.SMTPPort), auth, from, to, []byte(msg))
-	if err != nil {
-		log.Printf("Failed to send email: %v", err)
-		return err
+	slog.Info("shutting down gracefully")
+
+	// Consume stops receiving and returns once the messages in flight and
+	// already prefetched are handled.
+	select {
+	case <-consumed:
+		slog.Info("all workers finished")
+	case <-time.After(config.Envs.ShutdownTimeout):
+		// Unacknowledged messages are delivered again: RabbitMQ requeues them
+		// when the connection closes, and the postgres broker once their
+		// lock times out.
+		slog.Warn("shutdown timeout reached with messages in flight")
 	}
-
-	log.Println("Email sent successfully")
-	return nil
 }
@@ -1,57 +1,170 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 
+	"github.com/gfteix/book_loan_system/cmd/migrate/migrations"
 	"github.com/gfteix/book_loan_system/pkg/config"
 	"github.com/gfteix/book_loan_system/pkg/db"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
+const usage = `usage: migrate <command>
+
+commands:
+  up          apply every pending migration
+  down        revert the last applied migration
+  down -all   revert every migration
+  steps N     apply the next N migrations, or revert the last N if N is negative
+  goto V      migrate up or down to version V
+  status      show the schema version and the pending migrations
+  force V     record version V as clean without running anything, after
+              fixing a failed migration by hand; -1 records no version`
+
 func main() {
-	db, err := db.NewPostgreSQLStorage(db.DBConfig{
-		DBHost:     config.Envs.DBHost,
-		DBPort:     config.Envs.DBPort,
-		DBUser:     config.Envs.DBUser,
-		DBName:     config.Envs.DBName,
-		DBPassword: config.Envs.DBPassword,
+	args := os.Args[1:]
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+
+	pool, err := db.NewPostgreSQLStorage(db.DBConfig{
+		DSN:             config.Envs.DBDSN,
+		DBHost:          config.Envs.DBHost,
+		DBPort:          config.Envs.DBPort,
+		DBUser:          config.Envs.DBUser,
+		DBName:          config.Envs.DBName,
+		DBPassword:      config.Envs.DBPassword,
+		SSLMode:         config.Envs.DBSSLMode,
+		MaxOpenConns:    config.Envs.DBMaxOpenConns,
+		MaxIdleConns:    config.Envs.DBMaxIdleConns,
+		ConnMaxLifetime: config.Envs.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.Envs.DBConnMaxIdleTime,
 	})
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	driver, err := postgres.WithInstance(pool, &postgres.Config{})
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://cmd/migrate/migrations",
-		"postgres",
-		driver,
-	)
+	source, err := iofs.New(migrations.FS, ".")
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	cmd := os.Args[(len(os.Args) - 1)]
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m.Log = logger{}
+
+	if err := run(m, args); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		log.Fatal(err)
+	}
+}
+
+func run(m *migrate.Migrate, args []string) error {
+	cmd, args := args[0], args[1:]
+
+	switch {
+	case cmd == "up" && len(args) == 0:
+		return m.Up()
+	case cmd == "down" && len(args) == 0:
+		return m.Steps(-1)
+	case cmd == "down" && len(args) == 1 && args[0] == "-all":
+		return m.Down()
+	case cmd == "status" && len(args) == 0:
+		return status(m)
+	}
+
+	if len(args) != 1 {
+		return errors.New(usage)
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %q is not a number", cmd, args[0])
+	}
 
-	if cmd == "up" { // to apply the changes use "up"
-		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal(err)
+	switch cmd {
+	case "steps":
+		return m.Steps(n)
+	case "goto":
+		if n < 0 {
+			return fmt.Errorf("goto: invalid version %d", n)
 		}
+
+		return m.Migrate(uint(n))
+	case "force":
+		return m.Force(n)
+	default:
+		return errors.New(usage)
+	}
+}
+
+// status prints the schema version and whether each migration is applied.
+func status(m *migrate.Migrate) error {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		version, err = 0, nil
 	}
 
-	if cmd == "down" { // to revert the changes use "down"
-		if err := m.Down(); err != nil && err != migrate.ErrNoChange {
-			log.Fatal(err)
+	if err != nil {
+		return err
+	}
+
+	list, err := migrations.List()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dirty:
+		fmt.Printf("version %d is dirty: fix it by hand, then run force with the last clean version\n", version)
+	case version == 0:
+		fmt.Println("no migrations applied")
+	default:
+		fmt.Printf("version %d\n", version)
+	}
+
+	pending := 0
+
+	for _, migration := range list {
+		state := "applied"
+		if migration.Version > version || (dirty && migration.Version == version) {
+			state = "pending"
+			pending++
 		}
+
+		fmt.Printf("  %-8s %d %s\n", state, migration.Version, migration.Name)
 	}
+
+	fmt.Printf("%d pending\n", pending)
+
+	return nil
+}
+
+// logger prints each migration as it runs.
+type logger struct{}
+
+func (logger) Printf(format string, v ...any) {
+	log.Printf(format, v...)
+}
+
+func (logger) Verbose() bool {
+	return false
 }
@@ -1,2 +1 @@
-DELETE FROM users 
-WHERE email = 'johndoe@example.com';
\ No newline at end of file
+-- Left empty: rows from the old sample user are indistinguishable from real ones.
@@ -1,3 +1 @@
-INSERT INTO users (id, name, email) 
-VALUES 
-    (gen_random_uuid(), 'John Doe', 'johndoe@example.com');
\ No newline at end of file
+-- The sample user is inserted by cmd/seed.
@@ -1,8 +1 @@
-DELETE FROM books 
-WHERE isbn IN (
-    '9780143058144', 
-    '9780140449242', 
-    '9780199232765', 
-    '9780143035008', 
-    '9780805210576'
-);
\ No newline at end of file
+-- Left empty so rolling back never deletes catalog rows.
@@ -1 +1 @@
-DELETE FROM book_copies WHERE location = 'Shelf A';
\ No newline at end of file
+-- Left empty so rolling back never deletes copies.
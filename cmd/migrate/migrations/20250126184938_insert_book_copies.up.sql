@@ -1,3 +1 @@
-INSERT INTO book_copies (id, book_id, location, condition, status) 
-SELECT gen_random_uuid(), id, 'Shelf A', 'New', 'Available' 
-FROM books;
\ No newline at end of file
+-- The sample copies are inserted by cmd/seed.
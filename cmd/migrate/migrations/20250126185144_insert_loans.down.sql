@@ -1 +1 @@
-DELETE FROM loans WHERE expiring_date = CURRENT_DATE + INTERVAL '1 day';
+-- Left empty so rolling back never deletes loans.
@@ -0,0 +1,19 @@
+-- Restores the ISBNs, merged books and copies archived by the up migration.
+-- Kept books get their original ISBN back first, so the restored books do
+-- not collide with them on books_isbn_key.
+UPDATE books b
+SET isbn = a.isbn
+FROM normalize_isbns_archive_isbns a
+WHERE b.id = a.id;
+
+INSERT INTO books
+SELECT * FROM normalize_isbns_archive_books;
+
+UPDATE book_copies bc
+SET book_id = a.book_id
+FROM normalize_isbns_archive_copies a
+WHERE bc.id = a.id;
+
+DROP TABLE normalize_isbns_archive_copies;
+DROP TABLE normalize_isbns_archive_books;
+DROP TABLE normalize_isbns_archive_isbns;
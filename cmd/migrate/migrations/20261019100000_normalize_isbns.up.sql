@@ -0,0 +1,69 @@
+-- Store every ISBN as an ISBN-13 without separators, matching what the API
+-- now writes on book creation.
+
+-- normalize_isbn returns the ISBN-13 form of raw, or NULL when raw is not a
+-- valid ISBN-10 or ISBN-13, the same rules as pkg/isbn.
+CREATE FUNCTION pg_temp.normalize_isbn(raw text) RETURNS text LANGUAGE sql IMMUTABLE AS $$
+    WITH stripped AS (SELECT regexp_replace(upper(raw), '[^0-9X]', '', 'g') AS s)
+    SELECT CASE
+        WHEN s ~ '^[0-9]{13}$'
+            AND (SELECT SUM(substr(s, i, 1)::int * CASE WHEN i % 2 = 1 THEN 1 ELSE 3 END)
+                FROM generate_series(1, 13) AS i) % 10 = 0
+        THEN s
+        WHEN s ~ '^[0-9]{9}[0-9X]$'
+            AND (SELECT SUM(CASE WHEN substr(s, i, 1) = 'X' THEN 10 ELSE substr(s, i, 1)::int END * (11 - i))
+                FROM generate_series(1, 10) AS i) % 11 = 0
+        THEN '978' || left(s, 9) || ((10 - (
+                SELECT SUM(substr('978' || left(s, 9), i, 1)::int * CASE WHEN i % 2 = 1 THEN 1 ELSE 3 END)
+                FROM generate_series(1, 12) AS i
+            ) % 10) % 10)::text
+    END
+    FROM stripped
+$$;
+
+-- Books whose ISBNs normalize to the same value are one book entered twice,
+-- for example as 978-0-14-305814-4 and 9780143058144. The oldest row is kept.
+-- Rows with an invalid ISBN are left as they are.
+CREATE TEMPORARY TABLE normalized_isbns AS
+SELECT id, isbn, first_value(id) OVER (PARTITION BY isbn ORDER BY created_at, id) AS keep_id
+FROM (SELECT id, created_at, pg_temp.normalize_isbn(isbn) AS isbn FROM books) AS b
+WHERE isbn IS NOT NULL;
+
+-- What this migration changes is archived so the down migration can restore
+-- it: the original ISBN of every kept book it rewrites, the merged books
+-- themselves and the book each moved copy belonged to.
+CREATE TABLE normalize_isbns_archive_isbns AS
+SELECT b.id, b.isbn
+FROM books b
+INNER JOIN normalized_isbns n ON n.id = b.id
+WHERE b.isbn <> n.isbn AND n.id = n.keep_id;
+
+CREATE TABLE normalize_isbns_archive_books AS
+SELECT b.*
+FROM books b
+INNER JOIN normalized_isbns n ON n.id = b.id
+WHERE n.id <> n.keep_id;
+
+CREATE TABLE normalize_isbns_archive_copies AS
+SELECT bc.id, bc.book_id
+FROM book_copies bc
+INNER JOIN normalized_isbns n ON n.id = bc.book_id
+WHERE n.id <> n.keep_id;
+
+-- Loans reference copies, so they follow their copy to the kept book.
+UPDATE book_copies bc
+SET book_id = n.keep_id
+FROM normalized_isbns n
+WHERE bc.book_id = n.id AND n.id <> n.keep_id;
+
+DELETE FROM books b
+USING normalized_isbns n
+WHERE b.id = n.id AND n.id <> n.keep_id;
+
+UPDATE books b
+SET isbn = n.isbn
+FROM normalized_isbns n
+WHERE b.id = n.id AND b.isbn <> n.isbn;
+
+DROP TABLE normalized_isbns;
+DROP FUNCTION pg_temp.normalize_isbn(text);
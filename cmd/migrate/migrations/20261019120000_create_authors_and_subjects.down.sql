@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS book_subjects;
+DROP TABLE IF EXISTS subjects;
+DROP TABLE IF EXISTS book_authors;
+DROP TABLE IF EXISTS author_aliases;
+DROP TABLE IF EXISTS authors;
@@ -0,0 +1,61 @@
+CREATE TABLE authors (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX authors_name_key ON authors (lower(name));
+
+-- Names of authors that were merged into another author, so books created
+-- later under the old name still resolve to the surviving author.
+CREATE TABLE author_aliases (
+    name TEXT NOT NULL,
+    author_id UUID NOT NULL,
+
+    CONSTRAINT fk_author FOREIGN KEY(author_id) REFERENCES authors(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX author_aliases_name_key ON author_aliases (lower(name));
+
+CREATE TABLE book_authors (
+    book_id UUID NOT NULL,
+    author_id UUID NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (book_id, author_id),
+    CONSTRAINT fk_book FOREIGN KEY(book_id) REFERENCES books(id) ON DELETE CASCADE,
+    CONSTRAINT fk_author FOREIGN KEY(author_id) REFERENCES authors(id) ON DELETE CASCADE
+);
+
+CREATE INDEX book_authors_author_id_idx ON book_authors (author_id);
+
+CREATE TABLE subjects (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX subjects_name_key ON subjects (lower(name));
+
+CREATE TABLE book_subjects (
+    book_id UUID NOT NULL,
+    subject_id UUID NOT NULL,
+
+    PRIMARY KEY (book_id, subject_id),
+    CONSTRAINT fk_book FOREIGN KEY(book_id) REFERENCES books(id) ON DELETE CASCADE,
+    CONSTRAINT fk_subject FOREIGN KEY(subject_id) REFERENCES subjects(id) ON DELETE CASCADE
+);
+
+CREATE INDEX book_subjects_subject_id_idx ON book_subjects (subject_id);
+
+-- Backfill one author per distinct books.author value. books.author is kept
+-- as the display string and is rewritten whenever the relation changes.
+INSERT INTO authors (id, name)
+SELECT gen_random_uuid(), MIN(author)
+FROM books
+GROUP BY lower(author);
+
+INSERT INTO book_authors (book_id, author_id, position)
+SELECT b.id, a.id, 0
+FROM books b
+INNER JOIN authors a ON lower(a.name) = lower(b.author);
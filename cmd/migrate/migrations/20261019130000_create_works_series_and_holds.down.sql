@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS holds;
+DROP TABLE IF EXISTS series_works;
+DROP TABLE IF EXISTS series;
+ALTER TABLE books DROP COLUMN IF EXISTS edition;
+ALTER TABLE books DROP COLUMN IF EXISTS work_id;
+DROP TABLE IF EXISTS works;
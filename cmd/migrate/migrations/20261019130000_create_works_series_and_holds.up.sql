@@ -0,0 +1,63 @@
+CREATE TABLE works (
+    id UUID PRIMARY KEY,
+    title TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE books ADD COLUMN work_id UUID;
+ALTER TABLE books ADD COLUMN edition TEXT;
+ALTER TABLE books ADD CONSTRAINT fk_work FOREIGN KEY(work_id) REFERENCES works(id) ON DELETE SET NULL;
+
+CREATE INDEX books_work_id_idx ON books (work_id);
+
+-- Group existing books into works by title and author, so editions that were
+-- entered separately start out under the same work.
+WITH groups AS (
+    SELECT lower(title) AS title_key, lower(author) AS author_key, MIN(title) AS title, gen_random_uuid() AS id
+    FROM books
+    GROUP BY lower(title), lower(author)
+), inserted AS (
+    INSERT INTO works (id, title)
+    SELECT id, title FROM groups
+)
+UPDATE books b
+SET work_id = g.id
+FROM groups g
+WHERE lower(b.title) = g.title_key AND lower(b.author) = g.author_key;
+
+CREATE TABLE series (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX series_name_key ON series (lower(name));
+
+CREATE TABLE series_works (
+    series_id UUID NOT NULL,
+    work_id UUID NOT NULL,
+    position INT NOT NULL,
+
+    PRIMARY KEY (series_id, work_id),
+    CONSTRAINT fk_series FOREIGN KEY(series_id) REFERENCES series(id) ON DELETE CASCADE,
+    CONSTRAINT fk_work FOREIGN KEY(work_id) REFERENCES works(id) ON DELETE CASCADE
+);
+
+CREATE TABLE holds (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    book_id UUID NOT NULL,
+    work_id UUID,
+    any_edition BOOLEAN NOT NULL DEFAULT FALSE,
+    status TEXT NOT NULL,
+    book_copy_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_user_id FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_book FOREIGN KEY(book_id) REFERENCES books(id) ON DELETE CASCADE,
+    CONSTRAINT fk_work FOREIGN KEY(work_id) REFERENCES works(id) ON DELETE SET NULL,
+    CONSTRAINT fk_book_copy FOREIGN KEY(book_copy_id) REFERENCES book_copies(id) ON DELETE SET NULL
+);
+
+CREATE INDEX holds_book_id_status_idx ON holds (book_id, status);
+CREATE INDEX holds_work_id_status_idx ON holds (work_id, status);
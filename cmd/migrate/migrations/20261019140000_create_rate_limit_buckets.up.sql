@@ -0,0 +1,11 @@
+-- Token buckets shared by API replicas when RATE_LIMIT_BACKEND=postgres.
+-- expires_at is when the bucket will have refilled, after which the row can
+-- be deleted.
+CREATE UNLOGGED TABLE rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_expires_at_idx ON rate_limit_buckets (expires_at);
@@ -0,0 +1,14 @@
+-- Requests sent with an Idempotency-Key. status, header and body hold the
+-- response once the first request completes; until then the key is claimed
+-- until locked_until.
+CREATE TABLE idempotency_keys (
+    key TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    status INTEGER,
+    header JSONB,
+    body BYTEA,
+    locked_until TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
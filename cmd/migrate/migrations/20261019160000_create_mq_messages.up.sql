@@ -0,0 +1,17 @@
+-- Messages queued by the postgres mq broker, one row per queue a message was
+-- routed to. A consumer claims a row by pushing visible_at past now(); if it
+-- is not acknowledged by then, the row is delivered again.
+CREATE TABLE mq_messages (
+    id BIGSERIAL PRIMARY KEY,
+    queue TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    routing_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    headers JSONB NOT NULL,
+    body BYTEA NOT NULL,
+    deliveries INTEGER NOT NULL DEFAULT 0,
+    visible_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX mq_messages_queue_visible_at_idx ON mq_messages (queue, visible_at, id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS loans_book_item_id_open_idx;
+DROP INDEX IF EXISTS book_copies_book_id_idx;
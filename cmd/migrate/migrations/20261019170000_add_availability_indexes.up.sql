@@ -0,0 +1,3 @@
+-- Book availability counts copies per book and looks up their open loans.
+CREATE INDEX book_copies_book_id_idx ON book_copies (book_id);
+CREATE INDEX loans_book_item_id_open_idx ON loans (book_item_id) WHERE return_date IS NULL;
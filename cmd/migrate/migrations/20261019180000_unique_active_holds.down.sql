@@ -0,0 +1,3 @@
+-- Holds cancelled as duplicates by the up migration stay cancelled.
+DROP INDEX IF EXISTS holds_user_id_work_id_active_key;
+DROP INDEX IF EXISTS holds_user_id_book_id_active_key;
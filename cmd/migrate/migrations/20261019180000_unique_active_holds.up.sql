@@ -0,0 +1,44 @@
+-- A patron may have one active (waiting or ready) hold per book, and one
+-- any-edition hold per work; otherwise each extra hold takes another copy.
+-- Existing duplicates are cancelled first, keeping the ready or oldest hold.
+-- Copies set aside for a cancelled duplicate go back on the shelf.
+WITH ranked AS (
+    SELECT id, row_number() OVER (
+        PARTITION BY user_id, book_id
+        ORDER BY (status = 'ready') DESC, created_at, id
+    ) AS n
+    FROM holds
+    WHERE status IN ('waiting', 'ready')
+), cancelled AS (
+    UPDATE holds h
+    SET status = 'cancelled'
+    FROM ranked r
+    WHERE h.id = r.id AND r.n > 1
+    RETURNING h.book_copy_id
+)
+UPDATE book_copies
+SET status = 'available'
+WHERE id IN (SELECT book_copy_id FROM cancelled);
+
+WITH ranked AS (
+    SELECT id, row_number() OVER (
+        PARTITION BY user_id, work_id
+        ORDER BY (status = 'ready') DESC, created_at, id
+    ) AS n
+    FROM holds
+    WHERE status IN ('waiting', 'ready') AND any_edition AND work_id IS NOT NULL
+), cancelled AS (
+    UPDATE holds h
+    SET status = 'cancelled'
+    FROM ranked r
+    WHERE h.id = r.id AND r.n > 1
+    RETURNING h.book_copy_id
+)
+UPDATE book_copies
+SET status = 'available'
+WHERE id IN (SELECT book_copy_id FROM cancelled);
+
+CREATE UNIQUE INDEX holds_user_id_book_id_active_key ON holds (user_id, book_id)
+    WHERE status IN ('waiting', 'ready');
+CREATE UNIQUE INDEX holds_user_id_work_id_active_key ON holds (user_id, work_id)
+    WHERE status IN ('waiting', 'ready') AND any_edition;
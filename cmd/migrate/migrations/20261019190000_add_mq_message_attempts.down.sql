@@ -0,0 +1,13 @@
+-- Dead messages become visible again, so they are delivered once more
+-- rather than lost.
+DROP INDEX IF EXISTS mq_messages_queue_dead_at_idx;
+DROP INDEX IF EXISTS mq_messages_queue_visible_at_idx;
+
+UPDATE mq_messages SET visible_at = now() WHERE dead_at IS NOT NULL;
+
+ALTER TABLE mq_messages
+    DROP COLUMN dead_at,
+    DROP COLUMN last_error;
+ALTER TABLE mq_messages RENAME COLUMN attempts TO deliveries;
+
+CREATE INDEX mq_messages_queue_visible_at_idx ON mq_messages (queue, visible_at, id);
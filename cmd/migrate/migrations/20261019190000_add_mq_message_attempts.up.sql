@@ -0,0 +1,11 @@
+-- Failed messages are retried with a backoff, counted in attempts, and kept
+-- with dead_at set once they fail their last attempt.
+ALTER TABLE mq_messages RENAME COLUMN deliveries TO attempts;
+ALTER TABLE mq_messages
+    ADD COLUMN last_error TEXT,
+    ADD COLUMN dead_at TIMESTAMPTZ;
+
+-- Consumers only look for live messages.
+DROP INDEX IF EXISTS mq_messages_queue_visible_at_idx;
+CREATE INDEX mq_messages_queue_visible_at_idx ON mq_messages (queue, visible_at, id) WHERE dead_at IS NULL;
+CREATE INDEX mq_messages_queue_dead_at_idx ON mq_messages (queue, dead_at) WHERE dead_at IS NOT NULL;
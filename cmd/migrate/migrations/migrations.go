@@ -0,0 +1,62 @@
+// Package migrations embeds the SQL migrations, so cmd/migrate runs from any
+// directory and every binary knows the schema version it was built for.
+package migrations
+
+import (
+	"cmp"
+	"embed"
+	"fmt"
+	"io/fs"
+	"slices"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// List returns the migrations in version order.
+func List() ([]Migration, error) {
+	files, err := fs.Glob(FS, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Migration, 0, len(files))
+
+	for _, file := range files {
+		m, err := source.Parse(file)
+		if err != nil {
+			return nil, fmt.Errorf("parsing migration %s: %w", file, err)
+		}
+
+		list = append(list, Migration{Version: m.Version, Name: m.Identifier})
+	}
+
+	slices.SortFunc(list, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return list, nil
+}
+
+// Latest returns the version of the newest migration, which is the schema
+// version binaries built from this tree expect. Malformed file names are
+// caught by the package tests, so Latest panics on one.
+func Latest() uint {
+	list, err := List()
+	if err != nil {
+		panic(err)
+	}
+
+	if len(list) == 0 {
+		return 0
+	}
+
+	return list[len(list)-1].Version
+}
@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Run("should pair every migration with a rollback", func(t *testing.T) {
+		files, err := fs.Glob(FS, "*.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, file := range files {
+			m, err := source.Parse(file)
+			if err != nil {
+				t.Fatalf("unexpected migration name %s: %v", file, err)
+			}
+
+			pair := strings.Replace(file, "."+string(m.Direction)+".", ".up.", 1)
+			if m.Direction == source.Up {
+				pair = strings.Replace(file, ".up.", ".down.", 1)
+			}
+
+			if _, err := fs.Stat(FS, pair); err != nil {
+				t.Errorf("%s has no matching %s", file, pair)
+			}
+		}
+	})
+
+	t.Run("should list migrations in order with distinct versions", func(t *testing.T) {
+		list, err := List()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 1; i < len(list); i++ {
+			if list[i].Version <= list[i-1].Version {
+				t.Errorf("expected %d to come after %d", list[i].Version, list[i-1].Version)
+			}
+		}
+
+		if latest := Latest(); latest != list[len(list)-1].Version {
+			t.Errorf("expected latest %d, got %d", list[len(list)-1].Version, latest)
+		}
+	})
+}
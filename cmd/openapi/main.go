@@ -0,0 +1,31 @@
+// Command openapi writes the OpenAPI 3.1 document served by the API from the
+// Swagger 2 document swag generates. Run it after swag init; see make docs.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/gfteix/book_loan_system/pkg/openapi"
+)
+
+func main() {
+	in := flag.String("in", "docs/swagger.json", "Swagger 2 document to convert")
+	out := flag.String("out", "docs/openapi.json", "where to write the OpenAPI 3.1 document")
+	flag.Parse()
+
+	swagger, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	doc, err := openapi.Convert(swagger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(*out, doc, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -3,153 +3,142 @@ package main
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
-	"log"
-	"time"
+	"log/slog"
+	"os"
 
+	"github.com/gfteix/book_loan_system/cmd/migrate/migrations"
+	"github.com/gfteix/book_loan_system/internal/reminders"
 	"github.com/gfteix/book_loan_system/pkg/config"
 	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/events"
+	"github.com/gfteix/book_loan_system/pkg/logging"
+	"github.com/gfteix/book_loan_system/pkg/metrics"
 	"github.com/gfteix/book_loan_system/pkg/mq"
+	"github.com/gfteix/book_loan_system/pkg/tracing"
 	"github.com/gfteix/book_loan_system/types"
 	"github.com/google/uuid"
-
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func main() {
-	log.Println("starting reminders")
-
-	db, err := db.NewPostgreSQLStorage(db.DBConfig{
-		DBHost:     config.Envs.DBHost,
-		DBPort:     config.Envs.DBPort,
-		DBUser:     config.Envs.DBUser,
-		DBName:     config.Envs.DBName,
-		DBPassword: config.Envs.DBPassword,
-	})
+	logging.Setup("reminders", config.Envs.LogLevel)
 
-	if err != nil {
-		log.Fatalf("error starting db: %v", err)
-	}
+	// Every event published by this run carries the run's ID, so the emails
+	// it triggers can be traced back to it.
+	ctx := logging.WithRequestID(context.Background(), uuid.NewString())
 
-	loans, err := getLoansToProcess(db)
+	slog.InfoContext(ctx, "starting reminders")
 
+	shutdownTracing, err := tracing.Setup(ctx, "reminders", tracing.Config{Exporter: config.Envs.TraceExporter})
 	if err != nil {
-		log.Fatalf("error getting loans: %v", err)
+		slog.ErrorContext(ctx, "error starting tracing", "error", err)
+		os.Exit(1)
 	}
 
-	qty := len(loans)
+	reg := metrics.NewRegistry()
+	runMetrics := metrics.NewReminders(reg)
 
-	log.Printf("processing %v loans", qty)
-
-	if qty > 0 {
-		process(loans)
-	}
-}
-
-func buildPayload(loan types.Loan, eventType string) []byte {
-	payload, err := json.Marshal(types.Event{
-		Source:  "cmd/reminders",
-		Time:    time.Now().UTC().Format(time.RFC3339),
-		EventId: uuid.NewString(),
-		Type:    eventType,
-		Payload: types.EventPayload{
-			UserId: loan.UserId,
-			LoanId: loan.Id,
-		},
-	})
+	ctx, span := tracing.Tracer().Start(ctx, "reminders.run")
 
+	err = run(ctx, runMetrics)
 	if err != nil {
-		log.Fatalf("error marshiling json %v", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
 
-	return payload
-}
+	span.End()
 
-func publishMessage(ch *amqp.Channel, ctx context.Context, loan types.Loan, queue string, eventType string) {
-	body := buildPayload(loan, eventType)
+	if err := shutdownTracing(ctx); err != nil {
+		slog.ErrorContext(ctx, "error flushing traces", "error", err)
+	}
 
-	err := ch.PublishWithContext(ctx,
-		"",
-		queue,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		})
+	pushMetrics(ctx, reg)
 
 	if err != nil {
-		log.Printf("fail to publish message %v", err)
+		os.Exit(1)
 	}
-
-	log.Printf("sent %s\n", body)
 }
 
-func process(loans []types.Loan) {
-	conn, ch, err := mq.NewRabbitMQClient(mq.MQConfig{
-		Username: config.Envs.MQUsername,
-		Password: config.Envs.MQPassword,
-		Host:     config.Envs.MQHost,
-		Port:     config.Envs.MQPort,
+func run(ctx context.Context, runMetrics *metrics.Reminders) error {
+	pool, err := db.NewPostgreSQLStorage(db.DBConfig{
+		DSN:             config.Envs.DBDSN,
+		DBHost:          config.Envs.DBHost,
+		DBPort:          config.Envs.DBPort,
+		DBUser:          config.Envs.DBUser,
+		DBName:          config.Envs.DBName,
+		DBPassword:      config.Envs.DBPassword,
+		SSLMode:         config.Envs.DBSSLMode,
+		MaxOpenConns:    config.Envs.DBMaxOpenConns,
+		MaxIdleConns:    config.Envs.DBMaxIdleConns,
+		ConnMaxLifetime: config.Envs.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.Envs.DBConnMaxIdleTime,
 	})
 
 	if err != nil {
-		log.Fatalf("error creating mq client %v", err)
+		slog.ErrorContext(ctx, "error starting db", "error", err)
+		return err
 	}
-	defer conn.Close()
-	defer ch.Close()
+	defer pool.Close()
 
-	_, err = mq.DeclareQueue(ch, "LoanEvents")
+	if err := db.CheckSchema(ctx, pool, migrations.Latest()); err != nil {
+		slog.ErrorContext(ctx, "refusing to run against this schema", "error", err)
+		return err
+	}
+
+	loans, err := reminders.DueLoans(ctx, pool)
 
 	if err != nil {
-		log.Fatalf("error declaring queue %v", err)
+		slog.ErrorContext(ctx, "error getting loans", "error", err)
+		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	qty := len(loans)
+	runMetrics.LoansScanned.Add(float64(qty))
 
-	for _, l := range loans {
+	slog.InfoContext(ctx, "processing loans", "count", qty)
 
-		today := time.Now().Truncate(24 * time.Hour)
-		expiringDate := l.ExpiringDate.Truncate(24 * time.Hour)
+	if qty > 0 {
+		return process(ctx, pool, loans, runMetrics)
+	}
 
-		daysDiff := expiringDate.Sub(today).Hours() / 24
+	return nil
+}
 
-		if daysDiff == 0 {
-			publishMessage(ch, ctx, l, "LoanEvents", "LoanExpired")
-		}
+// pushMetrics sends the run's metrics to the Pushgateway, since the job exits
+// before Prometheus could scrape it.
+func pushMetrics(ctx context.Context, reg *prometheus.Registry) {
+	if config.Envs.PushgatewayURL == "" {
+		return
+	}
 
-		if daysDiff > 0 && daysDiff <= 2 {
-			publishMessage(ch, ctx, l, "LoanEvents", "LoanExpiring")
-		}
+	if err := push.New(config.Envs.PushgatewayURL, "reminders").Gatherer(reg).Push(); err != nil {
+		slog.ErrorContext(ctx, "error pushing metrics", "error", err)
 	}
 }
 
-// returns loans that expires today or that will expire in the next two days
-func getLoansToProcess(db *sql.DB) ([]types.Loan, error) {
-	rows, err := db.Query("SELECT id, expiring_date, user_id, book_item_id FROM loans WHERE return_date IS NULL AND expiring_date BETWEEN CURRENT_DATE AND CURRENT_DATE + INTERVAL '2 days'")
+func process(ctx context.Context, db *sql.DB, loans []types.Loan, m *metrics.Reminders) error {
+	broker, err := mq.Open(ctx, mq.Config{
+		Backend: config.Envs.MQBackend,
+		RabbitMQ: mq.MQConfig{
+			Username: config.Envs.MQUsername,
+			Password: config.Envs.MQPassword,
+			Host:     config.Envs.MQHost,
+			Port:     config.Envs.MQPort,
+		},
+		Postgres: mq.PostgresConfig{
+			PollInterval: config.Envs.MQPollInterval,
+			LockTimeout:  config.Envs.MQLockTimeout,
+		},
+		ConnectTimeout: config.Envs.MQConnectTimeout,
+	}, db, events.Topology)
 
 	if err != nil {
-		return nil, err
-	}
-
-	loans := make([]types.Loan, 0)
-
-	for rows.Next() {
-		loan := new(types.Loan)
-
-		err := rows.Scan(
-			&loan.Id,
-			&loan.ExpiringDate,
-			&loan.UserId,
-			&loan.BookCopyId,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-		loans = append(loans, *loan)
+		slog.ErrorContext(ctx, "error connecting to broker", "backend", config.Envs.MQBackend, "error", err)
+		return err
 	}
+	defer broker.Close()
 
-	return loans, nil
+	return reminders.Publish(ctx, broker, loans, m)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gfteix/book_loan_system/cmd/migrate/migrations"
+	"github.com/gfteix/book_loan_system/internal/books"
+	"github.com/gfteix/book_loan_system/internal/loans"
+	"github.com/gfteix/book_loan_system/internal/seed"
+	"github.com/gfteix/book_loan_system/internal/users"
+	"github.com/gfteix/book_loan_system/pkg/config"
+	"github.com/gfteix/book_loan_system/pkg/db"
+)
+
+func main() {
+	name := flag.String("set", "demo", "fixture set: minimal, demo or load-test")
+	size := flag.Int("size", 1, "thousands of users, books and loans in the load-test set")
+	randSeed := flag.Uint64("seed", 1, "random seed for the load-test set")
+
+	flag.Parse()
+
+	var set seed.Set
+
+	switch *name {
+	case "minimal":
+		set = seed.Minimal()
+	case "demo":
+		set = seed.Demo(time.Now())
+	case "load-test":
+		if *size < 1 {
+			log.Fatalf("invalid size %d", *size)
+		}
+
+		set = seed.LoadTest(time.Now(), *size*1000, *randSeed)
+	default:
+		log.Fatalf("unknown fixture set %q", *name)
+	}
+
+	pool, err := db.NewPostgreSQLStorage(db.DBConfig{
+		DSN:             config.Envs.DBDSN,
+		DBHost:          config.Envs.DBHost,
+		DBPort:          config.Envs.DBPort,
+		DBUser:          config.Envs.DBUser,
+		DBName:          config.Envs.DBName,
+		DBPassword:      config.Envs.DBPassword,
+		SSLMode:         config.Envs.DBSSLMode,
+		MaxOpenConns:    config.Envs.DBMaxOpenConns,
+		MaxIdleConns:    config.Envs.DBMaxIdleConns,
+		ConnMaxLifetime: config.Envs.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.Envs.DBConnMaxIdleTime,
+	})
+
+	if err != nil {
+		log.Fatalf("error starting db: %v", err)
+	}
+	defer pool.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := db.CheckSchema(ctx, pool, migrations.Latest()); err != nil {
+		log.Fatalf("refusing to seed this schema: %v", err)
+	}
+
+	empty, err := seed.Empty(ctx, pool)
+	if err != nil {
+		log.Fatalf("error checking for existing data: %v", err)
+	}
+
+	if !empty {
+		log.Fatal("the database already has users, books or loans; seed an empty database")
+	}
+
+	seeder := seed.NewSeeder(
+		users.NewRepository(pool, config.Envs.DBQueryTimeout),
+		books.NewRepository(pool, config.Envs.DBQueryTimeout),
+		loans.NewRepository(pool, config.Envs.DBQueryTimeout),
+	)
+
+	start := time.Now()
+
+	if err := seeder.Insert(ctx, set); err != nil {
+		log.Fatalf("error seeding %s: %v", *name, err)
+	}
+
+	log.Printf("seeded %s: %d users, %d books, %d copies and %d loans in %s",
+		*name, len(set.Users), len(set.Books), len(set.Copies), len(set.Loans), time.Since(start).Round(time.Millisecond))
+}
@@ -22,9 +22,9 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/books": {
+        "/authors": {
             "get": {
-                "description": "Retrieves a list of books with optional filters",
+                "description": "Retrieves authors with the number of books each one has",
                 "consumes": [
                     "application/json"
                 ],
@@ -32,26 +32,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "books"
+                    "authors"
                 ],
-                "summary": "Get books with filters",
+                "summary": "Get authors",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Filter by title",
-                        "name": "title",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by author",
-                        "name": "author",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by ISBN",
-                        "name": "isbn",
+                        "description": "Filter by part of the author name",
+                        "name": "name",
                         "in": "query"
                     }
                 ],
@@ -61,20 +49,22 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/types.Book"
+                                "$ref": "#/definitions/types.Author"
                             }
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Adds a new book to the library system",
+            }
+        },
+        "/authors/{id}": {
+            "get": {
+                "description": "Retrieves an author by its ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -82,42 +72,49 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "books"
+                    "authors"
                 ],
-                "summary": "Create a new book",
+                "summary": "Get an author by ID",
                 "parameters": [
                     {
-                        "description": "Book details",
-                        "name": "book",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/types.CreateBookPayload"
-                        }
+                        "type": "string",
+                        "description": "Author ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Author"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
             }
         },
-        "/books/{bookId}/items/{itemId}": {
+        "/authors/{id}/books": {
             "get": {
-                "description": "Retrieves a specific book item by its ID",
+                "description": "Retrieves every book the author wrote or co-wrote",
                 "consumes": [
                     "application/json"
                 ],
@@ -125,21 +122,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "books"
+                    "authors"
                 ],
-                "summary": "Get a book item by ID",
+                "summary": "Get books by author",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Book ID",
-                        "name": "bookId",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Book Item ID",
-                        "name": "itemId",
+                        "description": "Author ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -148,27 +138,30 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.BookCopy"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Book"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
             }
         },
-        "/books/{id}": {
-            "get": {
-                "description": "Retrieves a book by its ID",
+        "/authors/{id}/merge": {
+            "post": {
+                "description": "Moves the books of the source authors to this author and deletes the sources. Source names are kept as aliases, so new books created under them are linked to this author.",
                 "consumes": [
                     "application/json"
                 ],
@@ -176,43 +169,64 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "books"
+                    "authors"
                 ],
-                "summary": "Get a book by ID",
+                "summary": "Merge authors",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Book ID",
+                        "description": "ID of the author to keep",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Authors to merge into this one",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.MergeAuthorsPayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.Book"
+                            "$ref": "#/definitions/types.Author"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
             }
         },
-        "/books/{id}/items": {
+        "/books": {
             "get": {
-                "description": "Retrieves all items belonging to a book by its ID",
+                "description": "Retrieves a list of books with optional filters",
                 "consumes": [
                     "application/json"
                 ],
@@ -222,14 +236,55 @@ const docTemplate = `{
                 "tags": [
                     "books"
                 ],
-                "summary": "Get items of a book",
+                "summary": "Get books with filters",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Book ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Filter by title",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by author name",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by author ID",
+                        "name": "authorId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by subject name",
+                        "name": "subject",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by subject ID",
+                        "name": "subjectId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by work ID, listing every edition of the work",
+                        "name": "workId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by ISBN (ISBN-10 or ISBN-13, hyphens allowed)",
+                        "name": "isbn",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only books with (true) or without (false) an available copy",
+                        "name": "available",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -238,20 +293,26 @@ const docTemplate = `{
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/types.BookCopy"
+                                "$ref": "#/definitions/types.Book"
                             }
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
             },
             "post": {
-                "description": "Adds a new book item to a book",
+                "description": "Adds a new book to the library system",
                 "consumes": [
                     "application/json"
                 ],
@@ -261,47 +322,67 @@ const docTemplate = `{
                 "tags": [
                     "books"
                 ],
-                "summary": "Create a book item",
+                "summary": "Create a new book",
                 "parameters": [
                     {
-                        "description": "Book item details",
-                        "name": "bookCopy",
+                        "description": "Book details",
+                        "name": "book",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/types.CreateBookCopyPayload"
+                            "$ref": "#/definitions/types.CreateBookPayload"
                         }
                     },
+                    {
+                        "type": "boolean",
+                        "description": "Fill empty fields from the metadata source by ISBN",
+                        "name": "enrich",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Book ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Created"
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.Book"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created book"
+                            }
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
             }
         },
-        "/loans": {
-            "get": {
-                "description": "Retrieves loans with optional filters",
+        "/books/lookup": {
+            "post": {
+                "description": "Fetches title, authors, page count and cover from the metadata source, to prefill a new book",
                 "consumes": [
                     "application/json"
                 ],
@@ -309,55 +390,57 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "loans"
+                    "books"
                 ],
-                "summary": "Get Loans",
+                "summary": "Look up book metadata by ISBN",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Filter by User ID",
-                        "name": "userId",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by Loan Status",
-                        "name": "status",
-                        "in": "query"
+                        "description": "ISBN to look up",
+                        "name": "book",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.LookupBookPayload"
+                        }
                     },
                     {
                         "type": "string",
-                        "description": "Filter by Book Item ID",
-                        "name": "bookCopyId",
-                        "in": "query"
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/types.Loan"
-                            }
+                            "$ref": "#/definitions/types.Book"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Creates a book loan",
+            }
+        },
+        "/books/{id}": {
+            "get": {
+                "description": "Retrieves a book by its ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -365,42 +448,43 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "loans"
+                    "books"
                 ],
-                "summary": "Creates a Loan",
+                "summary": "Get a book by ID",
                 "parameters": [
                     {
-                        "description": "Loan that needs to be created",
-                        "name": "user",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/types.CreateLoanPayload"
-                        }
+                        "type": "string",
+                        "description": "Book ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK"
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Book"
+                        }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
             }
         },
-        "/loans/{id}": {
+        "/books/{id}/items": {
             "get": {
-                "description": "Retrieves a loan by ID",
+                "description": "Retrieves all items belonging to a book by its ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -408,13 +492,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "loans"
+                    "books"
                 ],
-                "summary": "Get a Loan",
+                "summary": "Get items of a book",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Loan ID",
+                        "description": "Book ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -424,25 +508,1108 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.Loan"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.BookCopy"
+                            }
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/users": {
+            },
+            "post": {
+                "description": "Adds a new book item to a book",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "books"
+                ],
+                "summary": "Create a book item",
+                "parameters": [
+                    {
+                        "description": "Book item details",
+                        "name": "bookCopy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.CreateBookCopyPayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Book ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.BookCopy"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created book item"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/{id}/items/{itemId}": {
+            "get": {
+                "description": "Retrieves a specific book item by its ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "books"
+                ],
+                "summary": "Get a book item by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Book ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Book Item ID",
+                        "name": "itemId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.BookCopy"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/export/{resource}": {
+            "get": {
+                "description": "Streams books, copies, users or loans as CSV, NDJSON or (books only) MARCXML. Accepts the same filters as the list endpoints.",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson",
+                    "application/marcxml+xml"
+                ],
+                "tags": [
+                    "export"
+                ],
+                "summary": "Export a resource",
+                "parameters": [
+                    {
+                        "enum": [
+                            "books",
+                            "copies",
+                            "users",
+                            "loans"
+                        ],
+                        "type": "string",
+                        "description": "Resource to export",
+                        "name": "resource",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "ndjson",
+                            "marcxml"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Export format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter books by title",
+                        "name": "title",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter books by author",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter books by ISBN",
+                        "name": "isbn",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter copies by book ID",
+                        "name": "bookId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter loans by user ID",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter loans by book copy ID",
+                        "name": "bookCopyId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter copies or loans by status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Reports that the process is up. It does not check dependencies.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.HealthStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/holds": {
+            "get": {
+                "description": "Retrieves holds in the order they were placed",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "holds"
+                ],
+                "summary": "Get holds",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by user ID",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by book ID",
+                        "name": "bookId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by work ID",
+                        "name": "workId",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "waiting",
+                            "ready",
+                            "cancelled",
+                            "fulfilled"
+                        ],
+                        "type": "string",
+                        "description": "Filter by status",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Hold"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Places a hold on a book. A free copy is set aside right away when there is one (\"ready\"); otherwise the hold is \"waiting\". With anyEdition the copy may come from any edition of the book's work.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "holds"
+                ],
+                "summary": "Place a hold",
+                "parameters": [
+                    {
+                        "description": "Hold details",
+                        "name": "hold",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.PlaceHoldPayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.Hold"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created hold"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/holds/{id}": {
+            "get": {
+                "description": "Retrieves a hold by its ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "holds"
+                ],
+                "summary": "Get a hold by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hold ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Hold"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/holds/{id}/cancel": {
+            "post": {
+                "description": "Cancels a hold. A copy set aside for it goes to the next waiting hold that accepts it, or back on the shelf.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "holds"
+                ],
+                "summary": "Cancel a hold",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hold ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/loans": {
+            "get": {
+                "description": "Retrieves loans with optional filters",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "loans"
+                ],
+                "summary": "Get Loans",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by User ID",
+                        "name": "userId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by Loan Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by Book Item ID",
+                        "name": "bookCopyId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Loan"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a book loan",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "loans"
+                ],
+                "summary": "Creates a Loan",
+                "parameters": [
+                    {
+                        "description": "Loan that needs to be created",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.CreateLoanPayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.Loan"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created loan"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Copy is lent, or on hold for another user",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/loans/{id}": {
+            "get": {
+                "description": "Retrieves a loan by ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "loans"
+                ],
+                "summary": "Get a Loan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Loan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Loan"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/loans/{id}/return": {
+            "post": {
+                "description": "Records the loan as returned. The copy goes to the oldest waiting hold for its book or work, or back on the shelf.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "loans"
+                ],
+                "summary": "Return a Loan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Loan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Loan"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Loan is already returned",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Reports whether the API can serve traffic: Postgres, and RabbitMQ when configured, must be reachable and the server must not be shutting down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.HealthStatus"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/types.HealthStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/series": {
+            "get": {
+                "description": "Retrieves all series",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "series"
+                ],
+                "summary": "Get series",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Series"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a series that works can be added to in order",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "series"
+                ],
+                "summary": "Create a series",
+                "parameters": [
+                    {
+                        "description": "Series details",
+                        "name": "series",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.CreateSeriesPayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.Series"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created series"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/series/{id}": {
+            "get": {
+                "description": "Retrieves a series with its works in reading order",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "series"
+                ],
+                "summary": "Get a series by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Series ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Series"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/series/{id}/works/{workId}": {
+            "put": {
+                "description": "Adds the work to the series at the given position, or moves it there if it is already part of it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "series"
+                ],
+                "summary": "Add a work to a series",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Series ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Work ID",
+                        "name": "workId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Position in the series",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.SetSeriesWorkPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subjects": {
+            "get": {
+                "description": "Retrieves subjects with the number of books filed under each one",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subjects"
+                ],
+                "summary": "Get subjects",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by part of the subject name",
+                        "name": "name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Subject"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subjects/{id}": {
+            "get": {
+                "description": "Retrieves a subject by its ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subjects"
+                ],
+                "summary": "Get a subject by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subject ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Subject"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subjects/{id}/books": {
+            "get": {
+                "description": "Retrieves every book filed under the subject",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subjects"
+                ],
+                "summary": "Get books by subject",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subject ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.Book"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users": {
+            "get": {
+                "description": "Retrieves users",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get users",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/types.User"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            },
             "post": {
                 "description": "Creates an User",
                 "consumes": [
@@ -464,30 +1631,51 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/types.CreateUserPayload"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK"
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.User"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created user"
+                            }
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
             }
         },
-        "/users/": {
+        "/users/{id}": {
             "get": {
-                "description": "Retrieves users",
+                "description": "Retrieves user details by their unique ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -497,29 +1685,187 @@ const docTemplate = `{
                 "tags": [
                     "users"
                 ],
-                "summary": "Get users",
+                "summary": "Get a user by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/works": {
+            "get": {
+                "description": "Retrieves works, the groups that editions of the same title belong to",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "works"
+                ],
+                "summary": "Get works",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by part of the title",
+                        "name": "title",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/types.User"
+                                "$ref": "#/definitions/types.Work"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a work that editions can then be filed under",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "works"
+                ],
+                "summary": "Create a work",
+                "parameters": [
+                    {
+                        "description": "Work details",
+                        "name": "work",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/types.CreateWorkPayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Makes retries safe: requests with the same key get the first response",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/types.Work"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created work"
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/works/{id}": {
+            "get": {
+                "description": "Retrieves a work with all of its editions",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "works"
+                ],
+                "summary": "Get a work by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Work ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/types.Work"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
             }
         },
-        "/users/{id}": {
+        "/works/{id}/availability": {
             "get": {
-                "description": "Retrieves user details by their unique ID",
+                "description": "Counts copies across every edition of the work, for patrons who accept any edition",
                 "consumes": [
                     "application/json"
                 ],
@@ -527,13 +1873,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "works"
                 ],
-                "summary": "Get a user by ID",
+                "summary": "Get availability of a work",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "User ID",
+                        "type": "string",
+                        "description": "Work ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -543,19 +1889,25 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/types.User"
+                            "$ref": "#/definitions/types.WorkAvailability"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
                         }
                     },
                     "404": {
                         "description": "Not Found",
                         "schema": {
-                            "$ref": "#/definitions/types.APIError"
+                            "$ref": "#/definitions/types.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/types.Problem"
                         }
                     }
                 }
@@ -563,10 +1915,16 @@ const docTemplate = `{
         }
     },
     "definitions": {
-        "types.APIError": {
+        "types.Author": {
             "type": "object",
             "properties": {
-                "error": {
+                "bookCount": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
                     "type": "string"
                 }
             }
@@ -577,12 +1935,27 @@ const docTemplate = `{
                 "author": {
                     "type": "string"
                 },
+                "authors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.Author"
+                    }
+                },
+                "availability": {
+                    "$ref": "#/definitions/types.BookAvailability"
+                },
+                "coverUrl": {
+                    "type": "string"
+                },
                 "createdAt": {
                     "type": "string"
                 },
                 "description": {
                     "type": "string"
                 },
+                "edition": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
@@ -592,8 +1965,37 @@ const docTemplate = `{
                 "numberOfPages": {
                     "type": "integer"
                 },
+                "subjects": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.Subject"
+                    }
+                },
                 "title": {
                     "type": "string"
+                },
+                "workId": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.BookAvailability": {
+            "type": "object",
+            "properties": {
+                "availableCopies": {
+                    "type": "integer"
+                },
+                "expectedReturnDate": {
+                    "type": "string"
+                },
+                "onHoldCopies": {
+                    "type": "integer"
+                },
+                "onLoanCopies": {
+                    "type": "integer"
+                },
+                "totalCopies": {
+                    "type": "integer"
                 }
             }
         },
@@ -622,43 +2024,96 @@ const docTemplate = `{
         },
         "types.CreateBookCopyPayload": {
             "type": "object",
+            "required": [
+                "bookId"
+            ],
             "properties": {
                 "bookId": {
                     "type": "string"
                 },
                 "condition": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 100
                 },
                 "location": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 200
                 },
                 "status": {
-                    "type": "string"
+                    "description": "Status defaults to available.",
+                    "type": "string",
+                    "enum": [
+                        "available",
+                        "on_hold",
+                        "lent"
+                    ]
                 }
             }
         },
         "types.CreateBookPayload": {
             "type": "object",
+            "required": [
+                "isbn",
+                "title"
+            ],
             "properties": {
                 "author": {
+                    "description": "Author is kept for single-author clients; Authors takes precedence.",
+                    "type": "string",
+                    "maxLength": 200
+                },
+                "authors": {
+                    "type": "array",
+                    "maxItems": 50,
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "coverUrl": {
                     "type": "string"
                 },
                 "description": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 10000
+                },
+                "edition": {
+                    "type": "string",
+                    "maxLength": 200
                 },
                 "isbn": {
                     "type": "string"
                 },
                 "numberOfPages": {
-                    "type": "integer"
+                    "type": "integer",
+                    "maximum": 100000,
+                    "minimum": 0
+                },
+                "subjects": {
+                    "type": "array",
+                    "maxItems": 50,
+                    "items": {
+                        "type": "string"
+                    }
                 },
                 "title": {
+                    "type": "string",
+                    "maxLength": 500
+                },
+                "workId": {
+                    "description": "WorkId files the book as an edition of an existing work; when empty a\nnew work is created from the title.",
                     "type": "string"
                 }
             }
         },
         "types.CreateLoanPayload": {
             "type": "object",
+            "required": [
+                "bookCopyId",
+                "expiringDate",
+                "loanDate",
+                "status",
+                "userId"
+            ],
             "properties": {
                 "bookCopyId": {
                     "type": "string"
@@ -670,13 +2125,22 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "status": {
-                    "type": "string"
+                    "type": "string",
+                    "maxLength": 50
                 },
                 "userId": {
                     "type": "string"
                 }
             }
         },
+        "types.CreateSeriesPayload": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
         "types.CreateUserPayload": {
             "type": "object",
             "required": [
@@ -692,6 +2156,91 @@ const docTemplate = `{
                 }
             }
         },
+        "types.CreateWorkPayload": {
+            "type": "object",
+            "properties": {
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.EditionAvailability": {
+            "type": "object",
+            "properties": {
+                "availableCopies": {
+                    "type": "integer"
+                },
+                "bookId": {
+                    "type": "string"
+                },
+                "edition": {
+                    "type": "string"
+                },
+                "isbn": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "totalCopies": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.HealthStatus": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.Hold": {
+            "type": "object",
+            "properties": {
+                "anyEdition": {
+                    "type": "boolean"
+                },
+                "bookCopyId": {
+                    "type": "string"
+                },
+                "bookId": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
+                },
+                "workId": {
+                    "type": "string"
+                }
+            }
+        },
         "types.Loan": {
             "type": "object",
             "properties": {
@@ -721,6 +2270,120 @@ const docTemplate = `{
                 }
             }
         },
+        "types.LookupBookPayload": {
+            "type": "object",
+            "properties": {
+                "isbn": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.MergeAuthorsPayload": {
+            "type": "object",
+            "properties": {
+                "sourceIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "types.PlaceHoldPayload": {
+            "type": "object",
+            "properties": {
+                "anyEdition": {
+                    "description": "AnyEdition lets the hold be filled by any edition of the book's work.",
+                    "type": "boolean"
+                },
+                "bookId": {
+                    "type": "string"
+                },
+                "userId": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Errors lists the offending payload fields of a validation problem.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.FieldError"
+                    }
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.Series": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "works": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.SeriesWork"
+                    }
+                }
+            }
+        },
+        "types.SeriesWork": {
+            "type": "object",
+            "properties": {
+                "position": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "workId": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.SetSeriesWorkPayload": {
+            "type": "object",
+            "properties": {
+                "position": {
+                    "type": "integer"
+                }
+            }
+        },
+        "types.Subject": {
+            "type": "object",
+            "properties": {
+                "bookCount": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
         "types.User": {
             "type": "object",
             "properties": {
@@ -737,6 +2400,49 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "types.Work": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "editions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.Book"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "types.WorkAvailability": {
+            "type": "object",
+            "properties": {
+                "availableCopies": {
+                    "type": "integer"
+                },
+                "editions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/types.EditionAvailability"
+                    }
+                },
+                "totalCopies": {
+                    "type": "integer"
+                },
+                "waitingHolds": {
+                    "type": "integer"
+                },
+                "workId": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -745,7 +2451,7 @@ const docTemplate = `{
 var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
 	Host:             "localhost:8080",
-	BasePath:         "/",
+	BasePath:         "/v1",
 	Schemes:          []string{},
 	Title:            "Book Loan API",
 	Description:      "API to manage book loans",
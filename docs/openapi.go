@@ -0,0 +1,9 @@
+package docs
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3.1 document for the API, converted from
+// swagger.json by cmd/openapi.
+//
+//go:embed openapi.json
+var OpenAPI []byte
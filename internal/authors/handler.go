@@ -0,0 +1,175 @@
+package authors
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gfteix/book_loan_system/pkg/utils"
+	"github.com/gfteix/book_loan_system/types"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	repository     types.AuthorRepository
+	bookRepository types.BookRepository
+}
+
+func NewHandler(repository types.AuthorRepository, bookRepository types.BookRepository) *Handler {
+	return &Handler{repository: repository, bookRepository: bookRepository}
+}
+
+func (h *Handler) RegisterRoutes(router types.Router) {
+	router.HandleFunc("GET /authors", h.handleGetAuthors)
+	router.HandleFunc("GET /authors/{id}", h.handleGetAuthorById)
+	router.HandleFunc("GET /authors/{id}/books", h.handleGetAuthorBooks)
+	router.HandleFunc("POST /authors/{id}/merge", h.handleMergeAuthors)
+}
+
+// handleGetAuthors godoc
+// @Summary Get authors
+// @Description Retrieves authors with the number of books each one has
+// @Tags authors
+// @Accept  json
+// @Produce  json
+// @Param name query string false "Filter by part of the author name"
+// @Success 200 {array} types.Author
+// @Failure 500 {object} types.Problem
+// @Router /authors [get]
+func (h *Handler) handleGetAuthors(w http.ResponseWriter, r *http.Request) {
+	filter := map[string]string{
+		"name": r.URL.Query().Get("name"),
+	}
+
+	authors, err := h.repository.GetAuthors(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetAuthors", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, authors)
+}
+
+// handleGetAuthorById godoc
+// @Summary Get an author by ID
+// @Description Retrieves an author by its ID
+// @Tags authors
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Author ID"
+// @Success 200 {object} types.Author
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /authors/{id} [get]
+func (h *Handler) handleGetAuthorById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	author, err := h.repository.GetAuthorById(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetAuthorById", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, author)
+}
+
+// handleGetAuthorBooks godoc
+// @Summary Get books by author
+// @Description Retrieves every book the author wrote or co-wrote
+// @Tags authors
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Author ID"
+// @Success 200 {array} types.Book
+// @Failure 400 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /authors/{id}/books [get]
+func (h *Handler) handleGetAuthorBooks(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	books, err := h.bookRepository.GetBooks(r.Context(), map[string]string{"authorId": id})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetBooks", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, books)
+}
+
+// handleMergeAuthors godoc
+// @Summary Merge authors
+// @Description Moves the books of the source authors to this author and deletes the sources. Source names are kept as aliases, so new books created under them are linked to this author.
+// @Tags authors
+// @Accept  json
+// @Produce  json
+// @Param id path string true "ID of the author to keep"
+// @Param payload body types.MergeAuthorsPayload true "Authors to merge into this one"
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 200 {object} types.Author
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /authors/{id}/merge [post]
+func (h *Handler) handleMergeAuthors(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	var payload types.MergeAuthorsPayload
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	sourceIds := make([]string, 0, len(payload.SourceIds))
+	seen := map[string]bool{id: true}
+
+	for _, sourceId := range payload.SourceIds {
+		sourceId = strings.TrimSpace(sourceId)
+
+		if err := uuid.Validate(sourceId); err != nil {
+			utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), map[string]string{"sourceIds": fmt.Sprintf("invalid id %q", sourceId)})
+			return
+		}
+
+		if !seen[sourceId] {
+			seen[sourceId] = true
+			sourceIds = append(sourceIds, sourceId)
+		}
+	}
+
+	if len(sourceIds) == 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), map[string]string{"sourceIds": "at least one other author is required"})
+		return
+	}
+
+	author, err := h.repository.MergeAuthors(r.Context(), id, sourceIds)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on MergeAuthors", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, author)
+}
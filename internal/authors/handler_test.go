@@ -0,0 +1,140 @@
+package authors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+type mockAuthorRepository struct {
+	GetAuthorsFunc    func(ctx context.Context, filter map[string]string) ([]types.Author, error)
+	GetAuthorByIdFunc func(ctx context.Context, id string) (*types.Author, error)
+	MergeAuthorsFunc  func(ctx context.Context, targetId string, sourceIds []string) (*types.Author, error)
+}
+
+func (m *mockAuthorRepository) GetAuthors(ctx context.Context, filter map[string]string) ([]types.Author, error) {
+	if m.GetAuthorsFunc != nil {
+		return m.GetAuthorsFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+func (m *mockAuthorRepository) GetAuthorById(ctx context.Context, id string) (*types.Author, error) {
+	if m.GetAuthorByIdFunc != nil {
+		return m.GetAuthorByIdFunc(ctx, id)
+	}
+	return nil, ErrAuthorNotFound
+}
+
+func (m *mockAuthorRepository) MergeAuthors(ctx context.Context, targetId string, sourceIds []string) (*types.Author, error) {
+	if m.MergeAuthorsFunc != nil {
+		return m.MergeAuthorsFunc(ctx, targetId, sourceIds)
+	}
+	return nil, ErrAuthorNotFound
+}
+
+type mockBookRepository struct {
+	types.BookRepository
+	GetBooksFunc func(ctx context.Context, filter map[string]string) ([]types.Book, error)
+}
+
+func (m *mockBookRepository) GetBooks(ctx context.Context, filter map[string]string) ([]types.Book, error) {
+	if m.GetBooksFunc != nil {
+		return m.GetBooksFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+const targetId = "123e4567-e89b-12d3-a456-426614174000"
+const sourceId = "123e4567-e89b-12d3-a456-426614174001"
+
+func TestAuthorHandler(t *testing.T) {
+	repository := &mockAuthorRepository{}
+	bookRepository := &mockBookRepository{}
+	handler := NewHandler(repository, bookRepository)
+
+	serve := func(t *testing.T, method string, path string, body []byte) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should fetch books by author id", func(t *testing.T) {
+		var gotFilter map[string]string
+
+		bookRepository.GetBooksFunc = func(ctx context.Context, filter map[string]string) ([]types.Book, error) {
+			gotFilter = filter
+			return []types.Book{{Title: "Crime and Punishment"}}, nil
+		}
+
+		rr := serve(t, http.MethodGet, fmt.Sprintf("/authors/%s/books", targetId), nil)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if gotFilter["authorId"] != targetId {
+			t.Errorf("expected authorId filter %v, got %v", targetId, gotFilter["authorId"])
+		}
+	})
+
+	t.Run("should return 404 for an unknown author", func(t *testing.T) {
+		rr := serve(t, http.MethodGet, fmt.Sprintf("/authors/%s", targetId), nil)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should merge source authors into the target", func(t *testing.T) {
+		var gotSources []string
+
+		repository.MergeAuthorsFunc = func(ctx context.Context, target string, sourceIds []string) (*types.Author, error) {
+			gotSources = sourceIds
+			return &types.Author{Id: target, Name: "Fyodor Dostoyevsky", BookCount: 2}, nil
+		}
+
+		body, _ := json.Marshal(types.MergeAuthorsPayload{SourceIds: []string{sourceId, sourceId, targetId}})
+		rr := serve(t, http.MethodPost, fmt.Sprintf("/authors/%s/merge", targetId), body)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if len(gotSources) != 1 || gotSources[0] != sourceId {
+			t.Errorf("expected deduplicated sources without the target, got %v", gotSources)
+		}
+	})
+
+	t.Run("should fail to merge with invalid source ids", func(t *testing.T) {
+		body, _ := json.Marshal(types.MergeAuthorsPayload{SourceIds: []string{"not-a-uuid"}})
+		rr := serve(t, http.MethodPost, fmt.Sprintf("/authors/%s/merge", targetId), body)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail to merge an author into itself only", func(t *testing.T) {
+		body, _ := json.Marshal(types.MergeAuthorsPayload{SourceIds: []string{targetId}})
+		rr := serve(t, http.MethodPost, fmt.Sprintf("/authors/%s/merge", targetId), body)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
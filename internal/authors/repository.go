@@ -0,0 +1,168 @@
+package authors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+var ErrAuthorNotFound = errs.NotFound("author not found")
+var ErrSourceNotFound = errs.NotFound("author to merge not found")
+
+type Repository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewRepository(db *sql.DB, timeout time.Duration) *Repository {
+	return &Repository{db: db, timeout: timeout}
+}
+
+const authorColumns = "a.id, a.name, (SELECT COUNT(*) FROM book_authors ba WHERE ba.author_id = a.id)"
+
+func scanRowIntoAuthor(rows *sql.Rows) (*types.Author, error) {
+	author := new(types.Author)
+	err := rows.Scan(
+		&author.Id,
+		&author.Name,
+		&author.BookCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return author, nil
+}
+
+func (r *Repository) GetAuthors(ctx context.Context, filters map[string]string) ([]types.Author, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	q := "SELECT " + authorColumns + " FROM authors a"
+
+	args := make([]interface{}, 0)
+
+	if v := filters["name"]; v != "" {
+		args = append(args, "%"+v+"%")
+		q = fmt.Sprintf("%v WHERE a.name ILIKE $%v", q, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, q+" ORDER BY a.name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := make([]types.Author, 0)
+
+	for rows.Next() {
+		author, err := scanRowIntoAuthor(rows)
+		if err != nil {
+			return nil, err
+		}
+		authors = append(authors, *author)
+	}
+
+	return authors, rows.Err()
+}
+
+func (r *Repository) GetAuthorById(ctx context.Context, id string) (*types.Author, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+authorColumns+" FROM authors a WHERE a.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanRowIntoAuthor(rows)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrAuthorNotFound
+}
+
+// MergeAuthors moves every book of the source authors to the target author,
+// keeps the source names as aliases of the target and deletes the sources.
+func (r *Repository) MergeAuthors(ctx context.Context, targetId string, sourceIds []string) (*types.Author, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the target too, so a concurrent merge or delete cannot remove it
+	// before this one commits.
+	err = tx.QueryRowContext(ctx, "SELECT id FROM authors WHERE id = $1 FOR UPDATE", targetId).Scan(&targetId)
+	if err == sql.ErrNoRows {
+		return nil, ErrAuthorNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sourceId := range sourceIds {
+		if sourceId == targetId {
+			continue
+		}
+
+		var name string
+		err := tx.QueryRowContext(ctx, "SELECT name FROM authors WHERE id = $1 FOR UPDATE", sourceId).Scan(&name)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, sourceId)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		statements := []struct {
+			query string
+			args  []any
+		}{
+			// Keep the source's position so co-author ordering is preserved.
+			{`INSERT INTO book_authors (book_id, author_id, position)
+				SELECT book_id, $1, position FROM book_authors WHERE author_id = $2
+				ON CONFLICT DO NOTHING`, []any{targetId, sourceId}},
+			{"UPDATE author_aliases SET author_id = $1 WHERE author_id = $2", []any{targetId, sourceId}},
+			// Only the source's books change; the target's other books keep
+			// their display string.
+			{`UPDATE books b SET author = (
+				SELECT string_agg(a.name, ', ' ORDER BY ba.position)
+				FROM book_authors ba INNER JOIN authors a ON a.id = ba.author_id
+				WHERE ba.book_id = b.id AND ba.author_id <> $1)
+			WHERE b.id IN (SELECT book_id FROM book_authors WHERE author_id = $1)`, []any{sourceId}},
+			{"DELETE FROM authors WHERE id = $1", []any{sourceId}},
+		}
+
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement.query, statement.args...); err != nil {
+				return nil, err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO author_aliases (name, author_id) VALUES ($1, $2) ON CONFLICT ((lower(name))) DO UPDATE SET author_id = $2",
+			name, targetId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetAuthorById(ctx, targetId)
+}
@@ -1,30 +1,41 @@
 package books
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/pkg/isbn"
+	"github.com/gfteix/book_loan_system/pkg/openlibrary"
 	"github.com/gfteix/book_loan_system/pkg/utils"
 	"github.com/gfteix/book_loan_system/types"
-	"github.com/go-playground/validator"
-	"github.com/google/uuid"
 )
 
+var ErrLookupNotConfigured = errs.NotImplemented("metadata lookup is not configured")
+
 type Handler struct {
 	repository types.BookRepository
+	lookup     types.BookLookup
 }
 
-func NewHandler(repository types.BookRepository) *Handler {
-	return &Handler{repository: repository}
+// NewHandler creates the books handler. lookup may be nil, in which case
+// metadata lookup and enrichment are disabled.
+func NewHandler(repository types.BookRepository, lookup types.BookLookup) *Handler {
+	return &Handler{repository: repository, lookup: lookup}
 }
 
-func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+func (h *Handler) RegisterRoutes(router types.Router) {
 	router.HandleFunc("POST /books", h.handleCreateBook)
+	router.HandleFunc("POST /books/lookup", h.handleLookupBook)
 	router.HandleFunc("GET /books", h.handleGetBooks)
 	router.HandleFunc("GET /books/{id}", h.handleGetBookById)
 	router.HandleFunc("POST /books/{id}/items", h.handleCreateBookCopy)
 	router.HandleFunc("GET /books/{id}/items", h.handleGetBookCopies)
+	router.HandleFunc("GET /books/{id}/items/{itemId}", h.handleGetBookCopyById)
 
 }
 
@@ -36,20 +47,16 @@ func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 // @Produce  json
 // @Param id path string true "Book ID"
 // @Success 200 {object} types.Book
-// @Failure 404 {object} types.APIError
-// @Failure 500 {object} types.APIError
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
 // @Router /books/{id} [get]
 func (h *Handler) handleGetBookById(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
-	book, err := h.repository.GetBookById(id)
+	book, err := h.repository.GetBookById(r.Context(), id)
 	if err != nil {
-		log.Printf("error on GetBookById %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
-	}
-	if book == nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("book with id %s not found", id))
+		slog.ErrorContext(r.Context(), "error on GetBookById", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, book)
@@ -62,23 +69,43 @@ func (h *Handler) handleGetBookById(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Produce  json
 // @Param title query string false "Filter by title"
-// @Param author query string false "Filter by author"
-// @Param isbn query string false "Filter by ISBN"
+// @Param author query string false "Filter by author name"
+// @Param authorId query string false "Filter by author ID"
+// @Param subject query string false "Filter by subject name"
+// @Param subjectId query string false "Filter by subject ID"
+// @Param workId query string false "Filter by work ID, listing every edition of the work"
+// @Param isbn query string false "Filter by ISBN (ISBN-10 or ISBN-13, hyphens allowed)"
+// @Param available query bool false "Only books with (true) or without (false) an available copy"
 // @Success 200 {array} types.Book
-// @Failure 500 {object} types.APIError
+// @Failure 400 {object} types.Problem
+// @Failure 500 {object} types.Problem
 // @Router /books [get]
 func (h *Handler) handleGetBooks(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
 	filter := map[string]string{
-		"title":  queryParams.Get("title"),
-		"author": queryParams.Get("author"),
-		"isbn":   queryParams.Get("isbn"),
+		"title":     queryParams.Get("title"),
+		"author":    queryParams.Get("author"),
+		"authorId":  queryParams.Get("authorId"),
+		"subject":   queryParams.Get("subject"),
+		"subjectId": queryParams.Get("subjectId"),
+		"workId":    queryParams.Get("workId"),
+		"isbn":      queryParams.Get("isbn"),
 	}
 
-	books, err := h.repository.GetBooks(filter)
+	if v := queryParams.Get("available"); v != "" {
+		available, err := strconv.ParseBool(v)
+		if err != nil {
+			utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid query"), map[string]string{"available": "must be true or false"})
+			return
+		}
+
+		filter["available"] = strconv.FormatBool(available)
+	}
+
+	books, err := h.repository.GetBooks(r.Context(), filter)
 	if err != nil {
-		log.Printf("error on GetBooks %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on GetBooks", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, books)
@@ -91,41 +118,184 @@ func (h *Handler) handleGetBooks(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Produce  json
 // @Param book body types.CreateBookPayload true "Book details"
-// @Success 201
-// @Failure 400 {object} types.APIError
-// @Failure 500 {object} types.APIError
+// @Param enrich query bool false "Fill empty fields from the metadata source by ISBN"
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 201 {object} types.Book
+// @Header 201 {string} Location "URL of the created book"
+// @Failure 400 {object} types.Problem
+// @Failure 409 {object} types.Problem
+// @Failure 500 {object} types.Problem
 // @Router /books [post]
 func (h *Handler) handleCreateBook(w http.ResponseWriter, r *http.Request) {
-	log.Print("handleCreateBook")
+	slog.DebugContext(r.Context(), "handleCreateBook")
 	var payload types.CreateBookPayload
 
 	err := utils.ParseJson(r, &payload)
 	if err != nil {
-		log.Printf("error on ParseJson %v", err)
-		utils.WriteError(w, http.StatusBadRequest, err)
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	normalizedISBN, err := isbn.Normalize(payload.ISBN)
+	if err != nil {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), map[string]string{"isbn": err.Error()})
 		return
 	}
 
-	if err := validator.New().Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+	if r.URL.Query().Get("enrich") == "true" {
+		if h.lookup == nil {
+			utils.WriteProblem(w, ErrLookupNotConfigured)
+			return
+		}
+
+		metadata, err := h.lookup.LookupISBN(r.Context(), normalizedISBN)
+
+		if err != nil && !errors.Is(err, openlibrary.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "error on LookupISBN", "error", err)
+			utils.WriteProblem(w, errs.Upstream("metadata lookup failed", err))
+			return
+		}
+
+		if metadata != nil {
+			enrich(&payload, metadata)
+		}
+	}
+
+	// Validate after enrichment, which may fill the title.
+	if err := utils.ValidateStruct(payload); err != nil {
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	err = h.repository.CreateBook(types.Book{
+	authorNames := payload.Authors
+	if len(authorNames) == 0 && payload.Author != "" {
+		authorNames = []string{payload.Author}
+	}
+
+	authors := make([]types.Author, 0, len(authorNames))
+	for _, name := range authorNames {
+		if name = strings.TrimSpace(name); name != "" {
+			authors = append(authors, types.Author{Name: name})
+		}
+	}
+
+	if len(authors) == 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), map[string]string{"authors": "at least one author is required"})
+		return
+	}
+
+	subjects := make([]types.Subject, 0, len(payload.Subjects))
+	for _, name := range payload.Subjects {
+		if name = strings.TrimSpace(name); name != "" {
+			subjects = append(subjects, types.Subject{Name: name})
+		}
+	}
+
+	book, err := h.repository.CreateBook(r.Context(), types.Book{
 		Title:         payload.Title,
 		Description:   payload.Description,
-		ISBN:          payload.ISBN,
-		Author:        payload.Author,
+		ISBN:          normalizedISBN,
+		Author:        joinAuthorNames(authors),
+		Authors:       authors,
+		Subjects:      subjects,
 		NumberOfPages: payload.NumberOfPages,
+		CoverURL:      payload.CoverURL,
+		WorkId:        payload.WorkId,
+		Edition:       payload.Edition,
 	})
 
 	if err != nil {
-		log.Printf("error on CreateBook %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on CreateBook", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+	utils.WriteCreated(w, "/books/"+book.Id, book)
+}
+
+// handleLookupBook godoc
+// @Summary Look up book metadata by ISBN
+// @Description Fetches title, authors, page count and cover from the metadata source, to prefill a new book
+// @Tags books
+// @Accept  json
+// @Produce  json
+// @Param book body types.LookupBookPayload true "ISBN to look up"
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 200 {object} types.Book
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 502 {object} types.Problem
+// @Router /books/lookup [post]
+func (h *Handler) handleLookupBook(w http.ResponseWriter, r *http.Request) {
+	var payload types.LookupBookPayload
+
+	err := utils.ParseJson(r, &payload)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	normalizedISBN, err := isbn.Normalize(payload.ISBN)
+	if err != nil {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), map[string]string{"isbn": err.Error()})
+		return
+	}
+
+	if h.lookup == nil {
+		utils.WriteProblem(w, ErrLookupNotConfigured)
+		return
+	}
+
+	book, err := h.lookup.LookupISBN(r.Context(), normalizedISBN)
+
+	if errors.Is(err, openlibrary.ErrNotFound) {
+		utils.WriteProblem(w, errs.NotFound("no metadata found for isbn %s", normalizedISBN))
+		return
+	}
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on LookupISBN", "error", err)
+		utils.WriteProblem(w, errs.Upstream("metadata lookup failed", err))
 		return
 	}
-	utils.WriteJSON(w, http.StatusCreated, nil)
+
+	utils.WriteJSON(w, http.StatusOK, book)
+}
+
+// enrich fills the fields the client left empty with looked up metadata.
+func enrich(payload *types.CreateBookPayload, metadata *types.Book) {
+	if payload.Title == "" {
+		payload.Title = metadata.Title
+	}
+	if payload.Author == "" && len(payload.Authors) == 0 {
+		for _, a := range metadata.Authors {
+			payload.Authors = append(payload.Authors, a.Name)
+		}
+	}
+	if len(payload.Subjects) == 0 {
+		for _, s := range metadata.Subjects {
+			payload.Subjects = append(payload.Subjects, s.Name)
+		}
+	}
+	if payload.Description == "" {
+		payload.Description = metadata.Description
+	}
+	if payload.NumberOfPages == 0 {
+		payload.NumberOfPages = metadata.NumberOfPages
+	}
+	if payload.CoverURL == "" {
+		payload.CoverURL = metadata.CoverURL
+	}
+}
+
+func joinAuthorNames(authors []types.Author) string {
+	names := make([]string, len(authors))
+	for i, a := range authors {
+		names[i] = a.Name
+	}
+
+	return strings.Join(names, ", ")
 }
 
 // handleCreateBookCopy godoc
@@ -136,45 +306,45 @@ func (h *Handler) handleCreateBook(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param bookCopy body types.CreateBookCopyPayload true "Book item details"
 // @Param id path string true "Book ID"
-// @Success 201
-// @Failure 400 {object} types.APIError
-// @Failure 500 {object} types.APIError
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 201 {object} types.BookCopy
+// @Header 201 {string} Location "URL of the created book item"
+// @Failure 400 {object} types.Problem
+// @Failure 500 {object} types.Problem
 // @Router /books/{id}/items [post]
 func (h *Handler) handleCreateBookCopy(w http.ResponseWriter, r *http.Request) {
-	log.Print("handleCreateBookCopy")
+	slog.DebugContext(r.Context(), "handleCreateBookCopy")
 	var payload types.CreateBookCopyPayload
 
 	err := utils.ParseJson(r, &payload)
 	if err != nil {
-		log.Printf("error on ParseJson %v", err)
-		utils.WriteError(w, http.StatusBadRequest, err)
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	if err := validator.New().Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
-		return
+	payload.Status = strings.ToLower(strings.TrimSpace(payload.Status))
+	if payload.Status == "" {
+		payload.Status = types.BookCopyStatusAvailable
 	}
 
-	err = uuid.Validate(payload.BookId)
-	if err != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid bookId"))
+	if err := utils.ValidateStruct(payload); err != nil {
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	book, err := h.repository.GetBookById(payload.BookId)
-	if err != nil {
-		log.Printf("error on GetBookById %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+	_, err = h.repository.GetBookById(r.Context(), payload.BookId)
+	if errors.Is(err, ErrBookNotFound) {
+		utils.WriteProblem(w, errs.Field("bookId", "book not found"))
 		return
 	}
-	if book == nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid bookId"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetBookById", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	err = h.repository.CreateBookCopy(types.BookCopy{
+	bookCopy, err := h.repository.CreateBookCopy(r.Context(), types.BookCopy{
 		BookId:    payload.BookId,
 		Status:    payload.Status,
 		Location:  payload.Location,
@@ -182,11 +352,11 @@ func (h *Handler) handleCreateBookCopy(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		log.Printf("error on CreateBookCopy %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on CreateBookCopy", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
-	utils.WriteJSON(w, http.StatusCreated, nil)
+	utils.WriteCreated(w, "/books/"+bookCopy.BookId+"/items/"+bookCopy.Id, bookCopy)
 }
 
 // handleGetBookCopies godoc
@@ -197,15 +367,15 @@ func (h *Handler) handleCreateBookCopy(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param id path string true "Book ID"
 // @Success 200 {array} types.BookCopy
-// @Failure 500 {object} types.APIError
+// @Failure 500 {object} types.Problem
 // @Router /books/{id}/items [get]
 func (h *Handler) handleGetBookCopies(w http.ResponseWriter, r *http.Request) {
 	bookId := r.PathValue("id")
 
-	bookCopies, err := h.repository.GetBookCopiesByBookId(bookId)
+	bookCopies, err := h.repository.GetBookCopiesByBookId(r.Context(), bookId)
 	if err != nil {
-		log.Printf("error on GetBookCopiesByBookId %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on GetBookCopiesByBookId", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, bookCopies)
@@ -217,23 +387,24 @@ func (h *Handler) handleGetBookCopies(w http.ResponseWriter, r *http.Request) {
 // @Tags books
 // @Accept  json
 // @Produce  json
-// @Param bookId path string true "Book ID"
+// @Param id path string true "Book ID"
 // @Param itemId path string true "Book Item ID"
 // @Success 200 {object} types.BookCopy
-// @Failure 404 {object} types.APIError
-// @Failure 500 {object} types.APIError
-// @Router /books/{bookId}/items/{itemId} [get]
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /books/{id}/items/{itemId} [get]
 func (h *Handler) handleGetBookCopyById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
 	itemId := r.PathValue("itemId")
 
-	bookCopy, err := h.repository.GetBookCopyById(itemId)
+	bookCopy, err := h.repository.GetBookCopyById(r.Context(), itemId)
 	if err != nil {
-		log.Printf("error on GetBookCopyById %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on GetBookCopyById", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
-	if bookCopy == nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("book item with id %s not found", itemId))
+	if bookCopy.BookId != id {
+		utils.WriteProblem(w, ErrBookCopyNotFound)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, bookCopy)
@@ -2,69 +2,103 @@ package books
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gfteix/book_loan_system/pkg/db/dbtest"
+	"github.com/gfteix/book_loan_system/pkg/openlibrary"
 	"github.com/gfteix/book_loan_system/types"
 )
 
 type mockBookRepository struct {
-	GetBookByIdFunc           func(id string) (*types.Book, error)
-	GetBooksFunc              func(filter map[string]string) ([]types.Book, error)
-	CreateBookFunc            func(book types.Book) error
-	CreateBookCopyFunc        func(bookCopy types.BookCopy) error
-	GetBookCopiesByBookIdFunc func(bookId string) ([]types.BookCopy, error)
-	GetBookCopyByIdFunc       func(itemId string) (*types.BookCopy, error)
+	GetBookByIdFunc           func(ctx context.Context, id string) (*types.Book, error)
+	GetBooksFunc              func(ctx context.Context, filter map[string]string) ([]types.Book, error)
+	CreateBookFunc            func(ctx context.Context, book types.Book) (*types.Book, error)
+	CreateBookCopyFunc        func(ctx context.Context, bookCopy types.BookCopy) (*types.BookCopy, error)
+	GetBookCopiesByBookIdFunc func(ctx context.Context, bookId string) ([]types.BookCopy, error)
+	GetBookCopyByIdFunc       func(ctx context.Context, itemId string) (*types.BookCopy, error)
+	StreamBooksFunc           func(ctx context.Context, filter map[string]string, fn func(types.Book) error) error
+	StreamBookCopiesFunc      func(ctx context.Context, filter map[string]string, fn func(types.BookCopy) error) error
 }
 
-func (m *mockBookRepository) GetBookById(id string) (*types.Book, error) {
+func (m *mockBookRepository) GetBookById(ctx context.Context, id string) (*types.Book, error) {
 	if m.GetBookByIdFunc != nil {
-		return m.GetBookByIdFunc(id)
+		return m.GetBookByIdFunc(ctx, id)
 	}
-	return nil, nil
+	return nil, ErrBookNotFound
 }
 
-func (m *mockBookRepository) GetBooks(filter map[string]string) ([]types.Book, error) {
+func (m *mockBookRepository) GetBooks(ctx context.Context, filter map[string]string) ([]types.Book, error) {
 	if m.GetBooksFunc != nil {
-		return m.GetBooksFunc(filter)
+		return m.GetBooksFunc(ctx, filter)
 	}
 	return nil, nil
 }
 
-func (m *mockBookRepository) CreateBook(book types.Book) error {
+func (m *mockBookRepository) CreateBook(ctx context.Context, book types.Book) (*types.Book, error) {
 	if m.CreateBookFunc != nil {
-		return m.CreateBookFunc(book)
+		return m.CreateBookFunc(ctx, book)
 	}
-	return nil
+	return &book, nil
 }
 
-func (m *mockBookRepository) CreateBookCopy(bookCopy types.BookCopy) error {
+func (m *mockBookRepository) CreateBookCopy(ctx context.Context, bookCopy types.BookCopy) (*types.BookCopy, error) {
 	if m.CreateBookCopyFunc != nil {
-		return m.CreateBookCopyFunc(bookCopy)
+		return m.CreateBookCopyFunc(ctx, bookCopy)
 	}
-	return nil
+	return &bookCopy, nil
 }
 
-func (m *mockBookRepository) GetBookCopiesByBookId(bookId string) ([]types.BookCopy, error) {
+func (m *mockBookRepository) GetBookCopiesByBookId(ctx context.Context, bookId string) ([]types.BookCopy, error) {
 	if m.GetBookCopiesByBookIdFunc != nil {
-		return m.GetBookCopiesByBookIdFunc(bookId)
+		return m.GetBookCopiesByBookIdFunc(ctx, bookId)
 	}
 	return nil, nil
 }
 
-func (m *mockBookRepository) GetBookCopyById(itemId string) (*types.BookCopy, error) {
+func (m *mockBookRepository) GetBookCopyById(ctx context.Context, itemId string) (*types.BookCopy, error) {
 	if m.GetBookCopyByIdFunc != nil {
-		return m.GetBookCopyByIdFunc(itemId)
+		return m.GetBookCopyByIdFunc(ctx, itemId)
 	}
-	return nil, nil
+	return nil, ErrBookCopyNotFound
+}
+
+func (m *mockBookRepository) StreamBooks(ctx context.Context, filter map[string]string, fn func(types.Book) error) error {
+	if m.StreamBooksFunc != nil {
+		return m.StreamBooksFunc(ctx, filter, fn)
+	}
+	return nil
+}
+
+func (m *mockBookRepository) StreamBookCopies(ctx context.Context, filter map[string]string, fn func(types.BookCopy) error) error {
+	if m.StreamBookCopiesFunc != nil {
+		return m.StreamBookCopiesFunc(ctx, filter, fn)
+	}
+	return nil
+}
+
+type mockBookLookup struct {
+	LookupISBNFunc func(ctx context.Context, isbn string) (*types.Book, error)
+}
+
+func (m *mockBookLookup) LookupISBN(ctx context.Context, isbn string) (*types.Book, error) {
+	if m.LookupISBNFunc != nil {
+		return m.LookupISBNFunc(ctx, isbn)
+	}
+	return nil, openlibrary.ErrNotFound
 }
 
 func TestBookHandler(t *testing.T) {
 	repository := &mockBookRepository{}
-	handler := NewHandler(repository)
+	lookup := &mockBookLookup{}
+	handler := NewHandler(repository, lookup)
 
 	t.Run("should fail if creating a book with invalid payload", func(t *testing.T) {
 		payload := map[string]interface{}{
@@ -89,14 +123,14 @@ func TestBookHandler(t *testing.T) {
 	})
 
 	t.Run("should successfully create a book", func(t *testing.T) {
-		repository.CreateBookFunc = func(book types.Book) error {
-			return nil
+		repository.CreateBookFunc = func(ctx context.Context, book types.Book) (*types.Book, error) {
+			return &book, nil
 		}
 
 		payload := types.CreateBookPayload{
 			Title:         "Sample Book",
 			Description:   "A book description",
-			ISBN:          "1234567890",
+			ISBN:          "978-0-14-305814-4",
 			Author:        "Author Name",
 			NumberOfPages: 100,
 		}
@@ -118,9 +152,305 @@ func TestBookHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("should store the normalized isbn-13", func(t *testing.T) {
+		var gotISBN string
+
+		repository.CreateBookFunc = func(ctx context.Context, book types.Book) (*types.Book, error) {
+			gotISBN = book.ISBN
+			return &book, nil
+		}
+
+		payload := types.CreateBookPayload{
+			Title:         "Sample Book",
+			ISBN:          "0-14-305814-2",
+			Author:        "Author Name",
+			NumberOfPages: 100,
+		}
+
+		marshalled, _ := json.Marshal(payload)
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		router.HandleFunc("/books", handler.handleCreateBook)
+
+		req, err := http.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if gotISBN != "9780143058144" {
+			t.Errorf("expected isbn 9780143058144, got %v", gotISBN)
+		}
+	})
+
+	t.Run("should fail to create a book with an invalid isbn", func(t *testing.T) {
+		payload := types.CreateBookPayload{
+			Title:         "Sample Book",
+			ISBN:          "9780143058145",
+			Author:        "Author Name",
+			NumberOfPages: 100,
+		}
+
+		marshalled, _ := json.Marshal(payload)
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		router.HandleFunc("/books", handler.handleCreateBook)
+
+		req, err := http.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		var problem types.Problem
+		if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != "isbn" {
+			t.Errorf("expected a field error for isbn, got %v", problem)
+		}
+	})
+
+	t.Run("should return 409 for a duplicate isbn", func(t *testing.T) {
+		repository.CreateBookFunc = func(ctx context.Context, book types.Book) (*types.Book, error) {
+			return nil, ErrDuplicateISBN
+		}
+
+		payload := types.CreateBookPayload{
+			Title:         "Sample Book",
+			ISBN:          "9780143058144",
+			Author:        "Author Name",
+			NumberOfPages: 100,
+		}
+
+		marshalled, _ := json.Marshal(payload)
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		router.HandleFunc("/books", handler.handleCreateBook)
+
+		req, err := http.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should look up book metadata by isbn", func(t *testing.T) {
+		var gotISBN string
+
+		lookup.LookupISBNFunc = func(ctx context.Context, isbn string) (*types.Book, error) {
+			gotISBN = isbn
+			return &types.Book{ISBN: isbn, Title: "Crime and Punishment", NumberOfPages: 671}, nil
+		}
+
+		marshalled, _ := json.Marshal(types.LookupBookPayload{ISBN: "0-14-305814-2"})
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(http.MethodPost, "/books/lookup", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if gotISBN != "9780143058144" {
+			t.Errorf("expected lookup by normalized isbn, got %v", gotISBN)
+		}
+
+		var book types.Book
+		if err := json.Unmarshal(rr.Body.Bytes(), &book); err != nil {
+			t.Fatal(err)
+		}
+
+		if book.Title != "Crime and Punishment" {
+			t.Errorf("expected title Crime and Punishment, got %v", book.Title)
+		}
+	})
+
+	t.Run("should return 404 when no metadata is found", func(t *testing.T) {
+		lookup.LookupISBNFunc = nil
+
+		marshalled, _ := json.Marshal(types.LookupBookPayload{ISBN: "9780143058144"})
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(http.MethodPost, "/books/lookup", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should return problems when the lookup fails or is not configured", func(t *testing.T) {
+		lookup.LookupISBNFunc = func(ctx context.Context, isbn string) (*types.Book, error) {
+			return nil, errors.New("connection refused")
+		}
+
+		for want, h := range map[int]*Handler{
+			http.StatusBadGateway:     handler,
+			http.StatusNotImplemented: NewHandler(repository, nil),
+		} {
+			marshalled, _ := json.Marshal(types.LookupBookPayload{ISBN: "9780143058144"})
+			rr := httptest.NewRecorder()
+			router := http.NewServeMux()
+			h.RegisterRoutes(router)
+
+			req, err := http.NewRequest(http.MethodPost, "/books/lookup", bytes.NewBuffer(marshalled))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != want {
+				t.Errorf("expected status code %d, got %d", want, rr.Code)
+			}
+
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("expected a problem response, got %q", ct)
+			}
+
+			if strings.Contains(rr.Body.String(), "connection refused") {
+				t.Errorf("expected the upstream error to be hidden, got %s", rr.Body)
+			}
+		}
+	})
+
+	t.Run("should fill empty fields when creating a book with enrich", func(t *testing.T) {
+		lookup.LookupISBNFunc = func(ctx context.Context, isbn string) (*types.Book, error) {
+			return &types.Book{ISBN: isbn, Title: "Crime and Punishment", Author: "Fyodor Dostoyevsky", NumberOfPages: 671}, nil
+		}
+
+		var got types.Book
+		repository.CreateBookFunc = func(ctx context.Context, book types.Book) (*types.Book, error) {
+			got = book
+			return &book, nil
+		}
+
+		marshalled, _ := json.Marshal(types.CreateBookPayload{ISBN: "9780143058144", Author: "F. Dostoyevsky"})
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		router.HandleFunc("/books", handler.handleCreateBook)
+
+		req, err := http.NewRequest(http.MethodPost, "/books?enrich=true", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if got.Title != "Crime and Punishment" || got.NumberOfPages != 671 {
+			t.Errorf("expected enriched title and pages, got %+v", got)
+		}
+
+		if got.Author != "F. Dostoyevsky" {
+			t.Errorf("expected client supplied author to be kept, got %v", got.Author)
+		}
+	})
+
+	t.Run("should create a book with several authors and subjects", func(t *testing.T) {
+		var got types.Book
+
+		repository.CreateBookFunc = func(ctx context.Context, book types.Book) (*types.Book, error) {
+			got = book
+			return &book, nil
+		}
+
+		payload := types.CreateBookPayload{
+			Title:         "Good Omens",
+			ISBN:          "9780060853983",
+			Authors:       []string{"Terry Pratchett", " Neil Gaiman "},
+			Subjects:      []string{"Fantasy", ""},
+			NumberOfPages: 432,
+		}
+
+		marshalled, _ := json.Marshal(payload)
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		router.HandleFunc("/books", handler.handleCreateBook)
+
+		req, err := http.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if len(got.Authors) != 2 || got.Authors[1].Name != "Neil Gaiman" {
+			t.Errorf("expected two trimmed authors, got %v", got.Authors)
+		}
+
+		if got.Author != "Terry Pratchett, Neil Gaiman" {
+			t.Errorf("expected joined author display name, got %v", got.Author)
+		}
+
+		if len(got.Subjects) != 1 {
+			t.Errorf("expected empty subjects to be dropped, got %v", got.Subjects)
+		}
+	})
+
+	t.Run("should fail to create a book without authors", func(t *testing.T) {
+		payload := types.CreateBookPayload{
+			Title: "Anonymous",
+			ISBN:  "9780143058144",
+		}
+
+		marshalled, _ := json.Marshal(payload)
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		router.HandleFunc("/books", handler.handleCreateBook)
+
+		req, err := http.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
 	t.Run("should fail to fetch a book if not found", func(t *testing.T) {
-		repository.GetBookByIdFunc = func(id string) (*types.Book, error) {
-			return nil, nil
+		repository.GetBookByIdFunc = func(ctx context.Context, id string) (*types.Book, error) {
+			return nil, ErrBookNotFound
 		}
 
 		rr := httptest.NewRecorder()
@@ -140,7 +470,7 @@ func TestBookHandler(t *testing.T) {
 	})
 
 	t.Run("should fetch all books successfully", func(t *testing.T) {
-		repository.GetBooksFunc = func(filter map[string]string) ([]types.Book, error) {
+		repository.GetBooksFunc = func(ctx context.Context, filter map[string]string) ([]types.Book, error) {
 			return []types.Book{
 				{Title: "Book 1", Author: "Author 1"},
 				{Title: "Book 2", Author: "Author 2"},
@@ -169,7 +499,7 @@ func TestBookHandler(t *testing.T) {
 
 		var gotValue string
 
-		repository.GetBooksFunc = func(filter map[string]string) ([]types.Book, error) {
+		repository.GetBooksFunc = func(ctx context.Context, filter map[string]string) ([]types.Book, error) {
 			gotValue = filter[filterKey]
 
 			return []types.Book{
@@ -200,6 +530,41 @@ func TestBookHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("should filter books by availability", func(t *testing.T) {
+		router := http.NewServeMux()
+		router.HandleFunc("/books", handler.handleGetBooks)
+
+		cases := map[string]struct {
+			status int
+			filter string
+		}{
+			"/books?available=true":  {http.StatusOK, "true"},
+			"/books?available=0":     {http.StatusOK, "false"},
+			"/books":                 {http.StatusOK, ""},
+			"/books?available=maybe": {http.StatusBadRequest, ""},
+		}
+
+		for path, want := range cases {
+			var got string
+			repository.GetBooksFunc = func(ctx context.Context, filter map[string]string) ([]types.Book, error) {
+				got = filter["available"]
+				return []types.Book{}, nil
+			}
+
+			rr := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != want.status || got != want.filter {
+				t.Errorf("%s: expected %d with filter %q, got %d with %q", path, want.status, want.filter, rr.Code, got)
+			}
+		}
+	})
+
 	t.Run("should fail to create book item with invalid book ID", func(t *testing.T) {
 		payload := types.CreateBookCopyPayload{
 			BookId:    "invalid-id",
@@ -226,7 +591,7 @@ func TestBookHandler(t *testing.T) {
 	})
 
 	t.Run("should fetch book items successfully", func(t *testing.T) {
-		repository.GetBookCopiesByBookIdFunc = func(bookId string) ([]types.BookCopy, error) {
+		repository.GetBookCopiesByBookIdFunc = func(ctx context.Context, bookId string) ([]types.BookCopy, error) {
 			return []types.BookCopy{
 				{BookId: "book-id", Status: "Available", Location: "Library"},
 			}, nil
@@ -247,4 +612,92 @@ func TestBookHandler(t *testing.T) {
 			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
 		}
 	})
+
+	t.Run("should fetch a book item of the book", func(t *testing.T) {
+		repository.GetBookCopyByIdFunc = func(ctx context.Context, itemId string) (*types.BookCopy, error) {
+			return &types.BookCopy{Id: itemId, BookId: "book-id", Status: "available"}, nil
+		}
+
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		for path, want := range map[string]int{
+			"/books/book-id/items/item-id":  http.StatusOK,
+			"/books/other-id/items/item-id": http.StatusNotFound,
+		} {
+			rr := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != want {
+				t.Errorf("%s: expected status code %d, got %d", path, want, rr.Code)
+			}
+		}
+	})
+}
+
+func TestBookHandlerQueries(t *testing.T) {
+	serve := func(repository *Repository, ctx context.Context) chan *httptest.ResponseRecorder {
+		router := http.NewServeMux()
+		NewHandler(repository, &mockBookLookup{}).RegisterRoutes(router)
+
+		req := httptest.NewRequest(http.MethodGet, "/books", nil).WithContext(ctx)
+		done := make(chan *httptest.ResponseRecorder, 1)
+
+		go func() {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			done <- rr
+		}()
+
+		return done
+	}
+
+	wait := func(t *testing.T, queries *dbtest.Blocking) error {
+		t.Helper()
+
+		select {
+		case err := <-queries.Aborted:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the query to be aborted")
+			return nil
+		}
+	}
+
+	t.Run("should abort the query when the request is cancelled", func(t *testing.T) {
+		db, queries := dbtest.NewBlocking()
+		defer db.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := serve(NewRepository(db, time.Minute), ctx)
+
+		<-queries.Started
+		cancel()
+
+		if err := wait(t, queries); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected %v, got %v", context.Canceled, err)
+		}
+
+		<-done
+	})
+
+	t.Run("should abort the query after the query timeout", func(t *testing.T) {
+		db, queries := dbtest.NewBlocking()
+		defer db.Close()
+
+		done := serve(NewRepository(db, 10*time.Millisecond), context.Background())
+
+		if err := wait(t, queries); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+
+		if rr := <-done; rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
 }
@@ -1,24 +1,68 @@
 package books
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/gfteix/book_loan_system/internal/holds"
+	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/pkg/isbn"
 	"github.com/gfteix/book_loan_system/types"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+var ErrBookNotFound = errs.NotFound("book not found")
+var ErrBookCopyNotFound = errs.NotFound("book item not found")
+var ErrDuplicateISBN = errs.Conflict("a book with this isbn already exists")
+var ErrWorkNotFound = errs.Field("workId", "work not found")
+
+const uniqueViolation = "23505"
+const foreignKeyViolation = "23503"
+
 type Repository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout time.Duration
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, timeout time.Duration) *Repository {
+	return &Repository{db: db, timeout: timeout}
 }
 
+// bookColumns selects a book together with its authors and subjects as JSON
+// arrays and its availability, so a page of books is loaded in a single
+// query. It reads from bookTables.
+const bookColumns = `b.id, b.title, b.description, b.isbn, b.author, b.number_of_pages, b.cover_url, b.work_id, b.edition, b.created_at,
+	(SELECT COALESCE(json_agg(json_build_object('id', a.id, 'name', a.name) ORDER BY ba.position), '[]')
+		FROM book_authors ba INNER JOIN authors a ON a.id = ba.author_id WHERE ba.book_id = b.id),
+	(SELECT COALESCE(json_agg(json_build_object('id', s.id, 'name', s.name) ORDER BY s.name), '[]')
+		FROM book_subjects bs INNER JOIN subjects s ON s.id = bs.subject_id WHERE bs.book_id = b.id),
+	copies.total, copies.available, copies.lent, copies.on_hold, due.expected_return`
+
+// bookTables joins each book to its copies counted by status and to the
+// earliest expiring date of its open loans. The aggregates return one row
+// per book even when it has no copies.
+const bookTables = `books b
+	CROSS JOIN LATERAL (SELECT COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE lower(bc.status) = 'available') AS available,
+			COUNT(*) FILTER (WHERE lower(bc.status) = 'lent') AS lent,
+			COUNT(*) FILTER (WHERE lower(bc.status) = 'on_hold') AS on_hold
+		FROM book_copies bc WHERE bc.book_id = b.id) copies
+	CROSS JOIN LATERAL (SELECT MIN(l.expiring_date) AS expected_return
+		FROM loans l INNER JOIN book_copies bc ON bc.id = l.book_item_id
+		WHERE bc.book_id = b.id AND l.return_date IS NULL) due`
+
 func scanRowIntoBook(rows *sql.Rows) (*types.Book, error) {
-	book := new(types.Book)
+	book := &types.Book{Availability: new(types.BookAvailability)}
+	var coverURL, workId, edition sql.NullString
+	var authors, subjects []byte
+	var expectedReturn sql.NullTime
 	err := rows.Scan(
 		&book.Id,
 		&book.Title,
@@ -26,11 +70,36 @@ func scanRowIntoBook(rows *sql.Rows) (*types.Book, error) {
 		&book.ISBN,
 		&book.Author,
 		&book.NumberOfPages,
+		&coverURL,
+		&workId,
+		&edition,
 		&book.CreatedAt,
+		&authors,
+		&subjects,
+		&book.Availability.TotalCopies,
+		&book.Availability.AvailableCopies,
+		&book.Availability.OnLoanCopies,
+		&book.Availability.OnHoldCopies,
+		&expectedReturn,
 	)
 	if err != nil {
 		return nil, err
 	}
+	book.CoverURL = coverURL.String
+	book.WorkId = workId.String
+	book.Edition = edition.String
+
+	if expectedReturn.Valid {
+		book.Availability.ExpectedReturnDate = &expectedReturn.Time
+	}
+
+	if err := json.Unmarshal(authors, &book.Authors); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(subjects, &book.Subjects); err != nil {
+		return nil, err
+	}
 
 	return book, nil
 }
@@ -52,8 +121,11 @@ func scanRowIntoBookCopy(rows *sql.Rows) (*types.BookCopy, error) {
 	return bookCopy, nil
 }
 
-func (r *Repository) GetBookById(id string) (*types.Book, error) {
-	rows, err := r.db.Query("SELECT id, title, description, isbn, author, number_of_pages, created_at FROM books WHERE id = $1", id)
+func (r *Repository) GetBookById(ctx context.Context, id string) (*types.Book, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+bookColumns+" FROM "+bookTables+" WHERE b.id = $1", id)
 	if err != nil {
 		return nil, err
 	}
@@ -63,11 +135,35 @@ func (r *Repository) GetBookById(id string) (*types.Book, error) {
 		return scanRowIntoBook(rows)
 	}
 
-	return nil, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrBookNotFound
 }
 
-func (r *Repository) GetBooks(filters map[string]string) ([]types.Book, error) {
-	q := "SELECT id, title, description, isbn, author, number_of_pages, created_at FROM books"
+func (r *Repository) GetBooks(ctx context.Context, filters map[string]string) ([]types.Book, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	books := make([]types.Book, 0)
+
+	err := r.StreamBooks(ctx, filters, func(book types.Book) error {
+		books = append(books, book)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// StreamBooks calls fn for every book matching filters, one row at a time,
+// so callers can process the whole catalog without holding it in memory.
+func (r *Repository) StreamBooks(ctx context.Context, filters map[string]string, fn func(types.Book) error) error {
+	q := "SELECT " + bookColumns + " FROM " + bookTables
 
 	where := make([]string, 0)
 	whereValues := make([]string, 0)
@@ -79,19 +175,59 @@ func (r *Repository) GetBooks(filters map[string]string) ([]types.Book, error) {
 		}
 
 		if k == "title" {
-			where = append(where, fmt.Sprintf("title = $%v", whereIndex))
+			where = append(where, fmt.Sprintf("b.title = $%v", whereIndex))
 			whereValues = append(whereValues, v)
 			whereIndex++
 		}
 
 		if k == "isbn" {
-			where = append(where, fmt.Sprintf("isbn = $%v", whereIndex))
+			// ISBNs are stored as ISBN-13, so accept either form here.
+			if normalized, err := isbn.Normalize(v); err == nil {
+				v = normalized
+			}
+			where = append(where, fmt.Sprintf("b.isbn = $%v", whereIndex))
 			whereValues = append(whereValues, v)
 			whereIndex++
 		}
 
 		if k == "author" {
-			where = append(where, fmt.Sprintf("author = $%v", whereIndex))
+			// Matches any co-author by name, including names merged away.
+			where = append(where, fmt.Sprintf(`EXISTS (SELECT 1 FROM book_authors ba INNER JOIN authors a ON a.id = ba.author_id
+				WHERE ba.book_id = b.id AND (lower(a.name) = lower($%[1]v)
+					OR a.id IN (SELECT author_id FROM author_aliases WHERE lower(name) = lower($%[1]v))))`, whereIndex))
+			whereValues = append(whereValues, v)
+			whereIndex++
+		}
+
+		if k == "authorId" {
+			where = append(where, fmt.Sprintf("EXISTS (SELECT 1 FROM book_authors ba WHERE ba.book_id = b.id AND ba.author_id = $%v)", whereIndex))
+			whereValues = append(whereValues, v)
+			whereIndex++
+		}
+
+		if k == "subject" {
+			where = append(where, fmt.Sprintf(`EXISTS (SELECT 1 FROM book_subjects bs INNER JOIN subjects s ON s.id = bs.subject_id
+				WHERE bs.book_id = b.id AND lower(s.name) = lower($%v))`, whereIndex))
+			whereValues = append(whereValues, v)
+			whereIndex++
+		}
+
+		if k == "workId" {
+			where = append(where, fmt.Sprintf("b.work_id = $%v", whereIndex))
+			whereValues = append(whereValues, v)
+			whereIndex++
+		}
+
+		if k == "available" && v == "true" {
+			where = append(where, "copies.available > 0")
+		}
+
+		if k == "available" && v == "false" {
+			where = append(where, "copies.available = 0")
+		}
+
+		if k == "subjectId" {
+			where = append(where, fmt.Sprintf("EXISTS (SELECT 1 FROM book_subjects bs WHERE bs.book_id = b.id AND bs.subject_id = $%v)", whereIndex))
 			whereValues = append(whereValues, v)
 			whereIndex++
 		}
@@ -101,41 +237,47 @@ func (r *Repository) GetBooks(filters map[string]string) ([]types.Book, error) {
 		q = fmt.Sprintf("%v WHERE %v", q, strings.Join(where, " AND "))
 		q = strings.TrimSuffix(q, " AND ")
 	}
+	q = q + " ORDER BY b.title"
+
 	args := make([]interface{}, len(whereValues))
 
 	for i, v := range whereValues {
 		args[i] = v
 	}
 
-	rows, err := r.db.Query(q, args...)
+	rows, err := r.db.QueryContext(ctx, q, args...)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	defer rows.Close()
 
-	books := make([]types.Book, 0)
-
 	for rows.Next() {
 		book, err := scanRowIntoBook(rows)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		if err := fn(*book); err != nil {
+			return err
 		}
-		books = append(books, *book)
 	}
 
-	return books, nil
+	return rows.Err()
 }
 
-func (r *Repository) GetBookCopiesByBookId(id string) ([]types.BookCopy, error) {
-	rows, err := r.db.Query("SELECT id, book_id, status, location, condition, created_at FROM book_copies WHERE book_id = $1", id)
+func (r *Repository) GetBookCopiesByBookId(ctx context.Context, id string) ([]types.BookCopy, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, book_id, status, location, condition, created_at FROM book_copies WHERE book_id = $1", id)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var bookCopies []types.BookCopy
+	bookCopies := make([]types.BookCopy, 0)
 	for rows.Next() {
 		bookCopy, err := scanRowIntoBookCopy(rows)
 		if err != nil {
@@ -144,11 +286,56 @@ func (r *Repository) GetBookCopiesByBookId(id string) ([]types.BookCopy, error)
 		bookCopies = append(bookCopies, *bookCopy)
 	}
 
-	return bookCopies, nil
+	return bookCopies, rows.Err()
+}
+
+// StreamBookCopies calls fn for every book copy matching filters. Supported
+// filters are "bookId" and "status".
+func (r *Repository) StreamBookCopies(ctx context.Context, filters map[string]string, fn func(types.BookCopy) error) error {
+	q := "SELECT id, book_id, status, location, condition, created_at FROM book_copies"
+
+	where := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if v := filters["bookId"]; v != "" {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf("book_id = $%v", len(args)))
+	}
+
+	if v := filters["status"]; v != "" {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf("status = $%v", len(args)))
+	}
+
+	if len(where) > 0 {
+		q = fmt.Sprintf("%v WHERE %v", q, strings.Join(where, " AND "))
+	}
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bookCopy, err := scanRowIntoBookCopy(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(*bookCopy); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
 }
 
-func (r *Repository) GetBookCopyById(id string) (*types.BookCopy, error) {
-	rows, err := r.db.Query("SELECT id, book_id, status, location, condition, created_at FROM book_copies WHERE id = $1", id)
+func (r *Repository) GetBookCopyById(ctx context.Context, id string) (*types.BookCopy, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, book_id, status, location, condition, created_at FROM book_copies WHERE id = $1", id)
 	if err != nil {
 		return nil, err
 	}
@@ -158,27 +345,152 @@ func (r *Repository) GetBookCopyById(id string) (*types.BookCopy, error) {
 		return scanRowIntoBookCopy(rows)
 	}
 
-	return nil, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrBookCopyNotFound
+}
+
+// CreateBook inserts the book and links it to its authors and subjects,
+// creating any that don't exist yet. Author names that were merged into
+// another author resolve to the surviving one. It returns the book as
+// stored.
+func (r *Repository) CreateBook(ctx context.Context, book types.Book) (*types.Book, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	id, err := r.createBook(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetBookById(ctx, id)
 }
 
-func (r *Repository) CreateBook(book types.Book) error {
+func (r *Repository) createBook(ctx context.Context, book types.Book) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	workId := book.WorkId
+	if workId == "" {
+		workId = uuid.NewString()
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO works (id, title) VALUES ($1, $2)", workId, book.Title)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	id := uuid.NewString()
-	_, err := r.db.Exec("INSERT INTO books (id, title, description, isbn, author, number_of_pages) VALUES ($1, $2, $3, $4, $5, $6)",
-		id, book.Title, book.Description, book.ISBN, book.Author, book.NumberOfPages)
+	_, err = tx.ExecContext(ctx, "INSERT INTO books (id, title, description, isbn, author, number_of_pages, cover_url, work_id, edition) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, NULLIF($9, ''))",
+		id, book.Title, book.Description, book.ISBN, book.Author, book.NumberOfPages, book.CoverURL, workId, book.Edition)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "books_isbn_key" {
+		return "", ErrDuplicateISBN
+	}
+
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation && pgErr.ConstraintName == "fk_work" {
+		return "", ErrWorkNotFound
+	}
+
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	for i, author := range book.Authors {
+		authorId, err := upsertAuthor(ctx, tx, author.Name)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO book_authors (book_id, author_id, position) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", id, authorId, i)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(book.Authors) > 0 {
+		_, err = tx.ExecContext(ctx, refreshAuthorNames+" WHERE b.id = $1", id)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	return nil
+	for _, subject := range book.Subjects {
+		var subjectId string
+		err := tx.QueryRowContext(ctx, "INSERT INTO subjects (id, name) VALUES ($1, $2) ON CONFLICT ((lower(name))) DO UPDATE SET name = subjects.name RETURNING id",
+			uuid.NewString(), subject.Name).Scan(&subjectId)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO book_subjects (book_id, subject_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, subjectId)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return id, tx.Commit()
 }
 
-func (r *Repository) CreateBookCopy(bookCopy types.BookCopy) error {
+// refreshAuthorNames rewrites the books.author display string from the
+// book_authors relation. Callers append a WHERE clause on b.
+const refreshAuthorNames = `UPDATE books b SET author = (
+	SELECT string_agg(a.name, ', ' ORDER BY ba.position)
+	FROM book_authors ba INNER JOIN authors a ON a.id = ba.author_id
+	WHERE ba.book_id = b.id)`
+
+func upsertAuthor(ctx context.Context, tx *sql.Tx, name string) (string, error) {
+	var authorId string
+
+	err := tx.QueryRowContext(ctx, "SELECT author_id FROM author_aliases WHERE lower(name) = lower($1)", name).Scan(&authorId)
+	if err == nil {
+		return authorId, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	err = tx.QueryRowContext(ctx, "INSERT INTO authors (id, name) VALUES ($1, $2) ON CONFLICT ((lower(name))) DO UPDATE SET name = authors.name RETURNING id",
+		uuid.NewString(), name).Scan(&authorId)
+
+	return authorId, err
+}
+
+// CreateBookCopy inserts the copy and returns it as stored. An available copy
+// is released to the oldest waiting hold that can take it.
+func (r *Repository) CreateBookCopy(ctx context.Context, bookCopy types.BookCopy) (*types.BookCopy, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	id := uuid.NewString()
-	_, err := r.db.Exec("INSERT INTO book_copies (id, book_id, status, location, condition) VALUES ($1, $2, $3, $4, $5)",
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO book_copies (id, book_id, status, location, condition) VALUES ($1, $2, $3, $4, $5)",
 		id, bookCopy.BookId, bookCopy.Status, bookCopy.Location, bookCopy.Condition)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if strings.EqualFold(bookCopy.Status, types.BookCopyStatusAvailable) {
+		if err := holds.ReleaseCopy(ctx, tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return r.GetBookCopyById(ctx, id)
 }
@@ -0,0 +1,47 @@
+package emails
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+
+	"github.com/gfteix/book_loan_system/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SMTP sends plain text emails through an SMTP server.
+type SMTP struct {
+	// Addr is the server's host:port.
+	Addr string
+	From string
+	// Username and Password enable PLAIN authentication, which net/smtp
+	// only allows over TLS or to localhost. When Username is empty no
+	// authentication is attempted.
+	Username string
+	Password string
+}
+
+func (s *SMTP) Send(ctx context.Context, to []string, subject string, body string) error {
+	_, span := tracing.Tracer().Start(ctx, "smtp.send", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	msg := fmt.Sprintf("Subject: %s\r\n\r\n%s", subject, body)
+
+	err := smtp.SendMail(s.Addr, auth, s.From, to, []byte(msg))
+	if err != nil {
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to send email", "error", err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "email sent successfully")
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package emails notifies borrowers by email about the loan events routed to
+// the emails queue.
+package emails
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/events"
+	"github.com/gfteix/book_loan_system/pkg/logging"
+	"github.com/gfteix/book_loan_system/pkg/metrics"
+	"github.com/gfteix/book_loan_system/pkg/mq"
+)
+
+type LoanData struct {
+	Email         string
+	Expiring_date time.Time
+	BookTitle     string
+}
+
+// Worker handles the events routed to the emails queue. Its database pool
+// and sender are shared by all messages in flight.
+type Worker struct {
+	db      *sql.DB
+	sender  *SMTP
+	metrics *metrics.EmailWorker
+}
+
+func NewWorker(db *sql.DB, sender *SMTP, m *metrics.EmailWorker) *Worker {
+	return &Worker{db: db, sender: sender, metrics: m}
+}
+
+// HandleMessage is the mq.Handler consuming the emails queue.
+func (w *Worker) HandleMessage(ctx context.Context, d mq.Delivery) error {
+	w.metrics.Consumed.Inc()
+
+	err := events.Handler(w.processEvent)(ctx, d)
+
+	switch {
+	case errors.Is(err, mq.ErrDecode):
+		slog.ErrorContext(ctx, "fail to unmarshal message body", "error", err)
+		w.metrics.Failed.WithLabelValues("decode").Inc()
+	case errors.Is(err, events.ErrUnknownSchema):
+		slog.WarnContext(ctx, "unrecognized event schema", "routingKey", d.RoutingKey, "error", err)
+		w.metrics.Failed.WithLabelValues("type").Inc()
+	}
+
+	return err
+}
+
+// processEvent emails the borrower about the event. Events the queue is not
+// bound for are dropped; lookup and send failures are retried once.
+func (w *Worker) processEvent(ctx context.Context, e events.Event, p events.Payload) error {
+	m := w.metrics
+
+	fail := func(reason string, err error) error {
+		m.Failed.WithLabelValues(reason).Inc()
+		return err
+	}
+
+	// Log under the ID of the run or request that published the event.
+	if e.RequestId != "" {
+		ctx = logging.WithRequestID(ctx, e.RequestId)
+	}
+
+	var loanId, subject, template string
+
+	switch p := p.(type) {
+	case events.LoanExpiredV1:
+		loanId = p.LoanId
+		subject = "Loan Expired"
+		template = "Your loan of the book %v expired on %v, please return the book to the library."
+	case events.LoanExpiringV1:
+		loanId = p.LoanId
+		subject = "Loan Expiring"
+		template = "Your loan of the book %v will expire on %v, please remember to return the book to the library until the expiration date."
+	default:
+		slog.WarnContext(ctx, "unexpected event type", "type", e.Type)
+		return fail("type", mq.Permanent(fmt.Errorf("unexpected event type %q", e.Type)))
+	}
+
+	slog.InfoContext(ctx, "received event", "eventId", e.EventId, "type", e.Type, "version", e.Version, "loanId", loanId)
+
+	data, err := w.getDataForEmail(ctx, loanId)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "error on getDataForEmail", "error", err)
+		return fail("lookup", err)
+	}
+
+	message := fmt.Sprintf(template, data.BookTitle, data.Expiring_date.Format("2006-01-02"))
+
+	err = w.sender.Send(ctx, []string{data.Email}, subject, message)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "error processing message", "eventId", e.EventId, "error", err)
+		return fail("send", err)
+	}
+	m.EmailsSent.Inc()
+	m.Processed.Inc()
+
+	return nil
+}
+
+// getDataForEmail returns the borrower's email and the loan's book. A loan
+// that no longer exists is a permanent error.
+func (w *Worker) getDataForEmail(ctx context.Context, loanId string) (LoanData, error) {
+	var data LoanData
+
+	err := w.db.QueryRowContext(ctx, "SELECT u.email, l.expiring_date, b.title FROM loans l INNER JOIN users u ON l.user_id = u.id INNER JOIN book_copies bi ON bi.id = l.book_item_id INNER JOIN books b ON b.id = bi.book_id WHERE l.id = $1", loanId).Scan(
+		&data.Email,
+		&data.Expiring_date,
+		&data.BookTitle,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return data, mq.Permanent(fmt.Errorf("loan %s not found", loanId))
+	}
+
+	return data, err
+}
@@ -0,0 +1,73 @@
+package emails
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/events"
+	"github.com/gfteix/book_loan_system/pkg/metrics"
+	"github.com/gfteix/book_loan_system/pkg/mq"
+	"github.com/gfteix/book_loan_system/pkg/smtptest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSMTP(t *testing.T) {
+	t.Run("should send to the configured server", func(t *testing.T) {
+		server := smtptest.NewServer()
+		defer server.Close()
+
+		sender := &SMTP{Addr: server.Addr, From: "library@example.com"}
+
+		if err := sender.Send(context.Background(), []string{"ana@example.com"}, "Loan Expired", "Please return the book."); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		messages, err := server.Wait(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m := messages[0]; m.To[0] != "ana@example.com" || m.Subject() != "Loan Expired" || strings.TrimSpace(m.Body()) != "Please return the book." {
+			t.Errorf("unexpected message %+v", m)
+		}
+	})
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("should drop messages that are not events", func(t *testing.T) {
+		m := metrics.NewEmailWorker(metrics.NewRegistry())
+		w := NewWorker(nil, &SMTP{}, m)
+
+		err := w.HandleMessage(context.Background(), mq.Delivery{Message: mq.Message{Body: []byte("not json")}})
+
+		if !errors.Is(err, mq.ErrDecode) {
+			t.Errorf("expected %v, got %v", mq.ErrDecode, err)
+		}
+
+		if got := testutil.ToFloat64(m.Failed.WithLabelValues("decode")); got != 1 {
+			t.Errorf("expected 1 decode failure, got %v", got)
+		}
+	})
+
+	t.Run("should drop events with an unknown schema", func(t *testing.T) {
+		m := metrics.NewEmailWorker(metrics.NewRegistry())
+		w := NewWorker(nil, &SMTP{}, m)
+
+		body := []byte(`{"type":"` + events.LoanExpired + `","version":99,"payload":{}}`)
+		err := w.HandleMessage(context.Background(), mq.Delivery{Message: mq.Message{Body: body}})
+
+		if !errors.Is(err, events.ErrUnknownSchema) {
+			t.Errorf("expected %v, got %v", events.ErrUnknownSchema, err)
+		}
+
+		if got := testutil.ToFloat64(m.Failed.WithLabelValues("type")); got != 1 {
+			t.Errorf("expected 1 type failure, got %v", got)
+		}
+	})
+}
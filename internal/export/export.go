@@ -0,0 +1,261 @@
+package export
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatMARCXML = "marcxml"
+)
+
+const (
+	ResourceBooks  = "books"
+	ResourceCopies = "copies"
+	ResourceUsers  = "users"
+	ResourceLoans  = "loans"
+)
+
+var ErrUnsupportedFormat = fmt.Errorf("unsupported export format")
+var ErrUnknownResource = fmt.Errorf("unknown export resource")
+
+// ContentType returns the MIME type used when serving an export in format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatMARCXML:
+		return "application/marcxml+xml"
+	}
+
+	return "application/octet-stream"
+}
+
+// FileExtension returns the conventional extension for format.
+func FileExtension(format string) string {
+	switch format {
+	case FormatNDJSON:
+		return "ndjson"
+	case FormatMARCXML:
+		return "xml"
+	}
+
+	return format
+}
+
+// Exporter streams records from the repositories into an encoder, one row at
+// a time, so exports never hold a full table in memory.
+type Exporter struct {
+	books types.BookRepository
+	users types.UserRepository
+	loans types.LoanRepository
+}
+
+func NewExporter(books types.BookRepository, users types.UserRepository, loans types.LoanRepository) *Exporter {
+	return &Exporter{books: books, users: users, loans: loans}
+}
+
+// Validate reports whether resource can be exported in format without
+// touching the database, so callers can reject a request before streaming.
+func Validate(resource string, format string) error {
+	switch resource {
+	case ResourceBooks:
+		if format == FormatMARCXML {
+			return nil
+		}
+	case ResourceCopies, ResourceUsers, ResourceLoans:
+	default:
+		return ErrUnknownResource
+	}
+
+	if format != FormatCSV && format != FormatNDJSON {
+		return ErrUnsupportedFormat
+	}
+
+	return nil
+}
+
+// Export writes every record of resource matching filters to w in format.
+// afterRecord, if not nil, is called once per record written; the HTTP
+// handler uses it to flush partial output to the client.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, resource string, format string, filters map[string]string, afterRecord func()) error {
+	if err := Validate(resource, format); err != nil {
+		return err
+	}
+
+	if afterRecord == nil {
+		afterRecord = func() {}
+	}
+
+	switch resource {
+	case ResourceBooks:
+		enc := newBookEncoder(w, format)
+		return run(enc, afterRecord, func(fn func(types.Book) error) error {
+			return e.books.StreamBooks(ctx, filters, fn)
+		})
+	case ResourceCopies:
+		enc := newRecordEncoder(w, format, bookCopyHeader, bookCopyRecord)
+		return run(enc, afterRecord, func(fn func(types.BookCopy) error) error {
+			return e.books.StreamBookCopies(ctx, filters, fn)
+		})
+	case ResourceUsers:
+		enc := newRecordEncoder(w, format, userHeader, userRecord)
+		return run(enc, afterRecord, func(fn func(types.User) error) error {
+			return e.users.StreamUsers(ctx, fn)
+		})
+	case ResourceLoans:
+		enc := newRecordEncoder(w, format, loanHeader, loanRecord)
+		return run(enc, afterRecord, func(fn func(types.Loan) error) error {
+			return e.loans.StreamLoans(ctx, filters, fn)
+		})
+	}
+
+	return ErrUnknownResource
+}
+
+func run[T any](enc encoder[T], afterRecord func(), stream func(func(T) error) error) error {
+	if err := enc.Begin(); err != nil {
+		return err
+	}
+
+	err := stream(func(v T) error {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		afterRecord()
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return enc.End()
+}
+
+type encoder[T any] interface {
+	Begin() error
+	Encode(v T) error
+	End() error
+}
+
+func newRecordEncoder[T any](w io.Writer, format string, header []string, record func(T) []string) encoder[T] {
+	if format == FormatCSV {
+		return &csvEncoder[T]{w: csv.NewWriter(w), header: header, record: record}
+	}
+
+	return &ndjsonEncoder[T]{enc: json.NewEncoder(w)}
+}
+
+func newBookEncoder(w io.Writer, format string) encoder[types.Book] {
+	if format == FormatMARCXML {
+		return newMARCEncoder(w)
+	}
+
+	return newRecordEncoder(w, format, bookHeader, bookRecord)
+}
+
+type csvEncoder[T any] struct {
+	w      *csv.Writer
+	header []string
+	record func(T) []string
+}
+
+func (e *csvEncoder[T]) Begin() error {
+	return e.w.Write(e.header)
+}
+
+func (e *csvEncoder[T]) Encode(v T) error {
+	record := e.record(v)
+	for i, cell := range record {
+		record[i] = escapeFormula(cell)
+	}
+
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder[T]) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeFormula prefixes cells that spreadsheets would read as a formula with
+// a quote, as OWASP recommends against CSV injection. Titles and names are
+// user input, and exports are opened by staff.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+
+	return cell
+}
+
+type ndjsonEncoder[T any] struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder[T]) Begin() error {
+	return nil
+}
+
+func (e *ndjsonEncoder[T]) Encode(v T) error {
+	return e.enc.Encode(v)
+}
+
+func (e *ndjsonEncoder[T]) End() error {
+	return nil
+}
+
+var bookHeader = []string{"id", "title", "description", "isbn", "author", "numberOfPages", "coverUrl", "createdAt"}
+
+func bookRecord(b types.Book) []string {
+	return []string{b.Id, b.Title, b.Description, b.ISBN, b.Author, strconv.Itoa(b.NumberOfPages), b.CoverURL, formatTime(b.CreatedAt)}
+}
+
+var bookCopyHeader = []string{"id", "bookId", "location", "condition", "status", "createdAt"}
+
+func bookCopyRecord(c types.BookCopy) []string {
+	return []string{c.Id, c.BookId, c.Location, c.Condition, c.Status, formatTime(c.CreatedAt)}
+}
+
+var userHeader = []string{"id", "name", "email", "createdAt"}
+
+func userRecord(u types.User) []string {
+	return []string{u.Id, u.Name, u.Email, formatTime(u.CreatedAt)}
+}
+
+var loanHeader = []string{"id", "userId", "bookCopyId", "status", "loanDate", "expiringDate", "returnDate", "createdAt"}
+
+func loanRecord(l types.Loan) []string {
+	returnDate := ""
+	if l.ReturnDate != nil {
+		returnDate = formatTime(*l.ReturnDate)
+	}
+
+	return []string{l.Id, l.UserId, l.BookCopyId, l.Status, formatTime(l.LoanDate), formatTime(l.ExpiringDate), returnDate, formatTime(l.CreatedAt)}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
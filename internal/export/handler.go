@@ -0,0 +1,107 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/utils"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+type Handler struct {
+	exporter *Exporter
+}
+
+func NewHandler(exporter *Exporter) *Handler {
+	return &Handler{exporter: exporter}
+}
+
+func (h *Handler) RegisterRoutes(router types.Router) {
+	router.HandleFunc("GET /export/{resource}", h.handleExport)
+}
+
+// filterKeys lists the query parameters each export accepts; they match the
+// filters of the corresponding list endpoint.
+var filterKeys = map[string][]string{
+	ResourceBooks:  {"title", "author", "isbn"},
+	ResourceCopies: {"bookId", "status"},
+	ResourceUsers:  {},
+	ResourceLoans:  {"userId", "status", "bookCopyId"},
+}
+
+// handleExport godoc
+// @Summary Export a resource
+// @Description Streams books, copies, users or loans as CSV, NDJSON or (books only) MARCXML. Accepts the same filters as the list endpoints.
+// @Tags export
+// @Produce  text/csv
+// @Produce  application/x-ndjson
+// @Produce  application/marcxml+xml
+// @Param resource path string true "Resource to export" Enums(books, copies, users, loans)
+// @Param format query string false "Export format" Enums(csv, ndjson, marcxml) default(csv)
+// @Param title query string false "Filter books by title"
+// @Param author query string false "Filter books by author"
+// @Param isbn query string false "Filter books by ISBN"
+// @Param bookId query string false "Filter copies by book ID"
+// @Param userId query string false "Filter loans by user ID"
+// @Param bookCopyId query string false "Filter loans by book copy ID"
+// @Param status query string false "Filter copies or loans by status"
+// @Success 200
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Router /export/{resource} [get]
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	resource := r.PathValue("resource")
+	queryParams := r.URL.Query()
+
+	format := queryParams.Get("format")
+	if format == "" {
+		format = FormatCSV
+	}
+
+	keys, ok := filterKeys[resource]
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("unknown export resource %s", resource))
+		return
+	}
+
+	if err := Validate(resource, format); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("%v: %s", err, format))
+		return
+	}
+
+	filter := make(map[string]string)
+	for _, k := range keys {
+		filter[k] = queryParams.Get(k)
+	}
+
+	w.Header().Set("Content-Type", ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", resource+"."+FileExtension(format)))
+	w.WriteHeader(http.StatusOK)
+
+	// Large exports outlive the server's write timeout, so lift it for this
+	// response only.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(r.Context(), "error on SetWriteDeadline", "error", err)
+	}
+
+	flusher, _ := w.(http.Flusher)
+	written := 0
+
+	err := h.exporter.Export(r.Context(), w, resource, format, filter, func() {
+		written++
+		if flusher != nil && written%100 == 0 {
+			flusher.Flush()
+		}
+	})
+
+	if err != nil {
+		// Headers are already sent, so the client sees a truncated body.
+		slog.ErrorContext(r.Context(), "error on Export", "resource", resource, "records", written, "error", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "export finished", "resource", resource, "format", format, "records", written)
+}
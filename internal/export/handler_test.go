@@ -0,0 +1,226 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+type mockBookRepository struct {
+	types.BookRepository
+	StreamBooksFunc      func(ctx context.Context, filter map[string]string, fn func(types.Book) error) error
+	StreamBookCopiesFunc func(ctx context.Context, filter map[string]string, fn func(types.BookCopy) error) error
+}
+
+func (m *mockBookRepository) StreamBooks(ctx context.Context, filter map[string]string, fn func(types.Book) error) error {
+	if m.StreamBooksFunc != nil {
+		return m.StreamBooksFunc(ctx, filter, fn)
+	}
+	return nil
+}
+
+func (m *mockBookRepository) StreamBookCopies(ctx context.Context, filter map[string]string, fn func(types.BookCopy) error) error {
+	if m.StreamBookCopiesFunc != nil {
+		return m.StreamBookCopiesFunc(ctx, filter, fn)
+	}
+	return nil
+}
+
+type mockUserRepository struct {
+	types.UserRepository
+	StreamUsersFunc func(ctx context.Context, fn func(types.User) error) error
+}
+
+func (m *mockUserRepository) StreamUsers(ctx context.Context, fn func(types.User) error) error {
+	if m.StreamUsersFunc != nil {
+		return m.StreamUsersFunc(ctx, fn)
+	}
+	return nil
+}
+
+type mockLoanRepository struct {
+	types.LoanRepository
+	StreamLoansFunc func(ctx context.Context, filter map[string]string, fn func(types.Loan) error) error
+}
+
+func (m *mockLoanRepository) StreamLoans(ctx context.Context, filter map[string]string, fn func(types.Loan) error) error {
+	if m.StreamLoansFunc != nil {
+		return m.StreamLoansFunc(ctx, filter, fn)
+	}
+	return nil
+}
+
+func streamBooks(books ...types.Book) func(context.Context, map[string]string, func(types.Book) error) error {
+	return func(ctx context.Context, filter map[string]string, fn func(types.Book) error) error {
+		for _, b := range books {
+			if err := fn(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	bookRepository := &mockBookRepository{}
+	userRepository := &mockUserRepository{}
+	loanRepository := &mockLoanRepository{}
+	handler := NewHandler(NewExporter(bookRepository, userRepository, loanRepository))
+
+	serve := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should export books as csv by default", func(t *testing.T) {
+		bookRepository.StreamBooksFunc = streamBooks(
+			types.Book{Id: "1", Title: "Book 1", ISBN: "9780143058144", NumberOfPages: 10},
+			types.Book{Id: "2", Title: "Book 2", ISBN: "9780140449242", NumberOfPages: 20},
+		)
+
+		rr := serve(t, "/export/books")
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if ct := rr.Header().Get("Content-Type"); ct != "text/csv" {
+			t.Errorf("expected content type text/csv, got %v", ct)
+		}
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(records) != 3 {
+			t.Fatalf("expected header and 2 rows, got %d rows", len(records))
+		}
+
+		if records[2][1] != "Book 2" {
+			t.Errorf("expected title Book 2, got %v", records[2][1])
+		}
+	})
+
+	t.Run("should escape csv cells that start a formula", func(t *testing.T) {
+		bookRepository.StreamBooksFunc = streamBooks(
+			types.Book{Id: "1", Title: "=HYPERLINK(\"http://example.com\")", Author: "@SUM(A1)", Description: "-1+1", ISBN: "+9780143058144"},
+		)
+
+		rr := serve(t, "/export/books")
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[int]string{1: "'=HYPERLINK(\"http://example.com\")", 2: "'-1+1", 3: "'+9780143058144", 4: "'@SUM(A1)"}
+		for i, cell := range want {
+			if records[1][i] != cell {
+				t.Errorf("expected %s to be %q, got %q", records[0][i], cell, records[1][i])
+			}
+		}
+
+		if records[1][0] != "1" {
+			t.Errorf("expected other cells unchanged, got %q", records[1][0])
+		}
+	})
+
+	t.Run("should pass list filters to the repository", func(t *testing.T) {
+		var gotFilter map[string]string
+
+		loanRepository.StreamLoansFunc = func(ctx context.Context, filter map[string]string, fn func(types.Loan) error) error {
+			gotFilter = filter
+			return fn(types.Loan{Id: "loan-1", UserId: "user-1"})
+		}
+
+		rr := serve(t, "/export/loans?format=ndjson&userId=user-1&title=ignored")
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if gotFilter["userId"] != "user-1" {
+			t.Errorf("expected userId filter user-1, got %v", gotFilter["userId"])
+		}
+
+		if _, ok := gotFilter["title"]; ok {
+			t.Errorf("expected title filter to be dropped for loans")
+		}
+
+		scanner := bufio.NewScanner(rr.Body)
+		lines := 0
+		for scanner.Scan() {
+			var loan types.Loan
+			if err := json.Unmarshal(scanner.Bytes(), &loan); err != nil {
+				t.Fatal(err)
+			}
+			lines++
+		}
+
+		if lines != 1 {
+			t.Errorf("expected 1 ndjson line, got %d", lines)
+		}
+	})
+
+	t.Run("should export books as marcxml", func(t *testing.T) {
+		bookRepository.StreamBooksFunc = streamBooks(
+			types.Book{Id: "1", Title: "Crime and Punishment", ISBN: "9780143058144", Author: "Fyodor Dostoyevsky"},
+		)
+
+		rr := serve(t, "/export/books?format=marcxml")
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var collection struct {
+			Records []marcRecord `xml:"record"`
+		}
+
+		if err := xml.Unmarshal(rr.Body.Bytes(), &collection); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(collection.Records) != 1 {
+			t.Fatalf("expected 1 record, got %d", len(collection.Records))
+		}
+
+		if !bytes.Contains(rr.Body.Bytes(), []byte(`<datafield tag="245" ind1="1" ind2="0"><subfield code="a">Crime and Punishment</subfield>`)) {
+			t.Errorf("expected title field 245, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should reject marcxml for non book resources", func(t *testing.T) {
+		rr := serve(t, "/export/users?format=marcxml")
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return 404 for unknown resources", func(t *testing.T) {
+		rr := serve(t, "/export/authors")
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+const marcNamespace = "http://www.loc.gov/MARC21/slim"
+
+// marcLeader is a fixed leader for a new, language material, monograph
+// record. Record length and base address are left blank as MARCXML does not
+// need them.
+const marcLeader = "     nam a22     uu 4500"
+
+type marcControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcDataField struct {
+	Tag       string         `xml:"tag,attr"`
+	Ind1      string         `xml:"ind1,attr"`
+	Ind2      string         `xml:"ind2,attr"`
+	Subfields []marcSubfield `xml:"subfield"`
+}
+
+type marcRecord struct {
+	XMLName       xml.Name           `xml:"record"`
+	Leader        string             `xml:"leader"`
+	ControlFields []marcControlField `xml:"controlfield"`
+	DataFields    []marcDataField    `xml:"datafield"`
+}
+
+// marcEncoder writes books as a MARCXML <collection>, one <record> per book.
+type marcEncoder struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+func newMARCEncoder(w io.Writer) *marcEncoder {
+	return &marcEncoder{w: w, enc: xml.NewEncoder(w)}
+}
+
+func (e *marcEncoder) Begin() error {
+	if _, err := io.WriteString(e.w, xml.Header); err != nil {
+		return err
+	}
+
+	return e.enc.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "collection"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: marcNamespace}},
+	})
+}
+
+func (e *marcEncoder) Encode(book types.Book) error {
+	if err := e.enc.Encode(bookToMARC(book)); err != nil {
+		return err
+	}
+
+	return e.enc.Flush()
+}
+
+func (e *marcEncoder) End() error {
+	if err := e.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "collection"}}); err != nil {
+		return err
+	}
+
+	return e.enc.Flush()
+}
+
+func bookToMARC(book types.Book) marcRecord {
+	record := marcRecord{
+		Leader: marcLeader,
+		ControlFields: []marcControlField{
+			{Tag: "001", Value: book.Id},
+		},
+	}
+
+	field := func(tag, ind1, ind2, code, value string) {
+		if value == "" {
+			return
+		}
+
+		record.DataFields = append(record.DataFields, marcDataField{
+			Tag:       tag,
+			Ind1:      ind1,
+			Ind2:      ind2,
+			Subfields: []marcSubfield{{Code: code, Value: value}},
+		})
+	}
+
+	field("020", " ", " ", "a", book.ISBN)
+	field("100", "1", " ", "a", book.Author)
+	field("245", "1", "0", "a", book.Title)
+
+	if book.NumberOfPages > 0 {
+		field("300", " ", " ", "a", fmt.Sprintf("%d p.", book.NumberOfPages))
+	}
+
+	field("520", " ", " ", "a", book.Description)
+	field("856", "4", "2", "u", book.CoverURL)
+
+	return record
+}
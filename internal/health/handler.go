@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/utils"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+// Check reports whether a dependency is usable. It should return promptly
+// once ctx is done.
+type Check func(ctx context.Context) error
+
+type Handler struct {
+	checks   map[string]Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// NewHandler returns a handler whose readiness depends on every check in
+// checks, each given at most timeout to answer.
+func NewHandler(checks map[string]Check, timeout time.Duration) *Handler {
+	return &Handler{checks: checks, timeout: timeout}
+}
+
+func (h *Handler) RegisterRoutes(router types.Router) {
+	router.HandleFunc("GET /healthz", h.handleHealthz)
+	router.HandleFunc("GET /readyz", h.handleReadyz)
+}
+
+// Drain makes /readyz fail from now on so load balancers stop routing new
+// requests while in-flight ones finish.
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
+
+// @Summary Liveness probe
+// @Description Reports that the process is up. It does not check dependencies.
+// @Tags health
+// @Produce json
+// @Success 200 {object} types.HealthStatus
+// @Router /healthz [get]
+func (h *Handler) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, types.HealthStatus{Status: types.HealthStatusOK})
+}
+
+// @Summary Readiness probe
+// @Description Reports whether the API can serve traffic: Postgres, and RabbitMQ when configured, must be reachable and the server must not be shutting down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} types.HealthStatus
+// @Failure 503 {object} types.HealthStatus
+// @Router /readyz [get]
+func (h *Handler) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		utils.WriteJSON(w, http.StatusServiceUnavailable, types.HealthStatus{Status: types.HealthStatusDraining})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	status := types.HealthStatus{Status: types.HealthStatusOK, Checks: make(map[string]string, len(h.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := types.HealthStatusOK
+			if err := run(ctx, check); err != nil {
+				slog.WarnContext(r.Context(), "readiness check failed", "check", name, "error", err)
+				result = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			status.Checks[name] = result
+			if result != types.HealthStatusOK {
+				status.Status = types.HealthStatusUnavailable
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	code := http.StatusOK
+	if status.Status != types.HealthStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	utils.WriteJSON(w, code, status)
+}
+
+// run returns as soon as ctx is done, even if check ignores it.
+func run(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+
+	go func() {
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+func TestHealthHandler(t *testing.T) {
+	serve := func(t *testing.T, handler *Handler, path string) (*httptest.ResponseRecorder, types.HealthStatus) {
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		var status types.HealthStatus
+		if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+
+		return rr, status
+	}
+
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return fmt.Errorf("connection refused") }
+
+	t.Run("should report liveness even when dependencies fail", func(t *testing.T) {
+		handler := NewHandler(map[string]Check{"postgres": failing}, time.Second)
+
+		rr, _ := serve(t, handler, "/healthz")
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should be ready when every check passes", func(t *testing.T) {
+		handler := NewHandler(map[string]Check{"postgres": ok, "rabbitmq": ok}, time.Second)
+
+		rr, status := serve(t, handler, "/readyz")
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if status.Checks["postgres"] != types.HealthStatusOK || status.Checks["rabbitmq"] != types.HealthStatusOK {
+			t.Errorf("unexpected checks %v", status.Checks)
+		}
+	})
+
+	t.Run("should not be ready when a check fails", func(t *testing.T) {
+		handler := NewHandler(map[string]Check{"postgres": ok, "rabbitmq": failing}, time.Second)
+
+		rr, status := serve(t, handler, "/readyz")
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status code %d, got %d", http.StatusServiceUnavailable, rr.Code)
+		}
+
+		if status.Checks["rabbitmq"] != "connection refused" {
+			t.Errorf("expected rabbitmq error, got %v", status.Checks["rabbitmq"])
+		}
+	})
+
+	t.Run("should time out slow checks", func(t *testing.T) {
+		hanging := func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}
+		handler := NewHandler(map[string]Check{"postgres": hanging}, 10*time.Millisecond)
+
+		rr, _ := serve(t, handler, "/readyz")
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status code %d, got %d", http.StatusServiceUnavailable, rr.Code)
+		}
+	})
+
+	t.Run("should not be ready while draining", func(t *testing.T) {
+		handler := NewHandler(map[string]Check{"postgres": ok}, time.Second)
+		handler.Drain()
+
+		rr, status := serve(t, handler, "/readyz")
+
+		if rr.Code != http.StatusServiceUnavailable || status.Status != types.HealthStatusDraining {
+			t.Errorf("expected draining 503, got %d %v", rr.Code, status.Status)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package holds
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gfteix/book_loan_system/pkg/utils"
+	"github.com/gfteix/book_loan_system/types"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	repository types.HoldRepository
+}
+
+func NewHandler(repository types.HoldRepository) *Handler {
+	return &Handler{repository: repository}
+}
+
+func (h *Handler) RegisterRoutes(router types.Router) {
+	router.HandleFunc("POST /holds", h.handlePlaceHold)
+	router.HandleFunc("GET /holds", h.handleGetHolds)
+	router.HandleFunc("GET /holds/{id}", h.handleGetHoldById)
+	router.HandleFunc("POST /holds/{id}/cancel", h.handleCancelHold)
+}
+
+// handlePlaceHold godoc
+// @Summary Place a hold
+// @Description Places a hold on a book. A free copy is set aside right away when there is one ("ready"); otherwise the hold is "waiting". With anyEdition the copy may come from any edition of the book's work.
+// @Tags holds
+// @Accept  json
+// @Produce  json
+// @Param hold body types.PlaceHoldPayload true "Hold details"
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 201 {object} types.Hold
+// @Header 201 {string} Location "URL of the created hold"
+// @Failure 400 {object} types.Problem
+// @Failure 409 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /holds [post]
+func (h *Handler) handlePlaceHold(w http.ResponseWriter, r *http.Request) {
+	var payload types.PlaceHoldPayload
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	fields := make(map[string]string)
+
+	if uuid.Validate(payload.UserId) != nil {
+		fields["userId"] = "invalid id"
+	}
+
+	if uuid.Validate(payload.BookId) != nil {
+		fields["bookId"] = "invalid id"
+	}
+
+	if len(fields) > 0 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), fields)
+		return
+	}
+
+	hold, err := h.repository.PlaceHold(r.Context(), types.Hold{
+		UserId:     payload.UserId,
+		BookId:     payload.BookId,
+		AnyEdition: payload.AnyEdition,
+	})
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on PlaceHold", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteCreated(w, "/holds/"+hold.Id, hold)
+}
+
+// handleGetHolds godoc
+// @Summary Get holds
+// @Description Retrieves holds in the order they were placed
+// @Tags holds
+// @Accept  json
+// @Produce  json
+// @Param userId query string false "Filter by user ID"
+// @Param bookId query string false "Filter by book ID"
+// @Param workId query string false "Filter by work ID"
+// @Param status query string false "Filter by status" Enums(waiting, ready, cancelled, fulfilled)
+// @Success 200 {array} types.Hold
+// @Failure 500 {object} types.Problem
+// @Router /holds [get]
+func (h *Handler) handleGetHolds(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+
+	filter := map[string]string{
+		"userId": queryParams.Get("userId"),
+		"bookId": queryParams.Get("bookId"),
+		"workId": queryParams.Get("workId"),
+		"status": queryParams.Get("status"),
+	}
+
+	holds, err := h.repository.GetHolds(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetHolds", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, holds)
+}
+
+// handleGetHoldById godoc
+// @Summary Get a hold by ID
+// @Description Retrieves a hold by its ID
+// @Tags holds
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Hold ID"
+// @Success 200 {object} types.Hold
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /holds/{id} [get]
+func (h *Handler) handleGetHoldById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	hold, err := h.repository.GetHold(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetHold", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, hold)
+}
+
+// handleCancelHold godoc
+// @Summary Cancel a hold
+// @Description Cancels a hold. A copy set aside for it goes to the next waiting hold that accepts it, or back on the shelf.
+// @Tags holds
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Hold ID"
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 204
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /holds/{id}/cancel [post]
+func (h *Handler) handleCancelHold(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	err := h.repository.CancelHold(r.Context(), id)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on CancelHold", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package holds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+type mockHoldRepository struct {
+	PlaceHoldFunc  func(ctx context.Context, hold types.Hold) (*types.Hold, error)
+	GetHoldFunc    func(ctx context.Context, id string) (*types.Hold, error)
+	GetHoldsFunc   func(ctx context.Context, filter map[string]string) ([]types.Hold, error)
+	CancelHoldFunc func(ctx context.Context, id string) error
+}
+
+func (m *mockHoldRepository) PlaceHold(ctx context.Context, hold types.Hold) (*types.Hold, error) {
+	if m.PlaceHoldFunc != nil {
+		return m.PlaceHoldFunc(ctx, hold)
+	}
+	return &hold, nil
+}
+
+func (m *mockHoldRepository) GetHold(ctx context.Context, id string) (*types.Hold, error) {
+	if m.GetHoldFunc != nil {
+		return m.GetHoldFunc(ctx, id)
+	}
+	return nil, ErrHoldNotFound
+}
+
+func (m *mockHoldRepository) GetHolds(ctx context.Context, filter map[string]string) ([]types.Hold, error) {
+	if m.GetHoldsFunc != nil {
+		return m.GetHoldsFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+func (m *mockHoldRepository) CancelHold(ctx context.Context, id string) error {
+	if m.CancelHoldFunc != nil {
+		return m.CancelHoldFunc(ctx, id)
+	}
+	return nil
+}
+
+const userId = "123e4567-e89b-12d3-a456-426614174000"
+const bookId = "123e4567-e89b-12d3-a456-426614174001"
+const copyId = "123e4567-e89b-12d3-a456-426614174002"
+
+func TestHoldHandler(t *testing.T) {
+	repository := &mockHoldRepository{}
+	handler := NewHandler(repository)
+
+	serve := func(t *testing.T, method string, path string, body []byte) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should place a hold that accepts any edition", func(t *testing.T) {
+		var got types.Hold
+
+		repository.PlaceHoldFunc = func(ctx context.Context, hold types.Hold) (*types.Hold, error) {
+			got = hold
+			copyId := copyId
+			hold.Status = types.HoldStatusReady
+			hold.BookCopyId = &copyId
+			return &hold, nil
+		}
+
+		body, _ := json.Marshal(types.PlaceHoldPayload{UserId: userId, BookId: bookId, AnyEdition: true})
+		rr := serve(t, http.MethodPost, "/holds", body)
+
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if !got.AnyEdition {
+			t.Errorf("expected anyEdition to be passed to the repository")
+		}
+
+		var hold types.Hold
+		if err := json.Unmarshal(rr.Body.Bytes(), &hold); err != nil {
+			t.Fatal(err)
+		}
+
+		if hold.Status != types.HoldStatusReady || hold.BookCopyId == nil {
+			t.Errorf("expected a ready hold with a copy, got %+v", hold)
+		}
+	})
+
+	t.Run("should return 409 for a second active hold on the book", func(t *testing.T) {
+		repository.PlaceHoldFunc = func(ctx context.Context, hold types.Hold) (*types.Hold, error) {
+			return nil, ErrDuplicateHold
+		}
+
+		body, _ := json.Marshal(types.PlaceHoldPayload{UserId: userId, BookId: bookId})
+		rr := serve(t, http.MethodPost, "/holds", body)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should fail to place a hold for an unknown book", func(t *testing.T) {
+		repository.PlaceHoldFunc = func(ctx context.Context, hold types.Hold) (*types.Hold, error) {
+			return nil, ErrBookNotFound
+		}
+
+		body, _ := json.Marshal(types.PlaceHoldPayload{UserId: userId, BookId: bookId})
+		rr := serve(t, http.MethodPost, "/holds", body)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should fail to place a hold with invalid ids", func(t *testing.T) {
+		body, _ := json.Marshal(types.PlaceHoldPayload{UserId: "user", BookId: bookId})
+		rr := serve(t, http.MethodPost, "/holds", body)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return 404 when cancelling an unknown hold", func(t *testing.T) {
+		repository.CancelHoldFunc = func(ctx context.Context, id string) error {
+			return ErrHoldNotFound
+		}
+
+		rr := serve(t, http.MethodPost, "/holds/"+bookId+"/cancel", nil)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should return 404 for an unknown hold", func(t *testing.T) {
+		rr := serve(t, http.MethodGet, "/holds/"+bookId, nil)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
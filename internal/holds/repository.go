@@ -0,0 +1,286 @@
+package holds
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrBookNotFound = errs.Field("bookId", "book not found")
+var ErrUserNotFound = errs.Field("userId", "user not found")
+var ErrHoldNotFound = errs.NotFound("hold not found")
+var ErrDuplicateHold = errs.Conflict("user already has an active hold on this book")
+
+type Repository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewRepository(db *sql.DB, timeout time.Duration) *Repository {
+	return &Repository{db: db, timeout: timeout}
+}
+
+const holdColumns = "id, user_id, book_id, work_id, any_edition, status, book_copy_id, created_at"
+
+func scanRowIntoHold(rows *sql.Rows) (*types.Hold, error) {
+	hold := new(types.Hold)
+	var workId sql.NullString
+
+	err := rows.Scan(
+		&hold.Id,
+		&hold.UserId,
+		&hold.BookId,
+		&workId,
+		&hold.AnyEdition,
+		&hold.Status,
+		&hold.BookCopyId,
+		&hold.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	hold.WorkId = workId.String
+
+	return hold, nil
+}
+
+// PlaceHold records a hold and immediately sets aside an available copy if
+// there is one. A copy of the requested edition is preferred; with AnyEdition
+// any edition of the same work may be used. Without a free copy the hold
+// waits for the next copy released by ReleaseCopy. A user may have one active
+// hold per book, and one any-edition hold per work.
+func (r *Repository) PlaceHold(ctx context.Context, hold types.Hold) (*types.Hold, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var workId sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT work_id FROM books WHERE id = $1", hold.BookId).Scan(&workId)
+	if err == sql.ErrNoRows {
+		return nil, ErrBookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var copyId sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT bc.id
+		FROM book_copies bc
+		INNER JOIN books b ON b.id = bc.book_id
+		WHERE lower(bc.status) = $1
+			AND (b.id = $2 OR ($3 AND b.work_id = $4))
+		ORDER BY (b.id = $2) DESC, bc.created_at
+		LIMIT 1
+		FOR UPDATE OF bc SKIP LOCKED`,
+		types.BookCopyStatusAvailable, hold.BookId, hold.AnyEdition, workId).Scan(&copyId)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	hold.Id = uuid.NewString()
+	hold.WorkId = workId.String
+	hold.Status = types.HoldStatusWaiting
+	hold.BookCopyId = nil
+
+	if copyId.Valid {
+		_, err = tx.ExecContext(ctx, "UPDATE book_copies SET status = $1 WHERE id = $2", types.BookCopyStatusOnHold, copyId.String)
+		if err != nil {
+			return nil, err
+		}
+
+		hold.Status = types.HoldStatusReady
+		hold.BookCopyId = &copyId.String
+	}
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO holds (id, user_id, book_id, work_id, any_edition, status, book_copy_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at`,
+		hold.Id, hold.UserId, hold.BookId, workId, hold.AnyEdition, hold.Status, hold.BookCopyId).Scan(&hold.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" && pgErr.ConstraintName == "fk_user_id" {
+		return nil, ErrUserNotFound
+	}
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, ErrDuplicateHold
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+func (r *Repository) GetHold(ctx context.Context, id string) (*types.Hold, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+holdColumns+" FROM holds WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanRowIntoHold(rows)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrHoldNotFound
+}
+
+func (r *Repository) GetHolds(ctx context.Context, filters map[string]string) ([]types.Hold, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	q := "SELECT " + holdColumns + " FROM holds"
+
+	columns := map[string]string{
+		"userId": "user_id",
+		"bookId": "book_id",
+		"workId": "work_id",
+		"status": "status",
+	}
+
+	where := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	for key, column := range columns {
+		if v := filters[key]; v != "" {
+			args = append(args, v)
+			where = append(where, fmt.Sprintf("%s = $%v", column, len(args)))
+		}
+	}
+
+	if len(where) > 0 {
+		q = fmt.Sprintf("%v WHERE %v", q, strings.Join(where, " AND "))
+	}
+
+	rows, err := r.db.QueryContext(ctx, q+" ORDER BY created_at", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := make([]types.Hold, 0)
+
+	for rows.Next() {
+		hold, err := scanRowIntoHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *hold)
+	}
+
+	return holds, rows.Err()
+}
+
+// CancelHold cancels the hold; finished holds are left as they are. If a copy
+// was set aside for it, the copy goes to the oldest waiting hold that can take
+// it, or back on the shelf.
+func (r *Repository) CancelHold(ctx context.Context, id string) error {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var copyId sql.NullString
+
+	err = tx.QueryRowContext(ctx, "SELECT status, book_copy_id FROM holds WHERE id = $1 FOR UPDATE", id).Scan(&status, &copyId)
+	if err == sql.ErrNoRows {
+		return ErrHoldNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if status == types.HoldStatusCancelled || status == types.HoldStatusFulfilled {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE holds SET status = $1 WHERE id = $2", types.HoldStatusCancelled, id)
+	if err != nil {
+		return err
+	}
+
+	if status == types.HoldStatusReady && copyId.Valid {
+		if err := ReleaseCopy(ctx, tx, copyId.String); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReleaseCopy is called, in the caller's transaction, whenever a copy becomes
+// free: cancelled holds, returned loans and new copies. The copy is set aside
+// for the oldest waiting hold on its book, or on its work for holds that take
+// any edition, and goes back on the shelf when no hold is waiting.
+func ReleaseCopy(ctx context.Context, tx *sql.Tx, copyId string) error {
+	var holdId string
+
+	err := tx.QueryRowContext(ctx, `SELECT h.id
+		FROM holds h, book_copies bc
+		INNER JOIN books b ON b.id = bc.book_id
+		WHERE bc.id = $1
+			AND h.status = $2
+			AND (h.book_id = b.id OR (h.any_edition AND h.work_id = b.work_id))
+		ORDER BY h.created_at
+		LIMIT 1
+		FOR UPDATE OF h SKIP LOCKED`, copyId, types.HoldStatusWaiting).Scan(&holdId)
+
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx, "UPDATE book_copies SET status = $1 WHERE id = $2", types.BookCopyStatusAvailable, copyId)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE holds SET status = $1, book_copy_id = $2 WHERE id = $3", types.HoldStatusReady, copyId, holdId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE book_copies SET status = $1 WHERE id = $2", types.BookCopyStatusOnHold, copyId)
+	return err
+}
+
+// FulfillHold marks userId's ready hold on the copy as fulfilled, in the
+// caller's transaction, when the copy is lent to them. It reports whether
+// they had one.
+func FulfillHold(ctx context.Context, tx *sql.Tx, userId string, copyId string) (bool, error) {
+	res, err := tx.ExecContext(ctx, "UPDATE holds SET status = $1 WHERE book_copy_id = $2 AND user_id = $3 AND status = $4",
+		types.HoldStatusFulfilled, copyId, userId, types.HoldStatusReady)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
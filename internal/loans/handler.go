@@ -2,7 +2,7 @@ package loans
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gfteix/book_loan_system/pkg/utils"
@@ -18,10 +18,11 @@ func NewHandler(repository types.LoanRepository) *Handler {
 	return &Handler{repository: repository}
 }
 
-func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+func (h *Handler) RegisterRoutes(router types.Router) {
 	router.HandleFunc("POST /loans", h.handleCreateLoan)
 	router.HandleFunc("GET /loans", h.handleGetLoans)
 	router.HandleFunc("GET /loans/{id}", h.handleGetLoanById)
+	router.HandleFunc("POST /loans/{id}/return", h.handleReturnLoan)
 }
 
 // CreateLoan godoc
@@ -31,9 +32,12 @@ func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 // @Accept  json
 // @Produce  json
 // @Param user body types.CreateLoanPayload true "Loan that needs to be created"
-// @Success 200
-// @Failure 400 {object} types.APIError
-// @Failure 500 {object} types.APIError
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 201 {object} types.Loan
+// @Header 201 {string} Location "URL of the created loan"
+// @Failure 400 {object} types.Problem
+// @Failure 422 {object} types.Problem "Copy is lent, or on hold for another user"
+// @Failure 500 {object} types.Problem
 // @Router /loans [post]
 func (h *Handler) handleCreateLoan(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -42,12 +46,17 @@ func (h *Handler) handleCreateLoan(w http.ResponseWriter, r *http.Request) {
 	err := utils.ParseJson(r, &payload)
 
 	if err != nil {
-		log.Printf("error on ParseJson %v", err)
-		utils.WriteError(w, http.StatusBadRequest, err)
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	err = h.repository.CreateLoan(ctx, types.Loan{
+	if err := utils.ValidateStruct(payload); err != nil {
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	loan, err := h.repository.CreateLoan(ctx, types.Loan{
 		UserId:       payload.UserId,
 		BookCopyId:   payload.BookCopyId,
 		Status:       payload.Status,
@@ -56,12 +65,12 @@ func (h *Handler) handleCreateLoan(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
-		log.Printf("error on CreateLoan %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on CreateLoan", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, nil)
+	utils.WriteCreated(w, "/loans/"+loan.Id, loan)
 }
 
 // GetLoans godoc
@@ -74,8 +83,8 @@ func (h *Handler) handleCreateLoan(w http.ResponseWriter, r *http.Request) {
 // @Param status query string false "Filter by Loan Status"
 // @Param bookCopyId query string false "Filter by Book Item ID"
 // @Success 200 {array} types.Loan
-// @Failure 400 {object} types.APIError
-// @Failure 500 {object} types.APIError
+// @Failure 400 {object} types.Problem
+// @Failure 500 {object} types.Problem
 // @Router /loans [get]
 func (h *Handler) handleGetLoans(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
@@ -86,11 +95,11 @@ func (h *Handler) handleGetLoans(w http.ResponseWriter, r *http.Request) {
 	filter["status"] = queryParams.Get("status")
 	filter["bookCopyId"] = queryParams.Get("bookCopyId")
 
-	loans, err := h.repository.GetLoans(filter)
+	loans, err := h.repository.GetLoans(r.Context(), filter)
 
 	if err != nil {
-		log.Printf("error on handleGetLoans %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on handleGetLoans", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 	utils.WriteJSON(w, http.StatusOK, loans)
@@ -104,8 +113,9 @@ func (h *Handler) handleGetLoans(w http.ResponseWriter, r *http.Request) {
 // @Produce  json
 // @Param id path string true "Loan ID"
 // @Success 200 {object} types.Loan
-// @Failure 400 {object} types.APIError
-// @Failure 500 {object} types.APIError
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
 // @Router /loans/{id} [get]
 func (h *Handler) handleGetLoanById(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -113,21 +123,48 @@ func (h *Handler) handleGetLoanById(w http.ResponseWriter, r *http.Request) {
 	err := uuid.Validate(id)
 
 	if err != nil {
-		log.Printf("invalid id %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("invalid id"))
+		slog.WarnContext(r.Context(), "invalid id", "error", err)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
 		return
 	}
 
-	loan, err := h.repository.GetLoan(id)
+	loan, err := h.repository.GetLoan(r.Context(), id)
 
 	if err != nil {
-		log.Printf("error on GetLoan %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on GetLoan", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	if loan == nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("not found"))
+	utils.WriteJSON(w, http.StatusOK, loan)
+}
+
+// ReturnLoan godoc
+// @Summary Return a Loan
+// @Description Records the loan as returned. The copy goes to the oldest waiting hold for its book or work, or back on the shelf.
+// @Tags loans
+// @Produce  json
+// @Param id path string true "Loan ID"
+// @Success 200 {object} types.Loan
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 422 {object} types.Problem "Loan is already returned"
+// @Failure 500 {object} types.Problem
+// @Router /loans/{id}/return [post]
+func (h *Handler) handleReturnLoan(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		slog.WarnContext(r.Context(), "invalid id", "error", err)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	loan, err := h.repository.ReturnLoan(r.Context(), id)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on ReturnLoan", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
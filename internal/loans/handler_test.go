@@ -14,30 +14,46 @@ import (
 )
 
 type mockLoanRepository struct {
-	CreateLoanFunc func(ctx context.Context, loan types.Loan) error
-	GetLoansFunc   func(filter map[string]string) ([]types.Loan, error)
-	GetLoanFunc    func(id string) (*types.Loan, error)
+	CreateLoanFunc  func(ctx context.Context, loan types.Loan) (*types.Loan, error)
+	ReturnLoanFunc  func(ctx context.Context, id string) (*types.Loan, error)
+	GetLoansFunc    func(ctx context.Context, filter map[string]string) ([]types.Loan, error)
+	GetLoanFunc     func(ctx context.Context, id string) (*types.Loan, error)
+	StreamLoansFunc func(ctx context.Context, filter map[string]string, fn func(types.Loan) error) error
 }
 
-func (m *mockLoanRepository) CreateLoan(ctx context.Context, loan types.Loan) error {
+func (m *mockLoanRepository) CreateLoan(ctx context.Context, loan types.Loan) (*types.Loan, error) {
 	if m.CreateLoanFunc != nil {
-		return m.CreateLoanFunc(nil, loan)
+		return m.CreateLoanFunc(ctx, loan)
 	}
-	return nil
+	return &loan, nil
 }
 
-func (m *mockLoanRepository) GetLoans(filter map[string]string) ([]types.Loan, error) {
+func (m *mockLoanRepository) ReturnLoan(ctx context.Context, id string) (*types.Loan, error) {
+	if m.ReturnLoanFunc != nil {
+		return m.ReturnLoanFunc(ctx, id)
+	}
+	return &types.Loan{Id: id}, nil
+}
+
+func (m *mockLoanRepository) GetLoans(ctx context.Context, filter map[string]string) ([]types.Loan, error) {
 	if m.GetLoansFunc != nil {
-		return m.GetLoansFunc(filter)
+		return m.GetLoansFunc(ctx, filter)
 	}
 	return nil, nil
 }
 
-func (m *mockLoanRepository) GetLoan(id string) (*types.Loan, error) {
+func (m *mockLoanRepository) GetLoan(ctx context.Context, id string) (*types.Loan, error) {
 	if m.GetLoanFunc != nil {
-		return m.GetLoanFunc(id)
+		return m.GetLoanFunc(ctx, id)
 	}
-	return nil, nil
+	return nil, ErrLoanNotFound
+}
+
+func (m *mockLoanRepository) StreamLoans(ctx context.Context, filter map[string]string, fn func(types.Loan) error) error {
+	if m.StreamLoansFunc != nil {
+		return m.StreamLoansFunc(ctx, filter, fn)
+	}
+	return nil
 }
 
 func TestLoanHandler(t *testing.T) {
@@ -67,16 +83,17 @@ func TestLoanHandler(t *testing.T) {
 	})
 
 	t.Run("should successfully create a loan", func(t *testing.T) {
-		repository.CreateLoanFunc = func(ctx context.Context, loan types.Loan) error {
-			return nil
+		repository.CreateLoanFunc = func(ctx context.Context, loan types.Loan) (*types.Loan, error) {
+			loan.Id = "123e4567-e89b-12d3-a456-426614174002"
+			return &loan, nil
 		}
 
-		expiringDate := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
-		loanDate := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+		loanDate := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+		expiringDate := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
 
 		payload := types.CreateLoanPayload{
-			UserId:       "user-123",
-			BookCopyId:   "item-456",
+			UserId:       "123e4567-e89b-12d3-a456-426614174000",
+			BookCopyId:   "123e4567-e89b-12d3-a456-426614174001",
 			Status:       "Borrowed",
 			ExpiringDate: expiringDate,
 			LoanDate:     loanDate,
@@ -97,11 +114,70 @@ func TestLoanHandler(t *testing.T) {
 		if rr.Code != http.StatusCreated {
 			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
 		}
+
+		if want := "/v1/loans/123e4567-e89b-12d3-a456-426614174002"; rr.Header().Get("Location") != want {
+			t.Errorf("expected Location %q, got %q", want, rr.Header().Get("Location"))
+		}
+
+		var loan types.Loan
+		if err := json.NewDecoder(rr.Body).Decode(&loan); err != nil {
+			t.Fatal(err)
+		}
+
+		if loan.Id != "123e4567-e89b-12d3-a456-426614174002" || loan.UserId != payload.UserId {
+			t.Errorf("expected the created loan, got %+v", loan)
+		}
+	})
+
+	t.Run("should list every invalid field", func(t *testing.T) {
+		payload := types.CreateLoanPayload{
+			UserId:       "user-123",
+			BookCopyId:   "123e4567-e89b-12d3-a456-426614174001",
+			Status:       "Borrowed",
+			LoanDate:     time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC),
+			ExpiringDate: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		}
+
+		marshalled, _ := json.Marshal(payload)
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		router.HandleFunc("/loans", handler.handleCreateLoan)
+
+		req, err := http.NewRequest(http.MethodPost, "/loans", bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		var problem types.Problem
+		if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []types.FieldError{
+			{Field: "expiringDate", Message: "must be after loanDate"},
+			{Field: "userId", Message: "must be a valid UUID"},
+		}
+
+		if len(problem.Errors) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, problem.Errors)
+		}
+
+		for i := range expected {
+			if problem.Errors[i] != expected[i] {
+				t.Errorf("expected %v, got %v", expected[i], problem.Errors[i])
+			}
+		}
 	})
 
 	t.Run("should fail to fetch a loan if not found", func(t *testing.T) {
-		repository.GetLoanFunc = func(id string) (*types.Loan, error) {
-			return nil, nil
+		repository.GetLoanFunc = func(ctx context.Context, id string) (*types.Loan, error) {
+			return nil, ErrLoanNotFound
 		}
 
 		rr := httptest.NewRecorder()
@@ -120,8 +196,128 @@ func TestLoanHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("should return 400 for an invalid loan id", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		router.HandleFunc("/loans/{id}", handler.handleGetLoanById)
+
+		req, err := http.NewRequest(http.MethodGet, "/loans/not-a-uuid", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should map repository errors to problem responses", func(t *testing.T) {
+		cases := []struct {
+			err    error
+			status int
+			field  string
+		}{
+			{ErrBookCopyNotFound, http.StatusBadRequest, "bookCopyId"},
+			{ErrUserNotFound, http.StatusBadRequest, "userId"},
+			{ErrBookCopyLent, http.StatusUnprocessableEntity, ""},
+			{ErrBookCopyOnHold, http.StatusUnprocessableEntity, ""},
+			{fmt.Errorf("connection reset"), http.StatusInternalServerError, ""},
+		}
+
+		for _, c := range cases {
+			repository.CreateLoanFunc = func(ctx context.Context, loan types.Loan) (*types.Loan, error) {
+				return nil, c.err
+			}
+
+			marshalled, _ := json.Marshal(types.CreateLoanPayload{
+				UserId:       "123e4567-e89b-12d3-a456-426614174000",
+				BookCopyId:   "123e4567-e89b-12d3-a456-426614174001",
+				Status:       "Borrowed",
+				LoanDate:     time.Now(),
+				ExpiringDate: time.Now().Add(24 * time.Hour),
+			})
+
+			rr := httptest.NewRecorder()
+			router := http.NewServeMux()
+			router.HandleFunc("/loans", handler.handleCreateLoan)
+
+			req, err := http.NewRequest(http.MethodPost, "/loans", bytes.NewBuffer(marshalled))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != c.status {
+				t.Errorf("%v: expected status code %d, got %d", c.err, c.status, rr.Code)
+			}
+
+			if got := rr.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("expected problem+json, got %v", got)
+			}
+
+			var problem types.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+
+			if problem.Status != c.status {
+				t.Errorf("expected problem status %d, got %d", c.status, problem.Status)
+			}
+
+			if c.field != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != c.field) {
+				t.Errorf("expected a field error for %v, got %v", c.field, problem.Errors)
+			}
+
+			if c.status == http.StatusInternalServerError && problem.Detail != "internal server error" {
+				t.Errorf("expected internal details to be hidden, got %v", problem.Detail)
+			}
+		}
+	})
+
+	t.Run("should return a loan", func(t *testing.T) {
+		id := "123e4567-e89b-12d3-a456-426614174002"
+
+		cases := []struct {
+			err    error
+			status int
+		}{
+			{nil, http.StatusOK},
+			{ErrLoanNotFound, http.StatusNotFound},
+			{ErrLoanReturned, http.StatusUnprocessableEntity},
+		}
+
+		for _, c := range cases {
+			var gotId string
+			repository.ReturnLoanFunc = func(ctx context.Context, loanId string) (*types.Loan, error) {
+				gotId = loanId
+				if c.err != nil {
+					return nil, c.err
+				}
+				return &types.Loan{Id: loanId, Status: "returned"}, nil
+			}
+
+			rr := httptest.NewRecorder()
+			router := http.NewServeMux()
+			handler.RegisterRoutes(router)
+
+			req, err := http.NewRequest(http.MethodPost, "/loans/"+id+"/return", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != c.status || gotId != id {
+				t.Errorf("%v: expected status code %d for %s, got %d for %q", c.err, c.status, id, rr.Code, gotId)
+			}
+		}
+	})
+
 	t.Run("should fetch all loans successfully", func(t *testing.T) {
-		repository.GetLoansFunc = func(filter map[string]string) ([]types.Loan, error) {
+		repository.GetLoansFunc = func(ctx context.Context, filter map[string]string) ([]types.Loan, error) {
 			return []types.Loan{
 				{UserId: "user-1", BookCopyId: "item-1", Status: "Borrowed"},
 				{UserId: "user-2", BookCopyId: "item-2", Status: "Returned"},
@@ -150,7 +346,7 @@ func TestLoanHandler(t *testing.T) {
 
 		var gotValue string
 
-		repository.GetLoansFunc = func(filter map[string]string) ([]types.Loan, error) {
+		repository.GetLoansFunc = func(ctx context.Context, filter map[string]string) ([]types.Loan, error) {
 			gotValue = filter[filterKey]
 			return []types.Loan{
 				{UserId: "user-1", BookCopyId: "item-1", Status: "Borrowed"},
@@ -3,20 +3,41 @@ package loans
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/gfteix/book_loan_system/internal/holds"
+	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/pkg/tracing"
 	"github.com/gfteix/book_loan_system/types"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var ErrBookCopyNotFound = errs.Field("bookCopyId", "book copy not found")
+var ErrUserNotFound = errs.Field("userId", "user not found")
+var ErrBookCopyLent = errs.PolicyViolation("book copy is already lent")
+var ErrBookCopyOnHold = errs.PolicyViolation("book copy is on hold for another user")
+var ErrBookCopyUnavailable = errs.PolicyViolation("book copy is not available")
+var ErrLoanNotFound = errs.NotFound("loan not found")
+var ErrLoanReturned = errs.PolicyViolation("loan is already returned")
+
+const foreignKeyViolation = "23503"
+
 type Repository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout time.Duration
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, timeout time.Duration) *Repository {
+	return &Repository{db: db, timeout: timeout}
 }
 
 func (r *Repository) GetBookCopyById(ctx context.Context, tx *sql.Tx, id string) (*types.BookCopy, error) {
@@ -42,52 +63,119 @@ func (r *Repository) GetBookCopyById(ctx context.Context, tx *sql.Tx, id string)
 		return bookCopy, nil
 	}
 
-	return nil, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrBookCopyNotFound
+}
+
+// CreateLoan marks the copy as lent and records the loan in one transaction,
+// fulfilling the borrower's hold when the copy was set aside for them,
+// traced as a single span around its statements. It returns the loan as
+// stored.
+func (r *Repository) CreateLoan(ctx context.Context, loan types.Loan) (*types.Loan, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	ctx, span := tracing.Tracer().Start(ctx, "loans.CreateLoan", trace.WithAttributes(
+		attribute.String("loan.user_id", loan.UserId),
+		attribute.String("loan.book_copy_id", loan.BookCopyId),
+	))
+	defer span.End()
+
+	created, err := r.createLoan(ctx, loan)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return created, err
 }
 
-func (r *Repository) CreateLoan(ctx context.Context, loan types.Loan) error {
-	fail := func(tx *sql.Tx, err error) error {
-		fmt.Printf("transaction failure %v", err)
+func (r *Repository) createLoan(ctx context.Context, loan types.Loan) (*types.Loan, error) {
+	fail := func(tx *sql.Tx, err error) (*types.Loan, error) {
+		slog.ErrorContext(ctx, "transaction failure", "error", err)
 
 		er := tx.Rollback()
 
 		if er != nil {
-			fmt.Printf("rollback fail %v", er)
+			slog.ErrorContext(ctx, "rollback fail", "error", er)
 		}
 
-		return err
+		return nil, err
 	}
 
 	tx, err := r.db.BeginTx(ctx, nil)
 
 	if err != nil {
-		log.Printf("error while starting transaction %v", err)
-		return err
+		slog.ErrorContext(ctx, "error while starting transaction", "error", err)
+		return nil, err
+	}
+
+	var userExists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", loan.UserId).Scan(&userExists)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "error while checking user", "error", err)
+		return fail(tx, err)
+	}
+
+	if !userExists {
+		tx.Rollback()
+		return nil, ErrUserNotFound
 	}
 
 	bookCopy, err := r.GetBookCopyById(ctx, tx, loan.BookCopyId)
 
+	if errors.Is(err, ErrBookCopyNotFound) {
+		tx.Rollback()
+		return nil, err
+	}
+
 	if err != nil {
-		log.Printf("error while getting book item %v", err)
-		return err
+		slog.ErrorContext(ctx, "error while getting book item", "error", err)
+		return fail(tx, err)
 	}
 
-	if bookCopy == nil {
-		return fmt.Errorf("no related book item found for %v", loan.BookCopyId)
+	switch strings.ToLower(bookCopy.Status) {
+	case types.BookCopyStatusAvailable:
+	case types.BookCopyStatusOnHold:
+		fulfilled, err := holds.FulfillHold(ctx, tx, loan.UserId, loan.BookCopyId)
+		if err != nil {
+			slog.ErrorContext(ctx, "error while fulfilling hold", "error", err)
+			return fail(tx, err)
+		}
+
+		if !fulfilled {
+			tx.Rollback()
+			return nil, ErrBookCopyOnHold
+		}
+	case types.BookCopyStatusLent:
+		tx.Rollback()
+		return nil, ErrBookCopyLent
+	default:
+		tx.Rollback()
+		return nil, ErrBookCopyUnavailable
 	}
 
 	_, err = tx.ExecContext(ctx, "UPDATE book_copies SET status = 'lent' WHERE id = $1", loan.BookCopyId)
 
 	if err != nil {
-		log.Printf("error while updating book item %v", err)
+		slog.ErrorContext(ctx, "error while updating book item", "error", err)
 		return fail(tx, err)
 	}
 
-	_, err = tx.ExecContext(ctx, "INSERT INTO loans (id, user_id, book_item_id, status, expiring_date, return_date, loan_date) VALUES ($1, $2, $3, $4, $5, $6, $7)",
-		uuid.NewString(), loan.UserId, loan.BookCopyId, loan.Status, loan.ExpiringDate, loan.ReturnDate, loan.LoanDate)
+	created, err := insertLoan(ctx, tx, loan)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation && pgErr.ConstraintName == "fk_user_id" {
+		tx.Rollback()
+		return nil, ErrUserNotFound
+	}
 
 	if err != nil {
-		log.Printf("error while creating loan %v", err)
+		slog.ErrorContext(ctx, "error while creating loan", "error", err)
 		return fail(tx, err)
 	}
 
@@ -95,29 +183,135 @@ func (r *Repository) CreateLoan(ctx context.Context, loan types.Loan) error {
 		return fail(tx, err)
 	}
 
-	return nil
+	return created, nil
 }
 
-func (r *Repository) GetLoan(id string) (*types.Loan, error) {
-	rows, err := r.db.Query("SELECT id, user_id, book_item_id, status, expiring_date, return_date, loan_date, created_at FROM loans WHERE id = $1", id)
+func insertLoan(ctx context.Context, tx *sql.Tx, loan types.Loan) (*types.Loan, error) {
+	rows, err := tx.QueryContext(ctx, "INSERT INTO loans (id, user_id, book_item_id, status, expiring_date, return_date, loan_date) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, user_id, book_item_id, status, expiring_date, return_date, loan_date, created_at",
+		uuid.NewString(), loan.UserId, loan.BookCopyId, loan.Status, loan.ExpiringDate, loan.ReturnDate, loan.LoanDate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var loan *types.Loan
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, sql.ErrNoRows
+	}
 
-	for rows.Next() {
-		loan, err = scanRowIntoLoan(rows)
-		if err != nil {
+	return scanRowIntoLoan(rows)
+}
+
+// ReturnLoan records the loan as returned and releases its copy to the next
+// waiting hold, or back on the shelf, in one transaction.
+func (r *Repository) ReturnLoan(ctx context.Context, id string) (*types.Loan, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var copyId string
+	var returnDate sql.NullTime
+
+	err = tx.QueryRowContext(ctx, "SELECT book_item_id, return_date FROM loans WHERE id = $1 FOR UPDATE", id).Scan(&copyId, &returnDate)
+	if err == sql.ErrNoRows {
+		return nil, ErrLoanNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if returnDate.Valid {
+		return nil, ErrLoanReturned
+	}
+
+	if _, err := r.GetBookCopyById(ctx, tx, copyId); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "UPDATE loans SET status = 'returned', return_date = CURRENT_TIMESTAMP WHERE id = $1 RETURNING id, user_id, book_item_id, status, expiring_date, return_date, loan_date, created_at", id)
+	if err != nil {
+		return nil, err
+	}
+
+	returned, err := scanReturnedLoan(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := holds.ReleaseCopy(ctx, tx, copyId); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return returned, nil
+}
+
+func scanReturnedLoan(rows *sql.Rows) (*types.Loan, error) {
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
 			return nil, err
 		}
+
+		return nil, sql.ErrNoRows
 	}
 
-	return loan, nil
+	return scanRowIntoLoan(rows)
+}
+
+func (r *Repository) GetLoan(ctx context.Context, id string) (*types.Loan, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, user_id, book_item_id, status, expiring_date, return_date, loan_date, created_at FROM loans WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanRowIntoLoan(rows)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrLoanNotFound
+}
+
+func (r *Repository) GetLoans(ctx context.Context, filters map[string]string) ([]types.Loan, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	loans := make([]types.Loan, 0)
+
+	err := r.StreamLoans(ctx, filters, func(loan types.Loan) error {
+		loans = append(loans, loan)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loans, nil
 }
 
-func (r *Repository) GetLoans(filters map[string]string) ([]types.Loan, error) {
+// StreamLoans calls fn for every loan matching filters, one row at a time.
+func (r *Repository) StreamLoans(ctx context.Context, filters map[string]string, fn func(types.Loan) error) error {
 	q := ("SELECT id, user_id, book_item_id, status, expiring_date, return_date, loan_date, created_at FROM loans")
 
 	where := make([]string, 0)
@@ -158,24 +352,25 @@ func (r *Repository) GetLoans(filters map[string]string) ([]types.Loan, error) {
 		args[i] = v
 	}
 
-	rows, err := r.db.Query(q, args...)
+	rows, err := r.db.QueryContext(ctx, q, args...)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	loans := make([]types.Loan, 0)
-
 	for rows.Next() {
 		loan, err := scanRowIntoLoan(rows)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		if err := fn(*loan); err != nil {
+			return err
 		}
-		loans = append(loans, *loan)
 	}
 
-	return loans, nil
+	return rows.Err()
 }
 
 func scanRowIntoLoan(rows *sql.Rows) (*types.Loan, error) {
@@ -0,0 +1,125 @@
+// Package reminders publishes an event for every open loan that expires
+// today or within the next two days.
+package reminders
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/events"
+	"github.com/gfteix/book_loan_system/pkg/metrics"
+	"github.com/gfteix/book_loan_system/pkg/mq"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+// publishTimeout bounds each publish, including the wait for the broker's
+// confirmation.
+const publishTimeout = 5 * time.Second
+
+// DueLoans returns the loans that are not returned and expire today or in
+// the next two days.
+func DueLoans(ctx context.Context, db *sql.DB) ([]types.Loan, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, expiring_date, user_id, book_item_id FROM loans WHERE return_date IS NULL AND expiring_date BETWEEN CURRENT_DATE AND CURRENT_DATE + INTERVAL '2 days'")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loans := make([]types.Loan, 0)
+
+	for rows.Next() {
+		loan := new(types.Loan)
+
+		err := rows.Scan(
+			&loan.Id,
+			&loan.ExpiringDate,
+			&loan.UserId,
+			&loan.BookCopyId,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+		loans = append(loans, *loan)
+	}
+
+	return loans, rows.Err()
+}
+
+// eventFor returns the event to publish for loan on the day today, or nil if
+// the loan is not due.
+func eventFor(loan types.Loan, today time.Time) events.Payload {
+	today = today.Truncate(24 * time.Hour)
+	expiringDate := loan.ExpiringDate.Truncate(24 * time.Hour)
+
+	daysDiff := expiringDate.Sub(today).Hours() / 24
+
+	if daysDiff == 0 {
+		return events.LoanExpiredV1{
+			LoanId:       loan.Id,
+			UserId:       loan.UserId,
+			ExpiringDate: loan.ExpiringDate,
+		}
+	}
+
+	if daysDiff > 0 && daysDiff <= 2 {
+		return events.LoanExpiringV1{
+			LoanId:       loan.Id,
+			UserId:       loan.UserId,
+			ExpiringDate: loan.ExpiringDate,
+		}
+	}
+
+	return nil
+}
+
+// Publish publishes the event for each due loan. A failed publish does not
+// stop the others; the returned error reports how many failed.
+func Publish(ctx context.Context, p mq.Publisher, loans []types.Loan, m *metrics.Reminders) error {
+	failed := 0
+	today := time.Now()
+
+	for _, l := range loans {
+		payload := eventFor(l, today)
+		if payload == nil {
+			continue
+		}
+
+		if err := publishEvent(ctx, p, l.Id, payload, m); err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d events could not be published", failed, len(loans))
+	}
+
+	return nil
+}
+
+func publishEvent(ctx context.Context, p mq.Publisher, loanId string, payload events.Payload, m *metrics.Reminders) error {
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	event, err := events.New(ctx, "cmd/reminders", payload)
+
+	if err == nil {
+		err = events.Publish(ctx, p, event)
+	}
+
+	if err != nil {
+		slog.ErrorContext(ctx, "fail to publish event", "loanId", loanId, "error", err)
+		m.PublishFailures.Inc()
+		return err
+	}
+
+	m.EventsPublished.WithLabelValues(event.Type).Inc()
+
+	slog.InfoContext(ctx, "sent event", "eventId", event.EventId, "type", event.Type, "loanId", loanId)
+
+	return nil
+}
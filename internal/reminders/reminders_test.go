@@ -0,0 +1,36 @@
+package reminders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/events"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+func TestEventFor(t *testing.T) {
+	today := time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC)
+
+	for name, tc := range map[string]struct {
+		expiring time.Time
+		want     string
+	}{
+		"should report loans expiring today as expired": {time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), events.LoanExpired},
+		"should report loans expiring tomorrow":         {time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), events.LoanExpiring},
+		"should report loans expiring in two days":      {time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC), events.LoanExpiring},
+		"should skip loans expiring later":              {time.Date(2026, 10, 22, 0, 0, 0, 0, time.UTC), ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			payload := eventFor(types.Loan{Id: "loan-id", UserId: "user-id", ExpiringDate: tc.expiring}, today)
+
+			got := ""
+			if payload != nil {
+				got = payload.EventType()
+			}
+
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
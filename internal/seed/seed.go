@@ -0,0 +1,115 @@
+// Package seed builds the sample data cmd/seed inserts. It is kept out of the
+// schema migrations so it never runs, or is rolled back, in production.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+// Set is a fixture set. Copies and loans refer to the books, users and
+// copies before them by index, as their IDs are only known once inserted.
+type Set struct {
+	Users  []types.User
+	Books  []types.Book
+	Copies []Copy
+	Loans  []Loan
+}
+
+// Copy is a copy of Books[Book].
+type Copy struct {
+	Book      int
+	Location  string
+	Condition string
+}
+
+// Loan lends Copies[Copy] to Users[User].
+type Loan struct {
+	User         int
+	Copy         int
+	LoanDate     time.Time
+	ExpiringDate time.Time
+}
+
+// Seeder inserts sets through the repositories, so seeded rows are written
+// the same way as the API writes them.
+type Seeder struct {
+	users types.UserRepository
+	books types.BookRepository
+	loans types.LoanRepository
+}
+
+func NewSeeder(users types.UserRepository, books types.BookRepository, loans types.LoanRepository) *Seeder {
+	return &Seeder{users: users, books: books, loans: loans}
+}
+
+// Insert creates every user, book, copy and loan in the set, stopping at the
+// first error.
+func (s *Seeder) Insert(ctx context.Context, set Set) error {
+	userIds := make([]string, len(set.Users))
+
+	for i, user := range set.Users {
+		created, err := s.users.CreateUser(ctx, user)
+		if err != nil {
+			return fmt.Errorf("creating user %s: %w", user.Email, err)
+		}
+
+		userIds[i] = created.Id
+	}
+
+	bookIds := make([]string, len(set.Books))
+
+	for i, book := range set.Books {
+		created, err := s.books.CreateBook(ctx, book)
+		if err != nil {
+			return fmt.Errorf("creating book %s: %w", book.ISBN, err)
+		}
+
+		bookIds[i] = created.Id
+	}
+
+	copyIds := make([]string, len(set.Copies))
+
+	for i, c := range set.Copies {
+		created, err := s.books.CreateBookCopy(ctx, types.BookCopy{
+			BookId:    bookIds[c.Book],
+			Location:  c.Location,
+			Condition: c.Condition,
+			Status:    types.BookCopyStatusAvailable,
+		})
+		if err != nil {
+			return fmt.Errorf("creating a copy of book %s: %w", set.Books[c.Book].ISBN, err)
+		}
+
+		copyIds[i] = created.Id
+	}
+
+	for _, loan := range set.Loans {
+		_, err := s.loans.CreateLoan(ctx, types.Loan{
+			UserId:       userIds[loan.User],
+			BookCopyId:   copyIds[loan.Copy],
+			Status:       "active",
+			LoanDate:     loan.LoanDate,
+			ExpiringDate: loan.ExpiringDate,
+		})
+		if err != nil {
+			return fmt.Errorf("creating a loan for %s: %w", set.Users[loan.User].Email, err)
+		}
+	}
+
+	return nil
+}
+
+// Empty reports whether the database has no users, books or loans. Sets use
+// fixed emails and ISBNs, so they only insert cleanly into an empty database.
+func Empty(ctx context.Context, db *sql.DB) (bool, error) {
+	var exists bool
+
+	err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users) OR EXISTS (SELECT 1 FROM books) OR EXISTS (SELECT 1 FROM loans)").Scan(&exists)
+
+	return !exists, err
+}
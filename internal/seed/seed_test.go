@@ -0,0 +1,168 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/isbn"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+// The mocks embed the repository interfaces; the seeder only creates, so any
+// other call panics.
+type mockUserRepository struct {
+	types.UserRepository
+	CreateUserFunc func(ctx context.Context, user types.User) (*types.User, error)
+}
+
+func (m *mockUserRepository) CreateUser(ctx context.Context, user types.User) (*types.User, error) {
+	return m.CreateUserFunc(ctx, user)
+}
+
+type mockBookRepository struct {
+	types.BookRepository
+	CreateBookFunc     func(ctx context.Context, book types.Book) (*types.Book, error)
+	CreateBookCopyFunc func(ctx context.Context, bookCopy types.BookCopy) (*types.BookCopy, error)
+}
+
+func (m *mockBookRepository) CreateBook(ctx context.Context, book types.Book) (*types.Book, error) {
+	return m.CreateBookFunc(ctx, book)
+}
+
+func (m *mockBookRepository) CreateBookCopy(ctx context.Context, bookCopy types.BookCopy) (*types.BookCopy, error) {
+	return m.CreateBookCopyFunc(ctx, bookCopy)
+}
+
+type mockLoanRepository struct {
+	types.LoanRepository
+	CreateLoanFunc func(ctx context.Context, loan types.Loan) (*types.Loan, error)
+}
+
+func (m *mockLoanRepository) CreateLoan(ctx context.Context, loan types.Loan) (*types.Loan, error) {
+	return m.CreateLoanFunc(ctx, loan)
+}
+
+func TestSeeder(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	newSeeder := func(loans *[]types.Loan, failBook string) *Seeder {
+		users := &mockUserRepository{CreateUserFunc: func(ctx context.Context, user types.User) (*types.User, error) {
+			user.Id = "user:" + user.Email
+			return &user, nil
+		}}
+
+		books := &mockBookRepository{
+			CreateBookFunc: func(ctx context.Context, book types.Book) (*types.Book, error) {
+				if book.ISBN == failBook {
+					return nil, errors.New("duplicate isbn")
+				}
+
+				book.Id = "book:" + book.ISBN
+				return &book, nil
+			},
+			CreateBookCopyFunc: func(ctx context.Context, bookCopy types.BookCopy) (*types.BookCopy, error) {
+				bookCopy.Id = "copy:" + bookCopy.BookId
+				return &bookCopy, nil
+			},
+		}
+
+		loanRepo := &mockLoanRepository{CreateLoanFunc: func(ctx context.Context, loan types.Loan) (*types.Loan, error) {
+			*loans = append(*loans, loan)
+			return &loan, nil
+		}}
+
+		return NewSeeder(users, books, loanRepo)
+	}
+
+	t.Run("should link loans to the created users and copies", func(t *testing.T) {
+		var loans []types.Loan
+
+		if err := newSeeder(&loans, "").Insert(context.Background(), Demo(now)); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{"copy:book:9780143058144", "copy:book:9780140449242"}
+
+		if len(loans) != len(want) {
+			t.Fatalf("expected %d loans, got %+v", len(want), loans)
+		}
+
+		for i, loan := range loans {
+			if loan.UserId != "user:johndoe@example.com" || loan.BookCopyId != want[i] {
+				t.Errorf("expected loan %d of %s for johndoe, got %+v", i, want[i], loan)
+			}
+		}
+	})
+
+	t.Run("should stop at the first error", func(t *testing.T) {
+		var loans []types.Loan
+
+		err := newSeeder(&loans, "9780199232765").Insert(context.Background(), Demo(now))
+
+		if err == nil || len(loans) != 0 {
+			t.Errorf("expected an error and no loans, got %v and %+v", err, loans)
+		}
+	})
+}
+
+func TestLoadTest(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should generate the same set for the same seed", func(t *testing.T) {
+		if !reflect.DeepEqual(LoadTest(now, 200, 7), LoadTest(now, 200, 7)) {
+			t.Error("expected equal sets")
+		}
+
+		if reflect.DeepEqual(LoadTest(now, 200, 7), LoadTest(now, 200, 8)) {
+			t.Error("expected different seeds to give different sets")
+		}
+	})
+
+	t.Run("should generate insertable rows", func(t *testing.T) {
+		n := 500
+		set := LoadTest(now, n, 1)
+
+		if len(set.Users) != n || len(set.Books) != n || len(set.Copies) != 2*n || len(set.Loans) != n {
+			t.Fatalf("expected %d users, books and loans and %d copies, got %d, %d, %d and %d",
+				n, 2*n, len(set.Users), len(set.Books), len(set.Loans), len(set.Copies))
+		}
+
+		unique := func(name string, keys []string) {
+			seen := map[string]bool{}
+			for _, key := range keys {
+				if seen[key] {
+					t.Errorf("duplicate %s %s", name, key)
+				}
+				seen[key] = true
+			}
+		}
+
+		var emails, isbns, copies []string
+
+		for _, user := range set.Users {
+			emails = append(emails, user.Email)
+		}
+
+		for _, book := range set.Books {
+			if !isbn.Valid(book.ISBN) {
+				t.Errorf("invalid isbn %s", book.ISBN)
+			}
+			isbns = append(isbns, book.ISBN)
+		}
+
+		for _, loan := range set.Loans {
+			if loan.User >= n || loan.Copy >= 2*n {
+				t.Errorf("loan out of range: %+v", loan)
+			}
+			copies = append(copies, fmt.Sprint(loan.Copy))
+		}
+
+		unique("email", emails)
+		unique("isbn", isbns)
+		unique("lent copy", copies)
+	})
+}
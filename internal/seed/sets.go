@@ -0,0 +1,147 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/isbn"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+// loanPeriod is how long seeded loans run.
+const loanPeriod = 28 * 24 * time.Hour
+
+// Minimal is a single user and a single available book, enough to try each
+// endpoint.
+func Minimal() Set {
+	return Set{
+		Users: []types.User{{Name: "John Doe", Email: "johndoe@example.com"}},
+		Books: []types.Book{
+			book("The Metamorphosis", "A novella by Kafka", "9780805210576", 104, []string{"Fiction"}, "Franz Kafka"),
+		},
+		Copies: []Copy{{Book: 0, Location: "Shelf A", Condition: "New"}},
+	}
+}
+
+// Demo is a small library: a few classics with one copy each, and two loans
+// due the day after now, so the reminders job has something to send.
+func Demo(now time.Time) Set {
+	set := Set{
+		Users: []types.User{
+			{Name: "John Doe", Email: "johndoe@example.com"},
+			{Name: "Jane Roe", Email: "janeroe@example.com"},
+		},
+		Books: []types.Book{
+			book("Crime and Punishment", "A psychological novel by Dostoyevsky", "9780143058144", 671, []string{"Fiction", "Russian literature"}, "Fyodor Dostoyevsky"),
+			book("The Brothers Karamazov", "A novel by Dostoyevsky about faith and doubt", "9780140449242", 824, []string{"Fiction", "Russian literature"}, "Fyodor Dostoyevsky"),
+			book("War and Peace", "A historical novel by Tolstoy", "9780199232765", 1392, []string{"Fiction", "Russian literature", "History"}, "Leo Tolstoy"),
+			book("Anna Karenina", "A romantic tragedy by Tolstoy", "9780143035008", 864, []string{"Fiction", "Russian literature"}, "Leo Tolstoy"),
+			book("The Metamorphosis", "A novella by Kafka", "9780805210576", 104, []string{"Fiction"}, "Franz Kafka"),
+		},
+	}
+
+	for i := range set.Books {
+		set.Copies = append(set.Copies, Copy{Book: i, Location: "Shelf A", Condition: "New"})
+	}
+
+	due := now.AddDate(0, 0, 1)
+	set.Loans = []Loan{
+		{User: 0, Copy: 0, LoanDate: due.Add(-loanPeriod), ExpiringDate: due},
+		{User: 0, Copy: 1, LoanDate: due.Add(-loanPeriod), ExpiringDate: due},
+	}
+
+	return set
+}
+
+var (
+	firstNames = []string{"Ada", "Alan", "Barbara", "Claude", "Donald", "Edsger", "Frances", "Grace", "John", "Ken", "Leslie", "Margaret", "Niklaus", "Radia", "Robin", "Tony"}
+	lastNames  = []string{"Allen", "Dijkstra", "Hamilton", "Hoare", "Hopper", "Kay", "Knuth", "Lamport", "Liskov", "Lovelace", "McCarthy", "Milner", "Perlman", "Ritchie", "Shannon", "Thompson", "Turing", "Wirth"}
+	adjectives = []string{"Silent", "Burning", "Hidden", "Last", "Broken", "Distant", "Golden", "Hollow", "Northern", "Quiet", "Scarlet", "Winter"}
+	nouns      = []string{"River", "Garden", "Empire", "Harbor", "Letters", "Machine", "Mountain", "Orchard", "Signal", "Station", "Tide", "Voyage"}
+	subjects   = []string{"Fiction", "History", "Science", "Poetry", "Philosophy", "Mystery", "Travel", "Biography"}
+	shelves    = []string{"Shelf A", "Shelf B", "Shelf C", "Shelf D", "Stacks"}
+	conditions = []string{"New", "Good", "Worn"}
+)
+
+// LoadTest generates n users, n books with two copies each and n active
+// loans. The same seed yields the same names, titles, ISBNs and loans;
+// dates are relative to now, spread so some loans are overdue and some due
+// soon.
+func LoadTest(now time.Time, n int, seed uint64) Set {
+	r := rand.New(rand.NewPCG(seed, seed))
+
+	pick := func(list []string) string { return list[r.IntN(len(list))] }
+
+	set := Set{
+		Users:  make([]types.User, n),
+		Books:  make([]types.Book, n),
+		Copies: make([]Copy, 0, 2*n),
+		Loans:  make([]Loan, n),
+	}
+
+	for i := range n {
+		set.Users[i] = types.User{
+			Name:  pick(firstNames) + " " + pick(lastNames),
+			Email: fmt.Sprintf("user%06d@example.com", i),
+		}
+	}
+
+	// Authors are shared between books, about ten books each.
+	authors := make([]string, max(n/10, 1))
+	for i := range authors {
+		authors[i] = fmt.Sprintf("%s %s %d", pick(firstNames), pick(lastNames), i)
+	}
+
+	for i := range n {
+		title := fmt.Sprintf("The %s %s, Volume %d", pick(adjectives), pick(nouns), i+1)
+
+		bookSubjects := []string{pick(subjects)}
+		if extra := pick(subjects); extra != bookSubjects[0] {
+			bookSubjects = append(bookSubjects, extra)
+		}
+
+		// To13 only reads the first nine digits, so any check digit works.
+		set.Books[i] = book(title, "Generated for load testing", isbn.To13(fmt.Sprintf("%09d0", i)), 80+r.IntN(1120), bookSubjects, authors[r.IntN(len(authors))])
+
+		for range 2 {
+			set.Copies = append(set.Copies, Copy{Book: i, Location: pick(shelves), Condition: pick(conditions)})
+		}
+	}
+
+	// Each loan takes a different copy, so none is lent twice.
+	copies := r.Perm(len(set.Copies))
+
+	for i := range n {
+		loanDate := now.AddDate(0, 0, -r.IntN(42))
+		set.Loans[i] = Loan{
+			User:         r.IntN(n),
+			Copy:         copies[i],
+			LoanDate:     loanDate,
+			ExpiringDate: loanDate.Add(loanPeriod),
+		}
+	}
+
+	return set
+}
+
+func book(title, description, isbn13 string, pages int, subjectNames []string, authorNames ...string) types.Book {
+	b := types.Book{
+		Title:         title,
+		Description:   description,
+		ISBN:          isbn13,
+		Author:        strings.Join(authorNames, ", "),
+		NumberOfPages: pages,
+	}
+
+	for _, name := range authorNames {
+		b.Authors = append(b.Authors, types.Author{Name: name})
+	}
+
+	for _, name := range subjectNames {
+		b.Subjects = append(b.Subjects, types.Subject{Name: name})
+	}
+
+	return b
+}
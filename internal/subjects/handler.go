@@ -0,0 +1,110 @@
+package subjects
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gfteix/book_loan_system/pkg/utils"
+	"github.com/gfteix/book_loan_system/types"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	repository     types.SubjectRepository
+	bookRepository types.BookRepository
+}
+
+func NewHandler(repository types.SubjectRepository, bookRepository types.BookRepository) *Handler {
+	return &Handler{repository: repository, bookRepository: bookRepository}
+}
+
+func (h *Handler) RegisterRoutes(router types.Router) {
+	router.HandleFunc("GET /subjects", h.handleGetSubjects)
+	router.HandleFunc("GET /subjects/{id}", h.handleGetSubjectById)
+	router.HandleFunc("GET /subjects/{id}/books", h.handleGetSubjectBooks)
+}
+
+// handleGetSubjects godoc
+// @Summary Get subjects
+// @Description Retrieves subjects with the number of books filed under each one
+// @Tags subjects
+// @Accept  json
+// @Produce  json
+// @Param name query string false "Filter by part of the subject name"
+// @Success 200 {array} types.Subject
+// @Failure 500 {object} types.Problem
+// @Router /subjects [get]
+func (h *Handler) handleGetSubjects(w http.ResponseWriter, r *http.Request) {
+	filter := map[string]string{
+		"name": r.URL.Query().Get("name"),
+	}
+
+	subjects, err := h.repository.GetSubjects(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetSubjects", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, subjects)
+}
+
+// handleGetSubjectById godoc
+// @Summary Get a subject by ID
+// @Description Retrieves a subject by its ID
+// @Tags subjects
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Subject ID"
+// @Success 200 {object} types.Subject
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /subjects/{id} [get]
+func (h *Handler) handleGetSubjectById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	subject, err := h.repository.GetSubjectById(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetSubjectById", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, subject)
+}
+
+// handleGetSubjectBooks godoc
+// @Summary Get books by subject
+// @Description Retrieves every book filed under the subject
+// @Tags subjects
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Subject ID"
+// @Success 200 {array} types.Book
+// @Failure 400 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /subjects/{id}/books [get]
+func (h *Handler) handleGetSubjectBooks(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	books, err := h.bookRepository.GetBooks(r.Context(), map[string]string{"subjectId": id})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetBooks", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, books)
+}
@@ -0,0 +1,94 @@
+package subjects
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+type mockSubjectRepository struct {
+	GetSubjectsFunc    func(ctx context.Context, filter map[string]string) ([]types.Subject, error)
+	GetSubjectByIdFunc func(ctx context.Context, id string) (*types.Subject, error)
+}
+
+func (m *mockSubjectRepository) GetSubjects(ctx context.Context, filter map[string]string) ([]types.Subject, error) {
+	if m.GetSubjectsFunc != nil {
+		return m.GetSubjectsFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+func (m *mockSubjectRepository) GetSubjectById(ctx context.Context, id string) (*types.Subject, error) {
+	if m.GetSubjectByIdFunc != nil {
+		return m.GetSubjectByIdFunc(ctx, id)
+	}
+	return nil, ErrSubjectNotFound
+}
+
+type mockBookRepository struct {
+	types.BookRepository
+	GetBooksFunc func(ctx context.Context, filter map[string]string) ([]types.Book, error)
+}
+
+func (m *mockBookRepository) GetBooks(ctx context.Context, filter map[string]string) ([]types.Book, error) {
+	if m.GetBooksFunc != nil {
+		return m.GetBooksFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+func TestSubjectHandler(t *testing.T) {
+	repository := &mockSubjectRepository{}
+	bookRepository := &mockBookRepository{}
+	handler := NewHandler(repository, bookRepository)
+
+	t.Run("should fetch books by subject id", func(t *testing.T) {
+		id := "123e4567-e89b-12d3-a456-426614174000"
+		var gotFilter map[string]string
+
+		bookRepository.GetBooksFunc = func(ctx context.Context, filter map[string]string) ([]types.Book, error) {
+			gotFilter = filter
+			return []types.Book{}, nil
+		}
+
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/subjects/%s/books", id), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if gotFilter["subjectId"] != id {
+			t.Errorf("expected subjectId filter %v, got %v", id, gotFilter["subjectId"])
+		}
+	})
+
+	t.Run("should fail with an invalid subject id", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(http.MethodGet, "/subjects/fiction/books", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package subjects
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+var ErrSubjectNotFound = errs.NotFound("subject not found")
+
+type Repository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewRepository(db *sql.DB, timeout time.Duration) *Repository {
+	return &Repository{db: db, timeout: timeout}
+}
+
+const subjectColumns = "s.id, s.name, (SELECT COUNT(*) FROM book_subjects bs WHERE bs.subject_id = s.id)"
+
+func scanRowIntoSubject(rows *sql.Rows) (*types.Subject, error) {
+	subject := new(types.Subject)
+	err := rows.Scan(
+		&subject.Id,
+		&subject.Name,
+		&subject.BookCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return subject, nil
+}
+
+func (r *Repository) GetSubjects(ctx context.Context, filters map[string]string) ([]types.Subject, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	q := "SELECT " + subjectColumns + " FROM subjects s"
+
+	args := make([]interface{}, 0)
+
+	if v := filters["name"]; v != "" {
+		args = append(args, "%"+v+"%")
+		q = fmt.Sprintf("%v WHERE s.name ILIKE $%v", q, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, q+" ORDER BY s.name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := make([]types.Subject, 0)
+
+	for rows.Next() {
+		subject, err := scanRowIntoSubject(rows)
+		if err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, *subject)
+	}
+
+	return subjects, rows.Err()
+}
+
+func (r *Repository) GetSubjectById(ctx context.Context, id string) (*types.Subject, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+subjectColumns+" FROM subjects s WHERE s.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanRowIntoSubject(rows)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrSubjectNotFound
+}
@@ -1,13 +1,14 @@
 package users
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 
+	"github.com/gfteix/book_loan_system/pkg/errs"
 	"github.com/gfteix/book_loan_system/pkg/utils"
 	"github.com/gfteix/book_loan_system/types"
-	"github.com/go-playground/validator"
 	"github.com/google/uuid"
 )
 
@@ -19,7 +20,7 @@ func NewHandler(repository types.UserRepository) *Handler {
 	return &Handler{repository: repository}
 }
 
-func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+func (h *Handler) RegisterRoutes(router types.Router) {
 	router.HandleFunc("POST /users", h.handleCreateUser)
 	router.HandleFunc("GET /users", h.handleGetUsers)
 	router.HandleFunc("GET /users/{id}", h.handleGetUserById)
@@ -33,11 +34,11 @@ func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 // @Produce  json
 // @Param id path int true "User ID"
 // @Success 200 {object} types.User
-// @Failure 400 {object} types.APIError
-// @Failure 404 {object} types.APIError
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
 // @Router /users/{id} [get]
 func (h *Handler) handleGetUserById(w http.ResponseWriter, r *http.Request) {
-	log.Print("handleGetUserById")
+	slog.DebugContext(r.Context(), "handleGetUserById")
 
 	id := r.PathValue("id")
 
@@ -48,16 +49,11 @@ func (h *Handler) handleGetUserById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.repository.GetUserById(id)
+	user, err := h.repository.GetUserById(r.Context(), id)
 
 	if err != nil {
-		log.Printf("error on handleGetUserById %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
-		return
-	}
-
-	if user == nil {
-		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		slog.ErrorContext(r.Context(), "error on handleGetUserById", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
@@ -71,16 +67,16 @@ func (h *Handler) handleGetUserById(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Produce  json
 // @Success 200 {array} types.User
-// @Failure 400 {object} types.APIError
-// @Router /users/ [get]
+// @Failure 400 {object} types.Problem
+// @Router /users [get]
 func (h *Handler) handleGetUsers(w http.ResponseWriter, r *http.Request) {
-	log.Print("handleGetUsers")
+	slog.DebugContext(r.Context(), "handleGetUsers")
 
-	users, err := h.repository.GetUsers()
+	users, err := h.repository.GetUsers(r.Context())
 
 	if err != nil {
-		log.Printf("error on handleGetUsers %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on handleGetUsers", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
@@ -94,9 +90,12 @@ func (h *Handler) handleGetUsers(w http.ResponseWriter, r *http.Request) {
 // @Accept  json
 // @Produce  json
 // @Param user body types.CreateUserPayload true "User object that needs to be created"
-// @Success 200
-// @Failure 400 {object} types.APIError
-// @Failure 500 {object} types.APIError
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 201 {object} types.User
+// @Header 201 {string} Location "URL of the created user"
+// @Failure 400 {object} types.Problem
+// @Failure 409 {object} types.Problem
+// @Failure 500 {object} types.Problem
 // @Router /users [post]
 func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	var payload types.CreateUserPayload
@@ -104,40 +103,39 @@ func (h *Handler) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	err := utils.ParseJson(r, &payload)
 
 	if err != nil {
-		log.Printf("error on ParseJson %v", err)
-		utils.WriteError(w, http.StatusBadRequest, err)
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	if err := utils.Validate.Struct(payload); err != nil {
-		errors := err.(validator.ValidationErrors)
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+	if err := utils.ValidateStruct(payload); err != nil {
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	user, err := h.repository.GetUserByEmail(payload.Email)
+	user, err := h.repository.GetUserByEmail(r.Context(), payload.Email)
 
-	if err != nil {
-		log.Printf("error on GetUserByEmail %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+	if err == nil {
+		utils.WriteProblem(w, errs.Conflict("user with email %s already exists", payload.Email))
 		return
 	}
 
-	if user != nil {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user with email %s already exists", payload.Email))
+	if !errors.Is(err, ErrUserNotFound) {
+		slog.ErrorContext(r.Context(), "error on GetUserByEmail", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	err = h.repository.CreateUser(types.User{
+	user, err = h.repository.CreateUser(r.Context(), types.User{
 		Email: payload.Email,
 		Name:  payload.Name,
 	})
 
 	if err != nil {
-		log.Printf("error on CreateUser %v", err)
-		utils.WriteError(w, http.StatusInternalServerError, err)
+		slog.ErrorContext(r.Context(), "error on CreateUser", "error", err)
+		utils.WriteProblem(w, err)
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, nil)
+	utils.WriteCreated(w, "/users/"+user.Id, user)
 }
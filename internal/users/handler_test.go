@@ -2,6 +2,7 @@ package users
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -13,10 +14,11 @@ import (
 )
 
 type mockUserRepository struct {
-	GetUserByEmailFunc func(email string) (*types.User, error)
-	GetUsersFunc       func() ([]types.User, error)
-	GetUserByIdFunc    func(id string) (*types.User, error)
-	CreateUserFunc     func(user types.User) error
+	GetUserByEmailFunc func(ctx context.Context, email string) (*types.User, error)
+	GetUsersFunc       func(ctx context.Context) ([]types.User, error)
+	GetUserByIdFunc    func(ctx context.Context, id string) (*types.User, error)
+	CreateUserFunc     func(ctx context.Context, user types.User) (*types.User, error)
+	StreamUsersFunc    func(ctx context.Context, fn func(types.User) error) error
 }
 
 func TestCreateUserHandler(t *testing.T) {
@@ -106,7 +108,7 @@ func TestCreateUserHandler(t *testing.T) {
 
 	t.Run("should fail if email is already registered", func(t *testing.T) {
 		userRepository := &mockUserRepository{
-			GetUserByEmailFunc: func(email string) (*types.User, error) {
+			GetUserByEmailFunc: func(ctx context.Context, email string) (*types.User, error) {
 				return &types.User{Id: "1", Email: "existing@email.com"}, nil
 			},
 		}
@@ -132,18 +134,18 @@ func TestCreateUserHandler(t *testing.T) {
 
 		router.ServeHTTP(rr, req)
 
-		if rr.Code != http.StatusBadRequest {
-			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
 		}
 	})
 
 	t.Run("should return 500 if repository fails on CreateUser", func(t *testing.T) {
 		userRepository := &mockUserRepository{
-			GetUserByEmailFunc: func(email string) (*types.User, error) {
-				return nil, nil // No user exists with this email
+			GetUserByEmailFunc: func(ctx context.Context, email string) (*types.User, error) {
+				return nil, ErrUserNotFound
 			},
-			CreateUserFunc: func(user types.User) error {
-				return fmt.Errorf("database error")
+			CreateUserFunc: func(ctx context.Context, user types.User) (*types.User, error) {
+				return nil, fmt.Errorf("database error")
 			},
 		}
 
@@ -201,8 +203,8 @@ func TestGetUserByIdHandler(t *testing.T) {
 
 	t.Run("should return 404 if user ID does not exist", func(t *testing.T) {
 		userRepository := &mockUserRepository{
-			GetUserByIdFunc: func(id string) (*types.User, error) {
-				return nil, nil // Simulate user not found
+			GetUserByIdFunc: func(ctx context.Context, id string) (*types.User, error) {
+				return nil, ErrUserNotFound
 			},
 		}
 
@@ -228,7 +230,7 @@ func TestGetUserByIdHandler(t *testing.T) {
 
 	t.Run("should return 500 if repository fails on GetUserById", func(t *testing.T) {
 		userRepository := &mockUserRepository{
-			GetUserByIdFunc: func(id string) (*types.User, error) {
+			GetUserByIdFunc: func(ctx context.Context, id string) (*types.User, error) {
 				return nil, fmt.Errorf("database error")
 			},
 		}
@@ -255,7 +257,7 @@ func TestGetUserByIdHandler(t *testing.T) {
 
 	t.Run("should retrieve user successfully", func(t *testing.T) {
 		userRepository := &mockUserRepository{
-			GetUserByIdFunc: func(id string) (*types.User, error) {
+			GetUserByIdFunc: func(ctx context.Context, id string) (*types.User, error) {
 				return &types.User{
 					Id:    id,
 					Name:  "Test User",
@@ -288,7 +290,7 @@ func TestGetUserByIdHandler(t *testing.T) {
 func TestGetUsersHandler(t *testing.T) {
 	t.Run("should return 500 if repository fails on GetUsers", func(t *testing.T) {
 		userRepository := &mockUserRepository{
-			GetUsersFunc: func() ([]types.User, error) {
+			GetUsersFunc: func(ctx context.Context) ([]types.User, error) {
 				return nil, fmt.Errorf("database error")
 			},
 		}
@@ -315,7 +317,7 @@ func TestGetUsersHandler(t *testing.T) {
 
 	t.Run("should retrieve users successfully", func(t *testing.T) {
 		userRepository := &mockUserRepository{
-			GetUsersFunc: func() ([]types.User, error) {
+			GetUsersFunc: func(ctx context.Context) ([]types.User, error) {
 				return []types.User{
 					{
 						Id:    "Id",
@@ -347,30 +349,37 @@ func TestGetUsersHandler(t *testing.T) {
 	})
 }
 
-func (m *mockUserRepository) GetUsers() ([]types.User, error) {
+func (m *mockUserRepository) GetUsers(ctx context.Context) ([]types.User, error) {
 	if m.GetUsersFunc != nil {
-		return m.GetUsersFunc()
+		return m.GetUsersFunc(ctx)
 	}
 	return nil, nil
 }
 
-func (m *mockUserRepository) GetUserByEmail(email string) (*types.User, error) {
+func (m *mockUserRepository) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
 	if m.GetUserByEmailFunc != nil {
-		return m.GetUserByEmailFunc(email)
+		return m.GetUserByEmailFunc(ctx, email)
 	}
-	return nil, nil
+	return nil, ErrUserNotFound
 }
 
-func (m *mockUserRepository) GetUserById(id string) (*types.User, error) {
+func (m *mockUserRepository) GetUserById(ctx context.Context, id string) (*types.User, error) {
 	if m.GetUserByIdFunc != nil {
-		return m.GetUserByIdFunc(id)
+		return m.GetUserByIdFunc(ctx, id)
 	}
-	return nil, nil
+	return nil, ErrUserNotFound
 }
 
-func (m *mockUserRepository) CreateUser(user types.User) error {
+func (m *mockUserRepository) CreateUser(ctx context.Context, user types.User) (*types.User, error) {
 	if m.CreateUserFunc != nil {
-		return m.CreateUserFunc(user)
+		return m.CreateUserFunc(ctx, user)
+	}
+	return &user, nil
+}
+
+func (m *mockUserRepository) StreamUsers(ctx context.Context, fn func(types.User) error) error {
+	if m.StreamUsersFunc != nil {
+		return m.StreamUsersFunc(ctx, fn)
 	}
 	return nil
 }
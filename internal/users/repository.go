@@ -1,18 +1,25 @@
 package users
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
+	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/errs"
 	"github.com/gfteix/book_loan_system/types"
 	"github.com/google/uuid"
 )
 
+var ErrUserNotFound = errs.NotFound("user not found")
+
 type Repository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout time.Duration
 }
 
-func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, timeout time.Duration) *Repository {
+	return &Repository{db: db, timeout: timeout}
 }
 
 func scanRowIntoUser(rows *sql.Rows) (*types.User, error) {
@@ -32,20 +39,33 @@ func scanRowIntoUser(rows *sql.Rows) (*types.User, error) {
 	return user, nil
 }
 
-func (r *Repository) CreateUser(user types.User) error {
-	id := uuid.NewString()
+// CreateUser inserts the user and returns it as stored, with its ID and
+// creation time.
+func (r *Repository) CreateUser(ctx context.Context, user types.User) (*types.User, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
 
-	_, err := r.db.Exec("INSERT INTO users (id, name, email) VALUES ($1, $2, $3)", id, user.Name, user.Email)
+	rows, err := r.db.QueryContext(ctx, "INSERT INTO users (id, name, email) VALUES ($1, $2, $3) RETURNING id, name, email, created_at",
+		uuid.NewString(), user.Name, user.Email)
 
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
 	}
 
-	return nil
+	return scanRowIntoUser(rows)
 }
 
-func (r *Repository) GetUserById(id string) (*types.User, error) {
-	rows, err := r.db.Query("SELECT id, name, email, created_at FROM users WHERE id = $1", id)
+func (r *Repository) GetUserById(ctx context.Context, id string) (*types.User, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, email, created_at FROM users WHERE id = $1", id)
 
 	if err != nil {
 		return nil, err
@@ -67,14 +87,17 @@ func (r *Repository) GetUserById(id string) (*types.User, error) {
 	}
 
 	if !found {
-		return nil, nil
+		return nil, ErrUserNotFound
 	}
 
 	return u, nil
 }
 
-func (r *Repository) GetUserByEmail(email string) (*types.User, error) {
-	rows, err := r.db.Query("SELECT id, name, email, created_at FROM users WHERE email = $1", email)
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, email, created_at FROM users WHERE email = $1", email)
 
 	if err != nil {
 		return nil, err
@@ -96,34 +119,51 @@ func (r *Repository) GetUserByEmail(email string) (*types.User, error) {
 	}
 
 	if !found {
-		return nil, nil
+		return nil, ErrUserNotFound
 	}
 
 	return u, nil
 }
 
-func (r *Repository) GetUsers() ([]types.User, error) {
-	rows, err := r.db.Query("SELECT id, name, email, created_at FROM users")
+func (r *Repository) GetUsers(ctx context.Context) ([]types.User, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	users := make([]types.User, 0)
+
+	err := r.StreamUsers(ctx, func(u types.User) error {
+		users = append(users, u)
+		return nil
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	defer rows.Close()
+	return users, nil
+}
 
-	users := make([]types.User, 0)
+// StreamUsers calls fn for every user, one row at a time.
+func (r *Repository) StreamUsers(ctx context.Context, fn func(types.User) error) error {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, email, created_at FROM users")
 
-	u := new(types.User)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
 
 	for rows.Next() {
-		u, err = scanRowIntoUser(rows)
+		u, err := scanRowIntoUser(rows)
 
 		if err != nil {
-			return nil, err
+			return err
 		}
-		users = append(users, *u)
 
+		if err := fn(*u); err != nil {
+			return err
+		}
 	}
 
-	return users, nil
+	return rows.Err()
 }
@@ -0,0 +1,298 @@
+package works
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gfteix/book_loan_system/pkg/utils"
+	"github.com/gfteix/book_loan_system/types"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	repository     types.WorkRepository
+	bookRepository types.BookRepository
+}
+
+func NewHandler(repository types.WorkRepository, bookRepository types.BookRepository) *Handler {
+	return &Handler{repository: repository, bookRepository: bookRepository}
+}
+
+func (h *Handler) RegisterRoutes(router types.Router) {
+	router.HandleFunc("GET /works", h.handleGetWorks)
+	router.HandleFunc("POST /works", h.handleCreateWork)
+	router.HandleFunc("GET /works/{id}", h.handleGetWorkById)
+	router.HandleFunc("GET /works/{id}/availability", h.handleGetWorkAvailability)
+
+	router.HandleFunc("GET /series", h.handleGetSeries)
+	router.HandleFunc("POST /series", h.handleCreateSeries)
+	router.HandleFunc("GET /series/{id}", h.handleGetSeriesById)
+	router.HandleFunc("PUT /series/{id}/works/{workId}", h.handleSetSeriesWork)
+}
+
+// handleGetWorks godoc
+// @Summary Get works
+// @Description Retrieves works, the groups that editions of the same title belong to
+// @Tags works
+// @Accept  json
+// @Produce  json
+// @Param title query string false "Filter by part of the title"
+// @Success 200 {array} types.Work
+// @Failure 500 {object} types.Problem
+// @Router /works [get]
+func (h *Handler) handleGetWorks(w http.ResponseWriter, r *http.Request) {
+	filter := map[string]string{
+		"title": r.URL.Query().Get("title"),
+	}
+
+	works, err := h.repository.GetWorks(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetWorks", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, works)
+}
+
+// handleCreateWork godoc
+// @Summary Create a work
+// @Description Creates a work that editions can then be filed under
+// @Tags works
+// @Accept  json
+// @Produce  json
+// @Param work body types.CreateWorkPayload true "Work details"
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 201 {object} types.Work
+// @Header 201 {string} Location "URL of the created work"
+// @Failure 400 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /works [post]
+func (h *Handler) handleCreateWork(w http.ResponseWriter, r *http.Request) {
+	var payload types.CreateWorkPayload
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	if strings.TrimSpace(payload.Title) == "" {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), map[string]string{"title": "title is required"})
+		return
+	}
+
+	work, err := h.repository.CreateWork(r.Context(), types.Work{Title: strings.TrimSpace(payload.Title)})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on CreateWork", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteCreated(w, "/works/"+work.Id, work)
+}
+
+// handleGetWorkById godoc
+// @Summary Get a work by ID
+// @Description Retrieves a work with all of its editions
+// @Tags works
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Work ID"
+// @Success 200 {object} types.Work
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /works/{id} [get]
+func (h *Handler) handleGetWorkById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	work, err := h.repository.GetWorkById(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetWorkById", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	work.Editions, err = h.bookRepository.GetBooks(r.Context(), map[string]string{"workId": id})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetBooks", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, work)
+}
+
+// handleGetWorkAvailability godoc
+// @Summary Get availability of a work
+// @Description Counts copies across every edition of the work, for patrons who accept any edition
+// @Tags works
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Work ID"
+// @Success 200 {object} types.WorkAvailability
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /works/{id}/availability [get]
+func (h *Handler) handleGetWorkAvailability(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	availability, err := h.repository.GetWorkAvailability(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetWorkAvailability", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, availability)
+}
+
+// handleGetSeries godoc
+// @Summary Get series
+// @Description Retrieves all series
+// @Tags series
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} types.Series
+// @Failure 500 {object} types.Problem
+// @Router /series [get]
+func (h *Handler) handleGetSeries(w http.ResponseWriter, r *http.Request) {
+	series, err := h.repository.GetSeries(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetSeries", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, series)
+}
+
+// handleCreateSeries godoc
+// @Summary Create a series
+// @Description Creates a series that works can be added to in order
+// @Tags series
+// @Accept  json
+// @Produce  json
+// @Param series body types.CreateSeriesPayload true "Series details"
+// @Param Idempotency-Key header string false "Makes retries safe: requests with the same key get the first response"
+// @Success 201 {object} types.Series
+// @Header 201 {string} Location "URL of the created series"
+// @Failure 400 {object} types.Problem
+// @Failure 409 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /series [post]
+func (h *Handler) handleCreateSeries(w http.ResponseWriter, r *http.Request) {
+	var payload types.CreateSeriesPayload
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	if strings.TrimSpace(payload.Name) == "" {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), map[string]string{"name": "name is required"})
+		return
+	}
+
+	series, err := h.repository.CreateSeries(r.Context(), types.Series{Name: strings.TrimSpace(payload.Name)})
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on CreateSeries", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteCreated(w, "/series/"+series.Id, series)
+}
+
+// handleGetSeriesById godoc
+// @Summary Get a series by ID
+// @Description Retrieves a series with its works in reading order
+// @Tags series
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Series ID"
+// @Success 200 {object} types.Series
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /series/{id} [get]
+func (h *Handler) handleGetSeriesById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := uuid.Validate(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	series, err := h.repository.GetSeriesById(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on GetSeriesById", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, series)
+}
+
+// handleSetSeriesWork godoc
+// @Summary Add a work to a series
+// @Description Adds the work to the series at the given position, or moves it there if it is already part of it
+// @Tags series
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Series ID"
+// @Param workId path string true "Work ID"
+// @Param payload body types.SetSeriesWorkPayload true "Position in the series"
+// @Success 204
+// @Failure 400 {object} types.Problem
+// @Failure 404 {object} types.Problem
+// @Failure 500 {object} types.Problem
+// @Router /series/{id}/works/{workId} [put]
+func (h *Handler) handleSetSeriesWork(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	workId := r.PathValue("workId")
+
+	if uuid.Validate(id) != nil || uuid.Validate(workId) != nil {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return
+	}
+
+	var payload types.SetSeriesWorkPayload
+
+	if err := utils.ParseJson(r, &payload); err != nil {
+		slog.WarnContext(r.Context(), "error on ParseJson", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	if payload.Position < 1 {
+		utils.WriteFieldErrors(w, http.StatusBadRequest, fmt.Errorf("invalid payload"), map[string]string{"position": "position must be 1 or greater"})
+		return
+	}
+
+	err := h.repository.SetSeriesWork(r.Context(), id, workId, payload.Position)
+
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on SetSeriesWork", "error", err)
+		utils.WriteProblem(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,192 @@
+package works
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+type mockWorkRepository struct {
+	GetWorksFunc            func(ctx context.Context, filter map[string]string) ([]types.Work, error)
+	GetWorkByIdFunc         func(ctx context.Context, id string) (*types.Work, error)
+	CreateWorkFunc          func(ctx context.Context, work types.Work) (*types.Work, error)
+	GetWorkAvailabilityFunc func(ctx context.Context, id string) (*types.WorkAvailability, error)
+	GetSeriesFunc           func(ctx context.Context) ([]types.Series, error)
+	GetSeriesByIdFunc       func(ctx context.Context, id string) (*types.Series, error)
+	CreateSeriesFunc        func(ctx context.Context, series types.Series) (*types.Series, error)
+	SetSeriesWorkFunc       func(ctx context.Context, seriesId string, workId string, position int) error
+}
+
+func (m *mockWorkRepository) GetWorks(ctx context.Context, filter map[string]string) ([]types.Work, error) {
+	if m.GetWorksFunc != nil {
+		return m.GetWorksFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+func (m *mockWorkRepository) GetWorkById(ctx context.Context, id string) (*types.Work, error) {
+	if m.GetWorkByIdFunc != nil {
+		return m.GetWorkByIdFunc(ctx, id)
+	}
+	return nil, ErrWorkNotFound
+}
+
+func (m *mockWorkRepository) CreateWork(ctx context.Context, work types.Work) (*types.Work, error) {
+	if m.CreateWorkFunc != nil {
+		return m.CreateWorkFunc(ctx, work)
+	}
+	return &work, nil
+}
+
+func (m *mockWorkRepository) GetWorkAvailability(ctx context.Context, id string) (*types.WorkAvailability, error) {
+	if m.GetWorkAvailabilityFunc != nil {
+		return m.GetWorkAvailabilityFunc(ctx, id)
+	}
+	return nil, ErrWorkNotFound
+}
+
+func (m *mockWorkRepository) GetSeries(ctx context.Context) ([]types.Series, error) {
+	if m.GetSeriesFunc != nil {
+		return m.GetSeriesFunc(ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockWorkRepository) GetSeriesById(ctx context.Context, id string) (*types.Series, error) {
+	if m.GetSeriesByIdFunc != nil {
+		return m.GetSeriesByIdFunc(ctx, id)
+	}
+	return nil, ErrSeriesNotFound
+}
+
+func (m *mockWorkRepository) CreateSeries(ctx context.Context, series types.Series) (*types.Series, error) {
+	if m.CreateSeriesFunc != nil {
+		return m.CreateSeriesFunc(ctx, series)
+	}
+	return &series, nil
+}
+
+func (m *mockWorkRepository) SetSeriesWork(ctx context.Context, seriesId string, workId string, position int) error {
+	if m.SetSeriesWorkFunc != nil {
+		return m.SetSeriesWorkFunc(ctx, seriesId, workId, position)
+	}
+	return nil
+}
+
+type mockBookRepository struct {
+	types.BookRepository
+	GetBooksFunc func(ctx context.Context, filter map[string]string) ([]types.Book, error)
+}
+
+func (m *mockBookRepository) GetBooks(ctx context.Context, filter map[string]string) ([]types.Book, error) {
+	if m.GetBooksFunc != nil {
+		return m.GetBooksFunc(ctx, filter)
+	}
+	return nil, nil
+}
+
+const workId = "123e4567-e89b-12d3-a456-426614174000"
+const seriesId = "123e4567-e89b-12d3-a456-426614174001"
+
+func TestWorkHandler(t *testing.T) {
+	repository := &mockWorkRepository{}
+	bookRepository := &mockBookRepository{}
+	handler := NewHandler(repository, bookRepository)
+
+	serve := func(t *testing.T, method string, path string, body []byte) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+		handler.RegisterRoutes(router)
+
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should return a work with its editions", func(t *testing.T) {
+		repository.GetWorkByIdFunc = func(ctx context.Context, id string) (*types.Work, error) {
+			return &types.Work{Id: id, Title: "War and Peace"}, nil
+		}
+
+		bookRepository.GetBooksFunc = func(ctx context.Context, filter map[string]string) ([]types.Book, error) {
+			if filter["workId"] != workId {
+				t.Errorf("expected workId filter %v, got %v", workId, filter["workId"])
+			}
+			return []types.Book{{Title: "War and Peace", Edition: "Oxford World's Classics"}, {Title: "War and Peace", Edition: "Penguin Classics"}}, nil
+		}
+
+		rr := serve(t, http.MethodGet, "/works/"+workId, nil)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var work types.Work
+		if err := json.Unmarshal(rr.Body.Bytes(), &work); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(work.Editions) != 2 {
+			t.Errorf("expected 2 editions, got %d", len(work.Editions))
+		}
+	})
+
+	t.Run("should return 404 for the availability of an unknown work", func(t *testing.T) {
+		rr := serve(t, http.MethodGet, "/works/"+workId+"/availability", nil)
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should add a work to a series at a position", func(t *testing.T) {
+		var gotPosition int
+
+		repository.SetSeriesWorkFunc = func(ctx context.Context, series string, work string, position int) error {
+			gotPosition = position
+			return nil
+		}
+
+		body, _ := json.Marshal(types.SetSeriesWorkPayload{Position: 2})
+		rr := serve(t, http.MethodPut, "/series/"+seriesId+"/works/"+workId, body)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		if gotPosition != 2 {
+			t.Errorf("expected position 2, got %d", gotPosition)
+		}
+	})
+
+	t.Run("should reject positions below 1", func(t *testing.T) {
+		body, _ := json.Marshal(types.SetSeriesWorkPayload{Position: 0})
+		rr := serve(t, http.MethodPut, "/series/"+seriesId+"/works/"+workId, body)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return 409 for a duplicate series", func(t *testing.T) {
+		repository.CreateSeriesFunc = func(ctx context.Context, series types.Series) (*types.Series, error) {
+			return nil, ErrDuplicateSeries
+		}
+
+		body, _ := json.Marshal(types.CreateSeriesPayload{Name: "Discworld"})
+		rr := serve(t, http.MethodPost, "/series", body)
+
+		if rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package works
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/db"
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrWorkNotFound = errs.NotFound("work not found")
+var ErrSeriesNotFound = errs.NotFound("series not found")
+var ErrDuplicateSeries = errs.Conflict("a series with this name already exists")
+var ErrSeriesOrWorkNotFound = errs.NotFound("series or work not found")
+
+type Repository struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewRepository(db *sql.DB, timeout time.Duration) *Repository {
+	return &Repository{db: db, timeout: timeout}
+}
+
+func scanRowIntoWork(rows *sql.Rows) (*types.Work, error) {
+	work := new(types.Work)
+	err := rows.Scan(
+		&work.Id,
+		&work.Title,
+		&work.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return work, nil
+}
+
+func (r *Repository) GetWorks(ctx context.Context, filters map[string]string) ([]types.Work, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	q := "SELECT id, title, created_at FROM works"
+
+	args := make([]interface{}, 0)
+
+	if v := filters["title"]; v != "" {
+		args = append(args, "%"+v+"%")
+		q = fmt.Sprintf("%v WHERE title ILIKE $%v", q, len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, q+" ORDER BY title", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	works := make([]types.Work, 0)
+
+	for rows.Next() {
+		work, err := scanRowIntoWork(rows)
+		if err != nil {
+			return nil, err
+		}
+		works = append(works, *work)
+	}
+
+	return works, rows.Err()
+}
+
+func (r *Repository) GetWorkById(ctx context.Context, id string) (*types.Work, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, title, created_at FROM works WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanRowIntoWork(rows)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrWorkNotFound
+}
+
+// CreateWork inserts the work and returns it as stored.
+func (r *Repository) CreateWork(ctx context.Context, work types.Work) (*types.Work, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	id := uuid.NewString()
+
+	if _, err := r.db.ExecContext(ctx, "INSERT INTO works (id, title) VALUES ($1, $2)", id, work.Title); err != nil {
+		return nil, err
+	}
+
+	return r.GetWorkById(ctx, id)
+}
+
+// GetWorkAvailability counts copies per edition of the work.
+func (r *Repository) GetWorkAvailability(ctx context.Context, id string) (*types.WorkAvailability, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	if _, err := r.GetWorkById(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT b.id, b.title, COALESCE(b.edition, ''), b.isbn,
+			COUNT(bc.id),
+			COUNT(bc.id) FILTER (WHERE lower(bc.status) = 'available')
+		FROM books b
+		LEFT JOIN book_copies bc ON bc.book_id = b.id
+		WHERE b.work_id = $1
+		GROUP BY b.id
+		ORDER BY b.title, b.edition`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	availability := &types.WorkAvailability{
+		WorkId:   id,
+		Editions: make([]types.EditionAvailability, 0),
+	}
+
+	for rows.Next() {
+		var edition types.EditionAvailability
+
+		err := rows.Scan(
+			&edition.BookId,
+			&edition.Title,
+			&edition.Edition,
+			&edition.ISBN,
+			&edition.TotalCopies,
+			&edition.AvailableCopies,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		availability.TotalCopies += edition.TotalCopies
+		availability.AvailableCopies += edition.AvailableCopies
+		availability.Editions = append(availability.Editions, edition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM holds WHERE work_id = $1 AND status = $2", id, types.HoldStatusWaiting).
+		Scan(&availability.WaitingHolds)
+	if err != nil {
+		return nil, err
+	}
+
+	return availability, nil
+}
+
+func (r *Repository) GetSeries(ctx context.Context) ([]types.Series, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, created_at FROM series ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := make([]types.Series, 0)
+
+	for rows.Next() {
+		s := types.Series{Works: make([]types.SeriesWork, 0)}
+
+		if err := rows.Scan(&s.Id, &s.Name, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+
+	return series, rows.Err()
+}
+
+// GetSeriesById returns the series with its works in reading order.
+func (r *Repository) GetSeriesById(ctx context.Context, id string) (*types.Series, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	series := &types.Series{Works: make([]types.SeriesWork, 0)}
+
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, created_at FROM series WHERE id = $1", id).
+		Scan(&series.Id, &series.Name, &series.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrSeriesNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT w.id, w.title, sw.position
+		FROM series_works sw
+		INNER JOIN works w ON w.id = sw.work_id
+		WHERE sw.series_id = $1
+		ORDER BY sw.position, w.title`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var work types.SeriesWork
+
+		if err := rows.Scan(&work.WorkId, &work.Title, &work.Position); err != nil {
+			return nil, err
+		}
+		series.Works = append(series.Works, work)
+	}
+
+	return series, rows.Err()
+}
+
+// CreateSeries inserts the series and returns it as stored.
+func (r *Repository) CreateSeries(ctx context.Context, series types.Series) (*types.Series, error) {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	id := uuid.NewString()
+	_, err := r.db.ExecContext(ctx, "INSERT INTO series (id, name) VALUES ($1, $2)", id, series.Name)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return nil, ErrDuplicateSeries
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetSeriesById(ctx, id)
+}
+
+// SetSeriesWork adds the work to the series at position, or moves it there if
+// it is already part of the series.
+func (r *Repository) SetSeriesWork(ctx context.Context, seriesId string, workId string, position int) error {
+	ctx, cancel := db.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO series_works (series_id, work_id, position) VALUES ($1, $2, $3)
+		ON CONFLICT (series_id, work_id) DO UPDATE SET position = EXCLUDED.position`, seriesId, workId, position)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrSeriesOrWorkNotFound
+	}
+
+	return err
+}
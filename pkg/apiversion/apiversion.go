@@ -0,0 +1,56 @@
+// Package apiversion serves the API under a version prefix while keeping the
+// old unprefixed paths working as deprecated aliases.
+package apiversion
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Prefix is the path every API route is served under.
+const Prefix = "/v1"
+
+// Middleware strips Prefix before handing the request to next, so routes are
+// registered without it. Requests for the old paths reach the same routes but
+// carry Deprecation and Link headers pointing at the versioned path. Paths
+// starting with one of unversioned (probes, metrics, docs) are passed through
+// untouched.
+//
+// It must wrap every middleware that reads r.Pattern, since the mux sets it on
+// the rewritten request.
+func Middleware(next http.Handler, unversioned ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := strings.CutPrefix(r.URL.Path, Prefix); ok && (path == "" || path[0] == '/') {
+			next.ServeHTTP(w, withPath(r, path))
+			return
+		}
+
+		for _, p := range unversioned {
+			if strings.HasPrefix(r.URL.Path, p) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+Prefix+r.URL.EscapedPath()+`>; rel="successor-version"`)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func withPath(r *http.Request, path string) *http.Request {
+	if path == "" {
+		path = "/"
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, Prefix)
+
+	return r2
+}
@@ -0,0 +1,73 @@
+package apiversion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Pattern + " " + r.PathValue("id")))
+	})
+	router.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	var pattern string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+		pattern = r.Pattern
+	}), "/healthz")
+
+	t.Run("should serve versioned routes without deprecation headers", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/books/42", nil))
+
+		if rr.Code != http.StatusOK || rr.Body.String() != "GET /books/{id} 42" {
+			t.Fatalf("got %d %q", rr.Code, rr.Body.String())
+		}
+
+		if rr.Header().Get("Deprecation") != "" {
+			t.Errorf("expected no Deprecation header, got %q", rr.Header().Get("Deprecation"))
+		}
+
+		if pattern != "GET /books/{id}" {
+			t.Errorf("expected outer handlers to see the pattern, got %q", pattern)
+		}
+	})
+
+	t.Run("should serve old paths as deprecated aliases", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/books/42", nil))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if rr.Header().Get("Deprecation") != "true" {
+			t.Errorf("expected Deprecation header, got %q", rr.Header().Get("Deprecation"))
+		}
+
+		if want := `</v1/books/42>; rel="successor-version"`; rr.Header().Get("Link") != want {
+			t.Errorf("expected Link %q, got %q", want, rr.Header().Get("Link"))
+		}
+	})
+
+	t.Run("should leave unversioned paths alone", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		if rr.Code != http.StatusOK || rr.Header().Get("Deprecation") != "" {
+			t.Errorf("got %d with Deprecation %q", rr.Code, rr.Header().Get("Deprecation"))
+		}
+	})
+
+	t.Run("should not treat a longer first segment as the prefix", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1books/42", nil))
+
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
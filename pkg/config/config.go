@@ -1,7 +1,10 @@
 package config
 
 import (
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,14 +17,101 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
+	// DBDSN, when set, replaces the DB_* connection settings above.
+	DBDSN     string
+	DBSSLMode string
+
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+
+	// DBQueryTimeout bounds each repository call. Streams used by exports
+	// are bound only by their request or process.
+	DBQueryTimeout time.Duration
+
+	// MQBackend is the broker used by cmd/reminders and cmd/emails: rabbitmq
+	// or postgres (the mq_messages table).
+	MQBackend string
 
 	MQUsername string
 	MQPassword string
 	MQPort     string
 	MQHost     string
+	// MQConnectTimeout bounds how long cmd/reminders retries connecting to
+	// RabbitMQ. cmd/emails retries until it is stopped.
+	MQConnectTimeout time.Duration
+	// MQPollInterval and MQLockTimeout configure the postgres broker: how
+	// often idle workers look for messages, and how long a claimed message
+	// is hidden before it is delivered again.
+	MQPollInterval time.Duration
+	MQLockTimeout  time.Duration
+	// MQMaxAttempts is how many times cmd/emails handles a failing message
+	// before dead-lettering it, waiting MQRetryDelay before the first retry
+	// and doubling the wait after each one.
+	MQMaxAttempts int
+	MQRetryDelay  time.Duration
 
 	SMTPHost string
 	SMTPPort string
+	// SMTPUsername and SMTPPassword are only sent when SMTPUsername is set.
+	SMTPUsername string
+	SMTPPassword string
+	// EmailConcurrency is the number of cmd/emails workers, and so the
+	// messages handled at once.
+	EmailConcurrency int
+	// EmailPrefetch caps the messages RabbitMQ delivers to cmd/emails before
+	// they are acknowledged.
+	EmailPrefetch int
+
+	LogLevel string
+
+	// MetricsAddr is where cmd/emails serves /metrics.
+	MetricsAddr string
+	// PushgatewayURL, when set, receives the metrics of each cmd/reminders run.
+	PushgatewayURL string
+	// TraceExporter is none, stdout or otlp. The otlp exporter reads the
+	// standard OTEL_EXPORTER_OTLP_* variables.
+	TraceExporter string
+
+	// MetricsQueryTimeout bounds the queries behind the business gauges.
+	MetricsQueryTimeout time.Duration
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	// ShutdownDelay keeps serving after /readyz starts failing, giving load
+	// balancers time to notice before the listener closes.
+	ShutdownDelay    time.Duration
+	ReadinessTimeout time.Duration
+	// ReadinessCheckMQ adds RabbitMQ to the API's readiness checks.
+	ReadinessCheckMQ bool
+
+	OpenLibraryURL       string
+	OpenLibraryTimeout   time.Duration
+	OpenLibraryCacheTTL  time.Duration
+	OpenLibraryCacheSize int
+
+	// RateLimitBackend is memory, postgres (shared by replicas) or none.
+	RateLimitBackend string
+	// RateLimitDefault applies to routes without their own limit, written
+	// as limit/period such as 300/1m.
+	RateLimitDefault string
+	// RateLimitRoutes overrides the default per route pattern, such as
+	// "POST /users=10/1h,GET /books=600/1m".
+	RateLimitRoutes string
+	// IdempotencyBackend is postgres (shared by replicas), memory or none.
+	IdempotencyBackend string
+	// IdempotencyTTL is how long responses to Idempotency-Key requests are
+	// kept for replays.
+	IdempotencyTTL time.Duration
+
+	// TrustProxy identifies clients for rate limits and idempotency keys by
+	// the X-API-Key, X-User-ID and X-Forwarded-For headers. Only enable it
+	// behind a proxy that sets them.
+	TrustProxy bool
 }
 
 var Envs = initConfig()
@@ -34,6 +124,51 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration, using fallback", "key", key, "value", value, "fallback", fallback)
+		return fallback
+	}
+
+	return d
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid integer, using fallback", "key", key, "value", value, "fallback", fallback)
+		return fallback
+	}
+
+	return i
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid boolean, using fallback", "key", key, "value", value, "fallback", fallback)
+		return fallback
+	}
+
+	return b
+}
+
 func initConfig() Config {
 	godotenv.Load()
 
@@ -45,11 +180,65 @@ func initConfig() Config {
 		DBHost:     getEnv("DB_HOST", "127.0.0.1"),
 		DBName:     getEnv("DB_NAME", "library"),
 		DBPort:     getEnv("DB_PORT", "5432"),
+		DBDSN:      getEnv("DB_DSN", ""),
+		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
+
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 20),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		DBQueryTimeout:    getEnvDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+
+		MQBackend: getEnv("MQ_BACKEND", "rabbitmq"),
+
 		MQUsername: getEnv("MQ_USERNAME", "guest"),
 		MQPassword: getEnv("MQ_PASSWORD", "guest"),
 		MQHost:     getEnv("MQ_HOST", "localhost"),
 		MQPort:     getEnv("MQ_PORT", "5672"),
-		SMTPHost:   getEnv("SMTP_HOST", "127.0.0.1"),
-		SMTPPort:   getEnv("SMTP_PORT", "1025"),
+
+		MQConnectTimeout: getEnvDuration("MQ_CONNECT_TIMEOUT", 30*time.Second),
+		MQPollInterval:   getEnvDuration("MQ_POLL_INTERVAL", time.Second),
+		MQLockTimeout:    getEnvDuration("MQ_LOCK_TIMEOUT", 5*time.Minute),
+		MQMaxAttempts:    getEnvInt("MQ_MAX_ATTEMPTS", 5),
+		MQRetryDelay:     getEnvDuration("MQ_RETRY_DELAY", time.Second),
+
+		SMTPHost: getEnv("SMTP_HOST", "127.0.0.1"),
+		SMTPPort: getEnv("SMTP_PORT", "1025"),
+
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		EmailConcurrency: getEnvInt("EMAIL_CONCURRENCY", 10),
+		EmailPrefetch:    getEnvInt("EMAIL_PREFETCH", 20),
+
+		LogLevel: getEnv("LOG_LEVEL", "info"),
+
+		MetricsAddr:         getEnv("METRICS_ADDR", ":6060"),
+		PushgatewayURL:      getEnv("PUSHGATEWAY_URL", ""),
+		TraceExporter:       getEnv("TRACE_EXPORTER", "none"),
+		MetricsQueryTimeout: getEnvDuration("METRICS_QUERY_TIMEOUT", 5*time.Second),
+
+		ReadTimeout:       getEnvDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		ReadHeaderTimeout: getEnvDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		WriteTimeout:      getEnvDuration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:       getEnvDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		ShutdownTimeout:   getEnvDuration("SHUTDOWN_TIMEOUT", 20*time.Second),
+		ShutdownDelay:     getEnvDuration("SHUTDOWN_DELAY", 0),
+		ReadinessTimeout:  getEnvDuration("READINESS_TIMEOUT", 2*time.Second),
+		ReadinessCheckMQ:  getEnvBool("READINESS_CHECK_MQ", false),
+
+		OpenLibraryURL:       getEnv("OPEN_LIBRARY_URL", "https://openlibrary.org"),
+		OpenLibraryTimeout:   getEnvDuration("OPEN_LIBRARY_TIMEOUT", 5*time.Second),
+		OpenLibraryCacheTTL:  getEnvDuration("OPEN_LIBRARY_CACHE_TTL", 24*time.Hour),
+		OpenLibraryCacheSize: getEnvInt("OPEN_LIBRARY_CACHE_SIZE", 10000),
+
+		RateLimitBackend: getEnv("RATE_LIMIT_BACKEND", "memory"),
+		RateLimitDefault: getEnv("RATE_LIMIT_DEFAULT", "300/1m"),
+		RateLimitRoutes:  getEnv("RATE_LIMIT_ROUTES", "POST /users=10/1h"),
+
+		IdempotencyBackend: getEnv("IDEMPOTENCY_BACKEND", "postgres"),
+		IdempotencyTTL:     getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+
+		TrustProxy: getEnvBool("TRUST_PROXY", false),
 	}
 }
@@ -1,51 +1,120 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 
 	_ "github.com/jackc/pgx"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
-	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 )
 
 type DBConfig struct {
+	// DSN, when set, is used as the connection string as is, either as a
+	// postgres:// URL or as key=value pairs. The fields below are ignored.
+	DSN string
+
 	DBHost     string
 	DBPort     string
 	DBUser     string
 	DBPassword string
 	DBName     string
+	// SSLMode is the libpq sslmode, such as disable, require or verify-full.
+	// It defaults to disable.
+	SSLMode string
+
+	// MaxOpenConns and MaxIdleConns size the pool. Zero keeps the
+	// database/sql defaults: unlimited open and two idle connections.
+	MaxOpenConns int
+	MaxIdleConns int
+	// ConnMaxLifetime and ConnMaxIdleTime close connections once they are
+	// that old or have been idle that long. Zero keeps them open.
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// DataSourceName returns the connection string for config.
+func (config DBConfig) DataSourceName() string {
+	if config.DSN != "" {
+		return config.DSN
+	}
+
+	sslMode := config.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	pairs := []string{
+		"host=" + quote(config.DBHost),
+		"port=" + quote(config.DBPort),
+		"user=" + quote(config.DBUser),
+		"password=" + quote(config.DBPassword),
+		"dbname=" + quote(config.DBName),
+		"sslmode=" + quote(sslMode),
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// quote writes v as a key=value connection string value, so passwords with
+// spaces or quotes survive.
+func quote(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
 }
 
 func NewPostgreSQLStorage(config DBConfig) (*sql.DB, error) {
-	dataSourceName := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		config.DBHost,
-		config.DBPort,
-		config.DBUser,
-		config.DBPassword,
-		config.DBName,
-	)
+	dataSourceName := config.DataSourceName()
 
-	db, err := sql.Open("pgx", dataSourceName)
+	// Statements become child spans of the caller's trace when a tracer
+	// provider is installed, and cost nothing otherwise.
+	db, err := otelsql.Open("pgx", dataSourceName, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 
 	if err != nil {
-		log.Printf("unable to connect to database: %v\n", err)
+		slog.Error("unable to connect to database", "error", err)
 		return nil, err
 	}
 
-	initStorage(db)
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	if err := initStorage(db); err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	return db, nil
 }
 
-func initStorage(db *sql.DB) {
+func initStorage(db *sql.DB) error {
 	err := db.Ping()
 
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("unable to ping database", "error", err)
+		return err
+	}
+
+	slog.Info("DB Successfuly connected")
+
+	return nil
+}
+
+// WithTimeout bounds ctx by timeout, so a repository call gives up when
+// either the caller goes away or the query runs too long. A zero timeout
+// leaves ctx as it is.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
 
-	log.Println("DB Successfuly connected")
+	return context.WithTimeout(ctx, timeout)
 }
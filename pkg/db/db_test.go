@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestDataSourceName(t *testing.T) {
+	t.Run("should quote values so they parse back unchanged", func(t *testing.T) {
+		config := DBConfig{
+			DBHost:     "db.internal",
+			DBPort:     "5433",
+			DBUser:     "library",
+			DBPassword: `it's a \secret`,
+			DBName:     "library",
+			SSLMode:    "require",
+		}
+
+		parsed, err := pgconn.ParseConfig(config.DataSourceName())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Host != "db.internal" || parsed.Port != 5433 || parsed.User != "library" || parsed.Database != "library" {
+			t.Errorf("unexpected config %+v", parsed)
+		}
+
+		if parsed.Password != config.DBPassword {
+			t.Errorf("expected password %q, got %q", config.DBPassword, parsed.Password)
+		}
+
+		if parsed.TLSConfig == nil {
+			t.Errorf("expected sslmode=require to enable TLS")
+		}
+	})
+
+	t.Run("should default to sslmode disable", func(t *testing.T) {
+		parsed, err := pgconn.ParseConfig(DBConfig{DBHost: "localhost", DBPort: "5432"}.DataSourceName())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.TLSConfig != nil {
+			t.Errorf("expected TLS to be disabled")
+		}
+	})
+
+	t.Run("should use the DSN as is", func(t *testing.T) {
+		dsn := "postgres://library@db.internal/library?sslmode=verify-full"
+
+		if got := (DBConfig{DSN: dsn, DBHost: "ignored"}).DataSourceName(); got != dsn {
+			t.Errorf("expected %q, got %q", dsn, got)
+		}
+	})
+}
+
+func TestCheckVersion(t *testing.T) {
+	for name, tc := range map[string]struct {
+		version uint
+		dirty   bool
+		want    error
+	}{
+		"should accept the expected version":   {20261019160000, false, nil},
+		"should accept a newer schema":         {20261019170000, false, nil},
+		"should refuse an older schema":        {20261019150000, false, ErrSchemaOutdated},
+		"should refuse an unmigrated database": {0, false, ErrSchemaOutdated},
+		"should refuse a dirty schema":         {20261019160000, true, ErrSchemaDirty},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := checkVersion(tc.version, tc.dirty, 20261019160000); !errors.Is(err, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Package dbtest provides a database/sql driver for tests that need a query
+// in flight without a running database.
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// Blocking records the statements run against its database. Each statement
+// blocks until its context is done and then fails with the context's error.
+type Blocking struct {
+	// Started receives each statement as it starts.
+	Started chan string
+	// Aborted receives the context error that ended each statement.
+	Aborted chan error
+}
+
+// NewBlocking returns a database whose statements block until cancelled.
+func NewBlocking() (*sql.DB, *Blocking) {
+	b := &Blocking{Started: make(chan string, 16), Aborted: make(chan error, 16)}
+	return sql.OpenDB(connector{b}), b
+}
+
+func (b *Blocking) run(ctx context.Context, query string) error {
+	b.Started <- query
+	<-ctx.Done()
+	b.Aborted <- ctx.Err()
+
+	return ctx.Err()
+}
+
+type connector struct {
+	b *Blocking
+}
+
+func (c connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return conn{c.b}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return nil
+}
+
+type conn struct {
+	b *Blocking
+}
+
+func (c conn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("dbtest: prepared statements are not supported")
+}
+
+func (c conn) Close() error {
+	return nil
+}
+
+func (c conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return nil, c.b.run(ctx, query)
+}
+
+func (c conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, c.b.run(ctx, query)
+}
+
+type tx struct{}
+
+func (tx) Commit() error {
+	return nil
+}
+
+func (tx) Rollback() error {
+	return nil
+}
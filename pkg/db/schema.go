@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrSchemaOutdated is returned by CheckSchema when the database has not
+	// been migrated as far as the binary expects.
+	ErrSchemaOutdated = errors.New("db: schema is older than expected")
+	// ErrSchemaDirty is returned by CheckSchema when a migration failed
+	// halfway and the schema needs fixing by hand.
+	ErrSchemaDirty = errors.New("db: schema is dirty")
+)
+
+// SchemaVersion returns the version recorded by the migrations in
+// schema_migrations, zero if none ran, and whether the last one failed.
+func SchemaVersion(ctx context.Context, db *sql.DB) (uint, bool, error) {
+	var exists bool
+
+	err := db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	if err != nil || !exists {
+		return 0, false, err
+	}
+
+	var version int64
+	var dirty bool
+
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, fmt.Errorf("reading schema version: %w", err)
+	}
+
+	return uint(version), dirty, nil
+}
+
+// CheckSchema returns an error unless the schema is clean and at version want
+// or newer. A newer schema is accepted so migrations can run ahead of a
+// rolling deploy; they are expected to keep older binaries working.
+func CheckSchema(ctx context.Context, db *sql.DB, want uint) error {
+	version, dirty, err := SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return checkVersion(version, dirty, want)
+}
+
+func checkVersion(version uint, dirty bool, want uint) error {
+	if dirty {
+		return fmt.Errorf("%w at version %d", ErrSchemaDirty, version)
+	}
+
+	if version < want {
+		return fmt.Errorf("%w: at version %d, expected %d; run cmd/migrate up", ErrSchemaOutdated, version, want)
+	}
+
+	return nil
+}
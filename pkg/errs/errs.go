@@ -0,0 +1,146 @@
+// Package errs defines the domain errors returned by repositories and
+// handlers. Each error has a Kind, and HTTPStatus is the single place that
+// maps a kind to a response status.
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type Kind int
+
+const (
+	// KindInternal covers anything not classified below; its message is not
+	// shown to clients.
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	// KindPolicyViolation is a well-formed request that breaks a library
+	// rule, such as lending a copy that is already lent.
+	KindPolicyViolation
+	// KindTooLarge is a request body over the accepted size.
+	KindTooLarge
+	// KindNotImplemented is a feature this deployment has not configured.
+	KindNotImplemented
+	// KindUpstream is a failure of a service the request depends on, such as
+	// a metadata provider. Wrap it around the cause so the cause is logged
+	// but not shown to clients.
+	KindUpstream
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation failed"
+	case KindPolicyViolation:
+		return "policy violation"
+	case KindTooLarge:
+		return "too large"
+	case KindNotImplemented:
+		return "not implemented"
+	case KindUpstream:
+		return "upstream failure"
+	}
+
+	return "internal error"
+}
+
+type Error struct {
+	Kind    Kind
+	Message string
+	// Fields maps the JSON name of an offending payload field to what is
+	// wrong with it.
+	Fields map[string]string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NotFound(format string, args ...any) *Error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(format string, args ...any) *Error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func PolicyViolation(format string, args ...any) *Error {
+	return &Error{Kind: KindPolicyViolation, Message: fmt.Sprintf(format, args...)}
+}
+
+func NotImplemented(format string, args ...any) *Error {
+	return &Error{Kind: KindNotImplemented, Message: fmt.Sprintf(format, args...)}
+}
+
+// Upstream classifies err, returned by a service the request depends on, as
+// KindUpstream with message.
+func Upstream(message string, err error) *Error {
+	return Wrap(KindUpstream, message, err)
+}
+
+// Validation returns a validation error naming the offending fields. fields
+// may be nil when the problem is not tied to one field.
+func Validation(message string, fields map[string]string) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+// Field is shorthand for a validation error on a single field.
+func Field(field string, message string) *Error {
+	return Validation("invalid payload", map[string]string{field: message})
+}
+
+// Wrap classifies err as kind with message, keeping err for errors.Is and
+// errors.As.
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// KindOf returns the kind of the first *Error in err's chain, or
+// KindInternal.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return KindInternal
+}
+
+// HTTPStatus maps err to the status code of its response.
+func HTTPStatus(err error) int {
+	switch KindOf(err) {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindPolicyViolation:
+		return http.StatusUnprocessableEntity
+	case KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindNotImplemented:
+		return http.StatusNotImplemented
+	case KindUpstream:
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,48 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	notFound := NotFound("book %s not found", "1")
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", notFound, http.StatusNotFound},
+		{"conflict", Conflict("duplicate"), http.StatusConflict},
+		{"validation", Field("isbn", "bad checksum"), http.StatusBadRequest},
+		{"policy violation", PolicyViolation("already lent"), http.StatusUnprocessableEntity},
+		{"not implemented", NotImplemented("lookup is not configured"), http.StatusNotImplemented},
+		{"upstream", Upstream("lookup failed", errors.New("timeout")), http.StatusBadGateway},
+		{"wrapped domain error", fmt.Errorf("creating loan: %w", notFound), http.StatusNotFound},
+		{"plain error", errors.New("connection reset"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTTPStatus(tt.err); got != tt.want {
+				t.Errorf("HTTPStatus(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("no rows")
+	err := Wrap(KindNotFound, "loan not found", cause)
+
+	if !errors.Is(err, cause) {
+		t.Errorf("expected the cause to be kept")
+	}
+
+	if err.Error() != "loan not found: no rows" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
@@ -0,0 +1,111 @@
+// Package events defines the domain events published on the library.events
+// topic exchange. An event's type is its routing key, and each type and
+// schema version has a payload struct registered in this package.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/logging"
+	"github.com/gfteix/book_loan_system/pkg/mq"
+	"github.com/google/uuid"
+)
+
+// Exchange is the topic exchange all domain events are published to.
+const Exchange = "library.events"
+
+// Event types, used as routing keys.
+const (
+	LoanExpiring = "loan.expiring"
+	LoanExpired  = "loan.expired"
+)
+
+// EmailsQueue holds the events cmd/emails notifies borrowers about.
+const EmailsQueue = "emails"
+
+// Topology declares the exchange along with every consumer's queue and
+// bindings. Publishers declare it too, so events published before a consumer
+// first starts wait in its queue instead of being dropped.
+var Topology = mq.Topology{
+	Exchanges: []string{Exchange},
+	Queues:    []string{EmailsQueue},
+	Bindings: []mq.Binding{
+		{Exchange: Exchange, Queue: EmailsQueue, Key: LoanExpiring},
+		{Exchange: Exchange, Queue: EmailsQueue, Key: LoanExpired},
+	},
+}
+
+// ErrUnknownSchema is returned when decoding an event whose type and version
+// have no registered payload.
+var ErrUnknownSchema = errors.New("events: unknown event schema")
+
+// Event is the envelope every event is published in.
+type Event struct {
+	EventId string `json:"eventId"`
+	Type    string `json:"type"`
+	// Version is the schema version of Payload.
+	Version int    `json:"version"`
+	Source  string `json:"source"`
+	Time    string `json:"time"`
+	// RequestId correlates the event with the run or request that caused it.
+	RequestId string          `json:"requestId,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Payload is the body of an event of one type and schema version.
+type Payload interface {
+	EventType() string
+	EventVersion() int
+}
+
+// New wraps p in an event from source, carrying the request ID from ctx.
+func New(ctx context.Context, source string, p Payload) (Event, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		EventId:   uuid.NewString(),
+		Type:      p.EventType(),
+		Version:   p.EventVersion(),
+		Source:    source,
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		RequestId: logging.RequestID(ctx),
+		Payload:   payload,
+	}, nil
+}
+
+// Publish sends e to Exchange with its type as the routing key.
+func Publish(ctx context.Context, p mq.Publisher, e Event) error {
+	return mq.PublishJSON(ctx, p, Exchange, e.Type, e)
+}
+
+// Decode returns the payload of e as its registered struct, such as
+// LoanExpiringV1.
+func Decode(e Event) (Payload, error) {
+	decode, ok := registry[schema{e.Type, e.Version}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownSchema, e.Type, e.Version)
+	}
+
+	return decode(e.Payload)
+}
+
+// Handler returns an mq.Handler passing each event and its decoded payload to
+// handle. Messages that do not decode, or whose schema is not registered, are
+// dropped.
+func Handler(handle func(ctx context.Context, e Event, p Payload) error) mq.Handler {
+	return mq.JSON(func(ctx context.Context, e Event) error {
+		p, err := Decode(e)
+		if err != nil {
+			return mq.Permanent(err)
+		}
+
+		return handle(ctx, e, p)
+	})
+}
@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/logging"
+	"github.com/gfteix/book_loan_system/pkg/mq"
+)
+
+type recordingPublisher struct {
+	exchange, key string
+	msg           mq.Message
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, exchange, key string, msg mq.Message) error {
+	p.exchange, p.key, p.msg = exchange, key, msg
+	return nil
+}
+
+func TestEvents(t *testing.T) {
+	expiring := time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC)
+	payload := LoanExpiringV1{LoanId: "loan-id", UserId: "user-id", ExpiringDate: expiring}
+
+	t.Run("should route events by type and decode their payload", func(t *testing.T) {
+		ctx := logging.WithRequestID(context.Background(), "run-id")
+
+		event, err := New(ctx, "test", payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if event.Type != LoanExpiring || event.Version != 1 || event.RequestId != "run-id" {
+			t.Errorf("unexpected envelope %+v", event)
+		}
+
+		publisher := &recordingPublisher{}
+		if err := Publish(ctx, publisher, event); err != nil {
+			t.Fatal(err)
+		}
+
+		if publisher.exchange != Exchange || publisher.key != LoanExpiring {
+			t.Errorf("expected a publish to %s with key %s, got %s with %s", Exchange, LoanExpiring, publisher.exchange, publisher.key)
+		}
+
+		var got Payload
+		handle := Handler(func(ctx context.Context, e Event, p Payload) error {
+			got = p
+			return nil
+		})
+
+		if err := handle(ctx, mq.Delivery{Message: publisher.msg}); err != nil {
+			t.Fatal(err)
+		}
+
+		if got != payload {
+			t.Errorf("expected %+v, got %+v", payload, got)
+		}
+	})
+
+	t.Run("should drop events with an unknown schema", func(t *testing.T) {
+		event, err := New(context.Background(), "test", payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		event.Version = 2
+		publisher := &recordingPublisher{}
+		if err := Publish(context.Background(), publisher, event); err != nil {
+			t.Fatal(err)
+		}
+
+		handle := Handler(func(ctx context.Context, e Event, p Payload) error {
+			t.Error("expected the handler not to run")
+			return nil
+		})
+
+		err = handle(context.Background(), mq.Delivery{Message: publisher.msg})
+
+		if !errors.Is(err, ErrUnknownSchema) {
+			t.Errorf("expected %v, got %v", ErrUnknownSchema, err)
+		}
+	})
+
+	t.Run("should bind every registered type", func(t *testing.T) {
+		bound := map[string]bool{}
+		for _, b := range Topology.Bindings {
+			bound[b.Key] = true
+		}
+
+		for s := range registry {
+			if !bound[s.typ] {
+				t.Errorf("no queue is bound to %s", s.typ)
+			}
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A schema change that breaks consumers gets a new payload struct with the
+// next version, registered alongside the old one until no event of the old
+// version is left in a queue.
+func init() {
+	register[LoanExpiringV1]()
+	register[LoanExpiredV1]()
+}
+
+type schema struct {
+	typ     string
+	version int
+}
+
+var registry = map[schema]func(data []byte) (Payload, error){}
+
+func register[P Payload]() {
+	var p P
+	key := schema{p.EventType(), p.EventVersion()}
+
+	if _, ok := registry[key]; ok {
+		panic(fmt.Sprintf("events: schema %s v%d registered twice", key.typ, key.version))
+	}
+
+	registry[key] = func(data []byte) (Payload, error) {
+		var p P
+		err := json.Unmarshal(data, &p)
+		return p, err
+	}
+}
+
+// LoanExpiringV1 is published for a loan that expires within two days.
+type LoanExpiringV1 struct {
+	LoanId       string    `json:"loanId"`
+	UserId       string    `json:"userId"`
+	ExpiringDate time.Time `json:"expiringDate"`
+}
+
+func (LoanExpiringV1) EventType() string { return LoanExpiring }
+func (LoanExpiringV1) EventVersion() int { return 1 }
+
+// LoanExpiredV1 is published for a loan that expires today.
+type LoanExpiredV1 struct {
+	LoanId       string    `json:"loanId"`
+	UserId       string    `json:"userId"`
+	ExpiringDate time.Time `json:"expiringDate"`
+}
+
+func (LoanExpiredV1) EventType() string { return LoanExpired }
+func (LoanExpiredV1) EventVersion() int { return 1 }
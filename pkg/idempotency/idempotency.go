@@ -0,0 +1,200 @@
+// Package idempotency makes POST requests safe to retry. A request carrying
+// an Idempotency-Key header is handled once per key, route and principal;
+// retries get the stored response instead of running the handler again.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/pkg/principal"
+	"github.com/gfteix/book_loan_system/pkg/utils"
+)
+
+const (
+	KeyHeader      = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	// lockTimeout is how long a claim on a key lasts without a response,
+	// after which a retry may take it over. It outlives the HTTP write
+	// timeout so a slow request is not run twice.
+	lockTimeout = time.Minute
+)
+
+var (
+	ErrInProgress = errs.Conflict("a request with this Idempotency-Key is still being processed")
+	ErrKeyReused  = errs.PolicyViolation("this Idempotency-Key was already used for a different request")
+)
+
+// storedHeaders are the response headers kept for replays. Others, such as
+// X-Request-ID, describe the original request rather than the result.
+var storedHeaders = []string{"Content-Type", "Location"}
+
+// Response is a stored response.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store keeps claimed keys and their responses until they expire.
+type Store interface {
+	// Begin claims key for a request with the given fingerprint. It returns
+	// nil once claimed, the stored response if the key already completed,
+	// ErrInProgress if another request holds it, or ErrKeyReused if it was
+	// used for a request with another fingerprint.
+	Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Response, error)
+	// Complete stores the response for a claimed key.
+	Complete(ctx context.Context, key string, response Response) error
+	// Release drops a claim that produced no response worth keeping, so the
+	// request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+type Middleware struct {
+	store      Store
+	ttl        time.Duration
+	trustProxy bool
+}
+
+// New returns middleware keeping responses in store for ttl. Principals are
+// identified with principal.FromRequest.
+func New(store Store, ttl time.Duration, trustProxy bool) *Middleware {
+	return &Middleware{store: store, ttl: ttl, trustProxy: trustProxy}
+}
+
+// Wrap applies idempotency to POST requests carrying an Idempotency-Key.
+// Responses with a 5xx or 429 status are not stored, so those requests can
+// be retried with the same key.
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(KeyHeader)
+
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+
+		if len(key) > maxKeyLength {
+			utils.WriteProblem(w, errs.Validation("invalid Idempotency-Key", map[string]string{KeyHeader: "must be at most 255 characters"}))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, utils.MaxBodyBytes+1))
+		if err != nil {
+			utils.WriteProblem(w, errs.Validation("could not read request body", nil))
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if len(body) > utils.MaxBodyBytes {
+			// The handler rejects the body; there is nothing to replay.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scopedKey := principal.FromRequest(r, m.trustProxy) + "|" + r.Method + " " + r.URL.Path + "|" + key
+
+		stored, err := m.store.Begin(ctx, scopedKey, fingerprint(body), m.ttl)
+		if err != nil {
+			if errs.KindOf(err) == errs.KindInternal {
+				slog.ErrorContext(ctx, "error on idempotency Begin", "error", err)
+			}
+
+			utils.WriteProblem(w, err)
+			return
+		}
+
+		if stored != nil {
+			replay(w, stored)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+
+		defer func() {
+			if !completed {
+				if err := m.store.Release(context.WithoutCancel(ctx), scopedKey); err != nil {
+					slog.ErrorContext(ctx, "error on idempotency Release", "error", err)
+				}
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status >= http.StatusInternalServerError || rec.status == http.StatusTooManyRequests {
+			return
+		}
+
+		response := Response{Status: rec.status, Header: http.Header{}, Body: rec.body.Bytes()}
+		for _, h := range storedHeaders {
+			if v := rec.Header().Values(h); len(v) > 0 {
+				response.Header[h] = v
+			}
+		}
+
+		if err := m.store.Complete(context.WithoutCancel(ctx), scopedKey, response); err != nil {
+			slog.ErrorContext(ctx, "error on idempotency Complete", "error", err)
+			return
+		}
+
+		completed = true
+	})
+}
+
+func fingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func replay(w http.ResponseWriter, response *Response) {
+	for h, v := range response.Header {
+		w.Header()[h] = v
+	}
+
+	w.Header().Set(ReplayedHeader, "true")
+	w.Header().Set("Content-Length", strconv.Itoa(len(response.Body)))
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
+
+// recorder passes the response through while keeping a copy.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
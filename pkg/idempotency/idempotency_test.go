@@ -0,0 +1,193 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls atomic.Int32
+	status := http.StatusCreated
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := strconv.Itoa(int(calls.Add(1)))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/loans/"+n)
+		w.WriteHeader(status)
+		w.Write([]byte(`{"call":` + n + `}`))
+	})
+
+	do := func(h http.Handler, method, key, ip, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/loans", strings.NewReader(body))
+		req.RemoteAddr = ip + ":1234"
+		if key != "" {
+			req.Header.Set(KeyHeader, key)
+		}
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should replay the first response for the same key", func(t *testing.T) {
+		calls.Store(0)
+		h := New(NewMemoryStore(), time.Hour, false).Wrap(handler)
+
+		first := do(h, http.MethodPost, "k1", "10.0.0.1", `{"a":1}`)
+		second := do(h, http.MethodPost, "k1", "10.0.0.1", `{"a":1}`)
+
+		if calls.Load() != 1 {
+			t.Fatalf("expected the handler to run once, ran %d times", calls.Load())
+		}
+
+		if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+			t.Errorf("expected the stored response, got %d %q", second.Code, second.Body.String())
+		}
+
+		if second.Header().Get("Location") != "/loans/1" || second.Header().Get(ReplayedHeader) != "true" {
+			t.Errorf("expected stored headers and %s, got %v", ReplayedHeader, second.Header())
+		}
+
+		if first.Header().Get(ReplayedHeader) != "" {
+			t.Errorf("expected the first response not to be marked replayed")
+		}
+	})
+
+	t.Run("should scope keys to the principal", func(t *testing.T) {
+		calls.Store(0)
+		h := New(NewMemoryStore(), time.Hour, false).Wrap(handler)
+
+		do(h, http.MethodPost, "k1", "10.0.0.1", `{}`)
+		do(h, http.MethodPost, "k1", "10.0.0.2", `{}`)
+
+		if calls.Load() != 2 {
+			t.Errorf("expected each principal to run the handler, ran %d times", calls.Load())
+		}
+	})
+
+	t.Run("should reject a key reused with a different body", func(t *testing.T) {
+		h := New(NewMemoryStore(), time.Hour, false).Wrap(handler)
+
+		do(h, http.MethodPost, "k1", "10.0.0.1", `{"a":1}`)
+
+		if rr := do(h, http.MethodPost, "k1", "10.0.0.1", `{"a":2}`); rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+	})
+
+	t.Run("should return 409 for a concurrent duplicate", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		h := New(NewMemoryStore(), time.Hour, false).Wrap(slow)
+		done := make(chan *httptest.ResponseRecorder)
+
+		go func() { done <- do(h, http.MethodPost, "k1", "10.0.0.1", `{}`) }()
+		<-started
+
+		if rr := do(h, http.MethodPost, "k1", "10.0.0.1", `{}`); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+
+		close(release)
+
+		if rr := <-done; rr.Code != http.StatusCreated {
+			t.Errorf("expected the first request to complete, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should not store server errors", func(t *testing.T) {
+		calls.Store(0)
+		status = http.StatusInternalServerError
+		defer func() { status = http.StatusCreated }()
+
+		h := New(NewMemoryStore(), time.Hour, false).Wrap(handler)
+
+		do(h, http.MethodPost, "k1", "10.0.0.1", `{}`)
+		do(h, http.MethodPost, "k1", "10.0.0.1", `{}`)
+
+		if calls.Load() != 2 {
+			t.Errorf("expected the retry to run the handler, ran %d times", calls.Load())
+		}
+	})
+
+	t.Run("should forget keys after the ttl", func(t *testing.T) {
+		calls.Store(0)
+		now := time.Now()
+		store := NewMemoryStore()
+		store.now = func() time.Time { return now }
+		h := New(store, time.Hour, false).Wrap(handler)
+
+		do(h, http.MethodPost, "k1", "10.0.0.1", `{}`)
+		now = now.Add(time.Hour)
+		do(h, http.MethodPost, "k1", "10.0.0.1", `{}`)
+
+		if calls.Load() != 2 {
+			t.Errorf("expected the expired key to run the handler again, ran %d times", calls.Load())
+		}
+	})
+
+	t.Run("should ignore requests without a key or other methods", func(t *testing.T) {
+		calls.Store(0)
+		h := New(NewMemoryStore(), time.Hour, false).Wrap(handler)
+
+		do(h, http.MethodPost, "", "10.0.0.1", `{}`)
+		do(h, http.MethodPost, "", "10.0.0.1", `{}`)
+		do(h, http.MethodGet, "k1", "10.0.0.1", ``)
+		do(h, http.MethodGet, "k1", "10.0.0.1", ``)
+
+		if calls.Load() != 4 {
+			t.Errorf("expected every request to run the handler, ran %d times", calls.Load())
+		}
+	})
+
+	t.Run("should reject overlong keys", func(t *testing.T) {
+		h := New(NewMemoryStore(), time.Hour, false).Wrap(handler)
+
+		if rr := do(h, http.MethodPost, strings.Repeat("k", 256), "10.0.0.1", `{}`); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Run("should sweep expired keys at most once per interval", func(t *testing.T) {
+		ctx := context.Background()
+		now := time.Now()
+		store := NewMemoryStore()
+		store.now = func() time.Time { return now }
+
+		store.Begin(ctx, "k1", "f", 30*time.Second)
+
+		now = now.Add(40 * time.Second)
+		store.Begin(ctx, "k2", "f", 30*time.Second)
+
+		if len(store.entries) != 2 {
+			t.Errorf("expected no sweep within the interval, got %d keys", len(store.entries))
+		}
+
+		if response, err := store.Begin(ctx, "k1", "other", 30*time.Second); response != nil || err != nil {
+			t.Errorf("expected the expired key to be claimable before it is swept, got %v, %v", response, err)
+		}
+
+		now = now.Add(sweepInterval)
+		store.Begin(ctx, "k3", "f", 30*time.Second)
+
+		if len(store.entries) != 1 {
+			t.Errorf("expected expired keys to be swept, got %d keys", len(store.entries))
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	fingerprint string
+	response    *Response
+	lockedUntil time.Time
+	expires     time.Time
+}
+
+// MemoryStore keeps keys in process memory. Retries that reach another
+// replica are not deduplicated.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*memoryEntry{}, now: time.Now}
+}
+
+func (s *MemoryStore) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Response, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	e, ok := s.entries[key]
+
+	// Expired keys may not be swept yet.
+	if !ok || !now.Before(e.expires) || (e.response == nil && !now.Before(e.lockedUntil)) {
+		s.entries[key] = &memoryEntry{fingerprint: fingerprint, lockedUntil: now.Add(lockTimeout), expires: now.Add(ttl)}
+		return nil, nil
+	}
+
+	if e.fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+
+	if e.response == nil {
+		return nil, ErrInProgress
+	}
+
+	return e.response, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, response Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.response = &response
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok && e.response == nil {
+		delete(s.entries, key)
+	}
+
+	return nil
+}
+
+// sweep forgets expired keys. Begin runs it at most once per sweepInterval,
+// so requests do not each scan every key.
+func (s *MemoryStore) sweep(now time.Time) {
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+
+	s.lastSweep = now
+}
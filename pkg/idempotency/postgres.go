@@ -0,0 +1,122 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// sweepInterval is how often expired keys are deleted.
+const sweepInterval = time.Minute
+
+// PostgresStore keeps keys in the idempotency_keys table so retries are
+// deduplicated whichever replica they reach.
+type PostgresStore struct {
+	db        *sql.DB
+	lastSweep atomic.Int64
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Response, error) {
+	s.maybeSweep(ctx)
+
+	// Claim the key unless a live claim or response exists. Expired keys and
+	// abandoned claims are taken over.
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO idempotency_keys (key, fingerprint, locked_until, expires_at)
+		VALUES ($1, $2, now() + make_interval(secs => $3), now() + make_interval(secs => $4))
+		ON CONFLICT (key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			locked_until = EXCLUDED.locked_until,
+			expires_at = EXCLUDED.expires_at,
+			status = NULL,
+			header = NULL,
+			body = NULL
+		WHERE idempotency_keys.expires_at <= now()
+			OR (idempotency_keys.status IS NULL AND idempotency_keys.locked_until <= now())
+		RETURNING key
+	`, key, fingerprint, lockTimeout.Seconds(), ttl.Seconds()).Scan(&key)
+
+	if err == nil {
+		return nil, nil
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("claiming idempotency key: %w", err)
+	}
+
+	var storedFingerprint string
+	var status sql.NullInt32
+	var header []byte
+	var body []byte
+
+	err = s.db.QueryRowContext(ctx, `
+		SELECT fingerprint, status, header, body FROM idempotency_keys WHERE key = $1
+	`, key).Scan(&storedFingerprint, &status, &header, &body)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released between the two statements.
+		return nil, ErrInProgress
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading idempotency key: %w", err)
+	}
+
+	if storedFingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+
+	if !status.Valid {
+		return nil, ErrInProgress
+	}
+
+	response := &Response{Status: int(status.Int32), Body: body}
+
+	if err := json.Unmarshal(header, &response.Header); err != nil {
+		return nil, fmt.Errorf("reading idempotency key: %w", err)
+	}
+
+	return response, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, key string, response Response) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET status = $2, header = $3, body = $4 WHERE key = $1
+	`, key, response.Status, header, response.Body)
+
+	return err
+}
+
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND status IS NULL", key)
+	return err
+}
+
+// maybeSweep deletes expired keys, at most once per sweepInterval per
+// replica.
+func (s *PostgresStore) maybeSweep(ctx context.Context) {
+	now := time.Now()
+	last := s.lastSweep.Load()
+
+	if now.Sub(time.Unix(0, last)) < sweepInterval || !s.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= now()"); err != nil {
+		slog.WarnContext(ctx, "error sweeping idempotency keys", "error", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Package isbn validates and normalizes ISBN-10 and ISBN-13 identifiers.
+//
+// Books are stored by their ISBN-13 without separators, so ISBN-10 input,
+// hyphenated ISBN-13s and plain ISBN-13s all map to the same value.
+package isbn
+
+import (
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidLength = fmt.Errorf("isbn must have 10 or 13 digits")
+var ErrInvalidCharacter = fmt.Errorf("isbn may only contain digits, hyphens, spaces and a trailing X")
+var ErrInvalidChecksum = fmt.Errorf("isbn checksum does not match")
+
+// Strip removes hyphens and spaces and upper-cases a trailing check digit.
+func Strip(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	return strings.NewReplacer("-", "", " ", "").Replace(s)
+}
+
+// Normalize validates s as an ISBN-10 or ISBN-13 and returns it as an
+// ISBN-13 with no separators.
+func Normalize(s string) (string, error) {
+	s = Strip(s)
+
+	switch len(s) {
+	case 10:
+		if err := validate10(s); err != nil {
+			return "", err
+		}
+		return To13(s), nil
+	case 13:
+		if err := validate13(s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+
+	return "", ErrInvalidLength
+}
+
+// Valid reports whether s is a well-formed ISBN-10 or ISBN-13.
+func Valid(s string) bool {
+	_, err := Normalize(s)
+	return err == nil
+}
+
+// To13 converts a stripped, valid ISBN-10 to its ISBN-13 form by adding the
+// 978 prefix and recomputing the check digit.
+func To13(isbn10 string) string {
+	prefix := "978" + isbn10[:9]
+	return prefix + string(checkDigit13(prefix))
+}
+
+// To10 converts a stripped, valid ISBN-13 back to ISBN-10. Only 978-prefixed
+// ISBN-13s have an ISBN-10 form; ok is false for the rest.
+func To10(isbn13 string) (string, bool) {
+	if !strings.HasPrefix(isbn13, "978") || len(isbn13) != 13 {
+		return "", false
+	}
+
+	body := isbn13[3:12]
+	return body + string(checkDigit10(body)), true
+}
+
+func validate10(s string) error {
+	for i, c := range s {
+		if c >= '0' && c <= '9' {
+			continue
+		}
+		if c == 'X' && i == 9 {
+			continue
+		}
+		return ErrInvalidCharacter
+	}
+
+	if checkDigit10(s[:9]) != rune(s[9]) {
+		return ErrInvalidChecksum
+	}
+
+	return nil
+}
+
+func validate13(s string) error {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return ErrInvalidCharacter
+		}
+	}
+
+	if checkDigit13(s[:12]) != rune(s[12]) {
+		return ErrInvalidChecksum
+	}
+
+	return nil
+}
+
+// checkDigit10 computes the ISBN-10 check digit for the first nine digits.
+func checkDigit10(body string) rune {
+	sum := 0
+	for i, c := range body {
+		sum += int(c-'0') * (10 - i)
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+
+	return rune('0' + check)
+}
+
+// checkDigit13 computes the ISBN-13 check digit for the first twelve digits.
+func checkDigit13(body string) rune {
+	sum := 0
+	for i, c := range body {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(c-'0') * weight
+	}
+
+	return rune('0' + (10-sum%10)%10)
+}
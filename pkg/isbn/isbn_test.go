@@ -0,0 +1,48 @@
+package isbn
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   error
+	}{
+		{name: "plain isbn-13", input: "9780143058144", want: "9780143058144"},
+		{name: "hyphenated isbn-13", input: "978-0-14-305814-4", want: "9780143058144"},
+		{name: "isbn-10 is converted", input: "0-14-305814-2", want: "9780143058144"},
+		{name: "isbn-10 with X check digit", input: "0-8044-2957-x", want: "9780804429573"},
+		{name: "spaces are stripped", input: " 978 0 14 044924 2 ", want: "9780140449242"},
+		{name: "bad isbn-13 checksum", input: "9780143058145", err: ErrInvalidChecksum},
+		{name: "bad isbn-10 checksum", input: "1234567890", err: ErrInvalidChecksum},
+		{name: "wrong length", input: "12345", err: ErrInvalidLength},
+		{name: "letters", input: "97801430581AB", err: ErrInvalidCharacter},
+		{name: "X only allowed last in isbn-10", input: "X143058146", err: ErrInvalidCharacter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+
+			if err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTo10(t *testing.T) {
+	got, ok := To10("9780143058144")
+	if !ok || got != "0143058142" {
+		t.Errorf("expected 0143058142, got %v (ok=%v)", got, ok)
+	}
+
+	if _, ok := To10("9791032305690"); ok {
+		t.Errorf("expected 979-prefixed isbn to have no isbn-10 form")
+	}
+}
@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	var buf bytes.Buffer
+	setup(&buf, "test", "info")
+
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		slog.InfoContext(r.Context(), "from repository")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	t.Run("should propagate the caller's request id", func(t *testing.T) {
+		buf.Reset()
+
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		req.Header.Set(RequestIDHeader, "abc-123")
+
+		handler.ServeHTTP(rr, req)
+
+		if seen != "abc-123" {
+			t.Errorf("expected request id in context, got %q", seen)
+		}
+
+		if got := rr.Header().Get(RequestIDHeader); got != "abc-123" {
+			t.Errorf("expected request id echoed, got %q", got)
+		}
+
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 log lines, got %d", len(lines))
+		}
+
+		for _, line := range lines {
+			var entry map[string]any
+			if err := json.Unmarshal(line, &entry); err != nil {
+				t.Fatal(err)
+			}
+
+			if entry["request_id"] != "abc-123" || entry["service"] != "test" {
+				t.Errorf("expected request_id and service on %s", line)
+			}
+		}
+
+		var access map[string]any
+		json.Unmarshal(lines[1], &access)
+		if access["status"] != float64(http.StatusTeapot) {
+			t.Errorf("expected status %d logged, got %v", http.StatusTeapot, access["status"])
+		}
+	})
+
+	t.Run("should assign a request id when none is sent", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/books", nil))
+
+		if seen == "" || rr.Header().Get(RequestIDHeader) != seen {
+			t.Errorf("expected a generated request id, got %q", seen)
+		}
+	})
+
+	t.Run("should not log probes at info level", func(t *testing.T) {
+		buf.Reset()
+
+		handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		if buf.Len() != 0 {
+			t.Errorf("expected no log output, got %s", buf.String())
+		}
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("expected empty id, got %q", id)
+	}
+
+	if id := RequestID(WithRequestID(context.Background(), "x")); id != "x" {
+		t.Errorf("expected x, got %q", id)
+	}
+}
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/recorder"
+	"github.com/google/uuid"
+)
+
+// probePaths are polled by orchestrators every few seconds, so they are only
+// logged at debug level.
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// maxRequestIDLength bounds client supplied IDs so they cannot bloat logs.
+const maxRequestIDLength = 128
+
+// Middleware propagates the caller's X-Request-ID, or assigns a new one,
+// stores it in the request context, echoes it on the response and logs one
+// line per request.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+
+		ctx := WithRequestID(r.Context(), id)
+		w.Header().Set(RequestIDHeader, id)
+
+		rec := recorder.New(w)
+		start := time.Now()
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if probePaths[r.URL.Path] {
+			level = slog.LevelDebug
+		}
+
+		slog.Log(ctx, level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.Status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
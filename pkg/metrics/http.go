@@ -0,0 +1,60 @@
+// Package metrics holds the Prometheus instrumentation shared by the API and
+// the workers.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/recorder"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTP holds the request metrics of a server, labelled by the ServeMux
+// pattern that matched (for example "GET /books/{id}") rather than the raw
+// path, so cardinality stays bounded.
+type HTTP struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewHTTP(reg prometheus.Registerer) *HTTP {
+	m := &HTTP{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests handled, by route pattern and status code.",
+		}, []string{"route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency, by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route"}),
+	}
+
+	reg.MustRegister(m.requests, m.duration)
+
+	return m
+}
+
+// Middleware labels requests with r.Pattern, read back once next returns.
+// The ServeMux records the matched pattern on the request it is given, so
+// no middleware between this one and the mux may replace the request, for
+// example with r.WithContext or r.Clone; otherwise every request counts as
+// "unmatched".
+func (m *HTTP) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := recorder.New(w)
+		start := time.Now()
+
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.requests.WithLabelValues(route, strconv.Itoa(rec.Status)).Inc()
+		m.duration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewHTTP(reg)
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	handler := m.Middleware(router)
+
+	for _, path := range []string{"/books/1", "/books/2", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	t.Run("should label requests by route pattern", func(t *testing.T) {
+		expected := `
+# HELP http_requests_total HTTP requests handled, by route pattern and status code.
+# TYPE http_requests_total counter
+http_requests_total{code="404",route="GET /books/{id}"} 2
+http_requests_total{code="404",route="unmatched"} 1
+`
+		if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_requests_total"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("should observe latency per route", func(t *testing.T) {
+		if got := testutil.CollectAndCount(m.duration); got != 2 {
+			t.Errorf("expected 2 latency series, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	activeLoansDesc = prometheus.NewDesc(
+		"library_active_loans",
+		"Loans that have not been returned.",
+		nil, nil,
+	)
+	overdueLoansDesc = prometheus.NewDesc(
+		"library_overdue_loans",
+		"Loans that have not been returned and are past their expiring date.",
+		nil, nil,
+	)
+	availableCopiesDesc = prometheus.NewDesc(
+		"library_available_copies",
+		"Book copies that can be lent right now.",
+		nil, nil,
+	)
+)
+
+const libraryStatsQuery = `
+SELECT
+	(SELECT count(*) FROM loans WHERE return_date IS NULL),
+	(SELECT count(*) FROM loans WHERE return_date IS NULL AND expiring_date < now()),
+	(SELECT count(*) FROM book_copies WHERE lower(status) = 'available')`
+
+// LibraryCollector reports business gauges computed from the database at
+// scrape time.
+type LibraryCollector struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+func NewLibraryCollector(db *sql.DB, timeout time.Duration) *LibraryCollector {
+	return &LibraryCollector{db: db, timeout: timeout}
+}
+
+func (c *LibraryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeLoansDesc
+	ch <- overdueLoansDesc
+	ch <- availableCopiesDesc
+}
+
+func (c *LibraryCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var active, overdue, available float64
+
+	err := c.db.QueryRowContext(ctx, libraryStatsQuery).Scan(&active, &overdue, &available)
+	if err != nil {
+		slog.Error("error collecting library metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(activeLoansDesc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(activeLoansDesc, prometheus.GaugeValue, active)
+	ch <- prometheus.MustNewConstMetric(overdueLoansDesc, prometheus.GaugeValue, overdue)
+	ch <- prometheus.MustNewConstMetric(availableCopiesDesc, prometheus.GaugeValue, available)
+}
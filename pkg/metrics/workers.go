@@ -0,0 +1,65 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// EmailWorker counts the messages from the emails queue handled by cmd/emails.
+type EmailWorker struct {
+	Consumed  prometheus.Counter
+	Processed prometheus.Counter
+	// Failed is labelled by the step that failed: decode, type, lookup or send.
+	Failed     *prometheus.CounterVec
+	EmailsSent prometheus.Counter
+}
+
+func NewEmailWorker(reg prometheus.Registerer) *EmailWorker {
+	m := &EmailWorker{
+		Consumed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "email_messages_consumed_total",
+			Help: "Messages received from the emails queue.",
+		}),
+		Processed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "email_messages_processed_total",
+			Help: "Messages handled successfully and acknowledged.",
+		}),
+		Failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "email_messages_failed_total",
+			Help: "Messages that could not be handled, by failing step.",
+		}, []string{"reason"}),
+		EmailsSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "emails_sent_total",
+			Help: "Emails accepted by the SMTP server.",
+		}),
+	}
+
+	reg.MustRegister(m.Consumed, m.Processed, m.Failed, m.EmailsSent)
+
+	return m
+}
+
+// Reminders counts the work done by one cmd/reminders run.
+type Reminders struct {
+	LoansScanned    prometheus.Counter
+	EventsPublished *prometheus.CounterVec
+	PublishFailures prometheus.Counter
+}
+
+func NewReminders(reg prometheus.Registerer) *Reminders {
+	m := &Reminders{
+		LoansScanned: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reminders_loans_scanned_total",
+			Help: "Loans expiring within the reminder window.",
+		}),
+		EventsPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "reminders_events_published_total",
+			Help: "Events published to library.events, by event type.",
+		}, []string{"type"}),
+		PublishFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "reminders_publish_failures_total",
+			Help: "Events that could not be published.",
+		}),
+	}
+
+	reg.MustRegister(m.LoansScanned, m.EventsPublished, m.PublishFailures)
+
+	return m
+}
@@ -0,0 +1,206 @@
+package mq
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Broker publishes messages to exchanges and consumes them from queues.
+// Client talks to RabbitMQ, PostgresBroker keeps messages in a table, and
+// MemoryBroker passes them between goroutines.
+type Broker interface {
+	Publisher
+
+	// Consume passes messages from queue to handle on opts.Workers
+	// goroutines until ctx is done. A message is acknowledged when handle
+	// returns nil. Otherwise it is delivered again after a backoff, up to
+	// opts.MaxAttempts times, and then dead-lettered. Messages failing with
+	// a Permanent error are dropped.
+	//
+	// Once ctx is done no more messages are received, and Consume returns
+	// after the messages in flight are handled. Handlers are not cancelled
+	// by ctx.
+	Consume(ctx context.Context, queue string, opts ConsumeOptions, handle Handler) error
+
+	// Close stops the broker. Consumers return and publishes fail with
+	// ErrClosed.
+	Close() error
+}
+
+var (
+	_ Broker = (*Client)(nil)
+	_ Broker = (*PostgresBroker)(nil)
+	_ Broker = (*MemoryBroker)(nil)
+)
+
+// Topology is the exchanges, queues and bindings a broker routes messages
+// with.
+type Topology struct {
+	// Exchanges are declared as durable topic exchanges.
+	Exchanges []string
+	// Queues are declared durable, so they and their persistent messages
+	// survive a broker restart. Each queue also gets a dead-letter queue,
+	// named after it with a ".dead" suffix on RabbitMQ, keeping the messages
+	// that failed every attempt.
+	Queues   []string
+	Bindings []Binding
+}
+
+// Binding routes the messages published to Exchange with a routing key
+// matching Key to Queue. In Key, "*" matches one dot-separated word and "#"
+// matches zero or more.
+type Binding struct {
+	Exchange string
+	Queue    string
+	Key      string
+}
+
+const (
+	defaultMaxAttempts   = 5
+	defaultRetryDelay    = time.Second
+	defaultMaxRetryDelay = 5 * time.Minute
+)
+
+type ConsumeOptions struct {
+	// Workers is how many messages are handled at once. It defaults to 1.
+	Workers int
+	// Prefetch caps the messages delivered before they are acknowledged.
+	// It defaults to Workers. Only RabbitMQ prefetches; other brokers hand
+	// each worker one message at a time.
+	Prefetch int
+	// MaxAttempts is how many times a failing message is delivered before
+	// it is dead-lettered. It defaults to 5.
+	MaxAttempts int
+	// RetryDelay is the wait before the second attempt, doubling with each
+	// attempt after it up to MaxRetryDelay. They default to 1s and 5m.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+}
+
+func (opts ConsumeOptions) withDefaults() ConsumeOptions {
+	opts.Workers = max(1, opts.Workers)
+
+	if opts.Prefetch <= 0 {
+		opts.Prefetch = opts.Workers
+	}
+
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = defaultRetryDelay
+	}
+
+	if opts.MaxRetryDelay <= 0 {
+		opts.MaxRetryDelay = defaultMaxRetryDelay
+	}
+
+	return opts
+}
+
+// retryDelay returns how long to wait before delivering a message again
+// after attempt number attempt, counting from one, failed.
+func (opts ConsumeOptions) retryDelay(attempt int) time.Duration {
+	shift := max(0, attempt-1)
+	if shift >= 30 || opts.RetryDelay<<shift >= opts.MaxRetryDelay {
+		return opts.MaxRetryDelay
+	}
+
+	return opts.RetryDelay << shift
+}
+
+// route returns the queues bound to exchange with a key matching key.
+func (t Topology) route(exchange, key string) []string {
+	var queues []string
+
+	for _, b := range t.Bindings {
+		if b.Exchange == exchange && matchTopic(b.Key, key) && !slices.Contains(queues, b.Queue) {
+			queues = append(queues, b.Queue)
+		}
+	}
+
+	return queues
+}
+
+// matchTopic reports whether a routing key matches a binding key, as a
+// RabbitMQ topic exchange would.
+func matchTopic(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && pattern[0] == key[0] && matchWords(pattern[1:], key[1:])
+	}
+}
+
+// publish fills in msg's ID and trace context, and calls send in a producer
+// span.
+func publish(ctx context.Context, system, exchange, key string, msg Message, send func(ctx context.Context, msg Message) error) error {
+	if msg.ID == "" {
+		msg.ID = uuid.NewString()
+	}
+
+	msg.Headers = maps.Clone(msg.Headers)
+	if msg.Headers == nil {
+		msg.Headers = map[string]string{}
+	}
+
+	ctx, span := tracing.StartPublish(ctx, system, exchange, key, msg.Headers)
+	defer span.End()
+
+	err := send(ctx, msg)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// process calls handle for d in a consumer span, and returns what should
+// happen to d along with the handler's error.
+func process(ctx context.Context, system, queue string, d Delivery, opts ConsumeOptions, handle Handler) (disposition, error) {
+	ctx, span := tracing.StartConsume(ctx, system, queue, d.ID, d.Headers)
+	defer span.End()
+
+	err := handle(ctx, d)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	disposition := dispose(err, d.Attempt, opts.MaxAttempts)
+	switch disposition {
+	case drop:
+		slog.WarnContext(ctx, "dropping message", "queue", queue, "messageId", d.ID, "error", err)
+	case deadLetter:
+		slog.ErrorContext(ctx, "dead-lettering message", "queue", queue, "messageId", d.ID, "attempts", d.Attempt, "error", err)
+	}
+
+	return disposition, err
+}
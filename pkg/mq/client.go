@@ -0,0 +1,480 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// declare declares t on conn. It runs on every connection, so the topology
+// is restored after a broker restart.
+func (t Topology) declare(conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	for _, exchange := range t.Exchanges {
+		if err := ch.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("declaring exchange %s: %w", exchange, err)
+		}
+	}
+
+	for _, queue := range t.Queues {
+		if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("declaring queue %s: %w", queue, err)
+		}
+
+		// Failed messages wait in the retry queue until their expiration,
+		// when RabbitMQ dead-letters them back to queue.
+		_, err := ch.QueueDeclare(retryQueue(queue), true, false, false, false, amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		})
+		if err != nil {
+			return fmt.Errorf("declaring queue %s: %w", retryQueue(queue), err)
+		}
+
+		if _, err := ch.QueueDeclare(deadQueue(queue), true, false, false, false, nil); err != nil {
+			return fmt.Errorf("declaring queue %s: %w", deadQueue(queue), err)
+		}
+	}
+
+	for _, b := range t.Bindings {
+		if err := ch.QueueBind(b.Queue, b.Key, b.Exchange, false, nil); err != nil {
+			return fmt.Errorf("binding queue %s to %s with %s: %w", b.Queue, b.Exchange, b.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// attemptHeader carries the attempt number of a message republished for a
+// retry.
+const attemptHeader = "x-attempt"
+
+func retryQueue(queue string) string {
+	return queue + ".retry"
+}
+
+func deadQueue(queue string) string {
+	return queue + ".dead"
+}
+
+// Client is a RabbitMQ connection that reconnects with backoff and
+// redeclares its topology whenever the connection drops.
+type Client struct {
+	config   MQConfig
+	topology Topology
+
+	mu    sync.Mutex
+	conn  *amqp.Connection
+	ready chan struct{} // closed while conn is usable
+
+	publisherMu sync.Mutex
+	publisher   *amqp.Channel
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+}
+
+// Dial connects to the broker and declares topology, retrying until ctx is
+// done. The returned client keeps reconnecting in the background until
+// Close.
+func Dial(ctx context.Context, config MQConfig, topology Topology) (*Client, error) {
+	c := &Client{
+		config:   config,
+		topology: topology,
+		ready:    make(chan struct{}),
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	conn, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setConn(conn)
+	go c.supervise(conn)
+
+	return c, nil
+}
+
+// Close closes the connection and stops reconnecting. Consumers return and
+// publishes in progress fail with ErrClosed.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.closing) })
+	<-c.done
+
+	return nil
+}
+
+func (c *Client) connect(ctx context.Context) (*amqp.Connection, error) {
+	for attempt := 0; ; attempt++ {
+		conn, err := amqp.Dial(c.config.url())
+
+		if err == nil {
+			if err = c.topology.declare(conn); err == nil {
+				return conn, nil
+			}
+
+			conn.Close()
+		}
+
+		delay := c.config.reconnectDelay(attempt)
+		slog.WarnContext(ctx, "error connecting to rabbitmq, retrying", "error", err, "attempt", attempt+1, "delay", delay)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("connecting to rabbitmq: %w", err)
+		case <-c.closing:
+			return nil, ErrClosed
+		case <-time.After(delay):
+		}
+	}
+}
+
+// supervise replaces conn whenever it closes, until the client is closed.
+func (c *Client) supervise(conn *amqp.Connection) {
+	defer close(c.done)
+
+	for {
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		select {
+		case <-c.closing:
+			conn.Close()
+			return
+		case err := <-closed:
+			slog.Warn("rabbitmq connection lost, reconnecting", "error", err)
+		}
+
+		c.mu.Lock()
+		c.conn = nil
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+
+		var err error
+		if conn, err = c.connect(context.Background()); err != nil {
+			return
+		}
+
+		c.setConn(conn)
+		slog.Info("reconnected to rabbitmq")
+	}
+}
+
+func (c *Client) setConn(conn *amqp.Connection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn = conn
+	close(c.ready)
+}
+
+// connection returns the current connection, waiting for a reconnect if
+// there is none.
+func (c *Client) connection(ctx context.Context) (*amqp.Connection, error) {
+	for {
+		c.mu.Lock()
+		conn, ready := c.conn, c.ready
+		c.mu.Unlock()
+
+		if conn != nil && !conn.IsClosed() {
+			return conn, nil
+		}
+
+		if conn != nil {
+			// Closed but not yet noticed by supervise.
+			ready = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.closing:
+			return nil, ErrClosed
+		case <-ready:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Publish sends a persistent message to exchange with routing key key and
+// waits for the broker to confirm it. If the connection drops first, the
+// message is sent again once reconnected, so consumers may see it twice.
+//
+// The broker confirms messages that no queue is bound to receive and drops
+// them, so the bindings must be declared before publishing.
+func (c *Client) Publish(ctx context.Context, exchange, key string, msg Message) error {
+	return publish(ctx, "rabbitmq", exchange, key, msg, func(ctx context.Context, msg Message) error {
+		return c.publish(ctx, exchange, key, msg)
+	})
+}
+
+func (c *Client) publish(ctx context.Context, exchange, key string, msg Message) error {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+
+	return c.send(ctx, exchange, key, amqp.Publishing{
+		MessageId:    msg.ID,
+		ContentType:  msg.ContentType,
+		Headers:      headers,
+		Body:         msg.Body,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    time.Now(),
+	})
+}
+
+// send publishes publishing and waits for the broker to confirm it,
+// publishing it again if the connection drops first.
+func (c *Client) send(ctx context.Context, exchange, key string, publishing amqp.Publishing) error {
+	for {
+		ch, err := c.publisherChannel(ctx)
+		if err != nil {
+			return err
+		}
+
+		confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, publishing)
+		if errors.Is(err, amqp.ErrClosed) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		acked, err := confirm.WaitContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !acked && ch.IsClosed() {
+			// Pending confirms are nacked when the channel closes.
+			continue
+		}
+
+		if !acked {
+			return ErrNotConfirmed
+		}
+
+		return nil
+	}
+}
+
+// publisherChannel returns the channel in confirm mode used for publishing,
+// opening a new one if the last one closed.
+func (c *Client) publisherChannel(ctx context.Context) (*amqp.Channel, error) {
+	c.publisherMu.Lock()
+	defer c.publisherMu.Unlock()
+
+	if c.publisher != nil && !c.publisher.IsClosed() {
+		return c.publisher, nil
+	}
+
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	c.publisher = ch
+
+	return ch, nil
+}
+
+// Consume consumes queue as described on Broker, resubscribing after
+// reconnects. Once ctx is done it also handles the messages already
+// prefetched before returning.
+func (c *Client) Consume(ctx context.Context, queue string, opts ConsumeOptions, handle Handler) error {
+	opts = opts.withDefaults()
+
+	for attempt := 0; ; attempt++ {
+		subscribed, err := c.consume(ctx, queue, opts, handle)
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrClosed) {
+			return err
+		}
+
+		if subscribed {
+			attempt = 0
+		}
+
+		delay := c.config.reconnectDelay(attempt)
+		slog.WarnContext(ctx, "consumer stopped, resubscribing", "queue", queue, "error", err, "delay", delay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.closing:
+			return ErrClosed
+		case <-time.After(delay):
+		}
+	}
+}
+
+// consume subscribes to queue and handles deliveries until the subscription
+// ends. It reports whether the subscription was made.
+func (c *Client) consume(ctx context.Context, queue string, opts ConsumeOptions, handle Handler) (bool, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer ch.Close()
+
+	if err := ch.Qos(opts.Prefetch, 0, false); err != nil {
+		return false, err
+	}
+
+	// The consumer is cancelled when ctx is done, closing deliveries once
+	// the prefetched messages are read. The channel stays open until then,
+	// so they can still be acknowledged.
+	deliveries, err := ch.ConsumeWithContext(ctx, queue, "", false, false, false, false, nil)
+	if err != nil {
+		return false, err
+	}
+
+	handlerCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for d := range deliveries {
+				c.deliver(handlerCtx, queue, d, opts, handle)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return true, errors.New("deliveries closed")
+}
+
+// deliver handles d and settles it. A message to retry is republished to the
+// retry queue with its next attempt number and an expiration of the retry
+// delay; RabbitMQ only expires messages at the head of a queue, so a retry
+// may also wait for one with a longer delay ahead of it. A message that
+// failed its last attempt is moved to the dead-letter queue.
+func (c *Client) deliver(ctx context.Context, queue string, d amqp.Delivery, opts ConsumeOptions, handle Handler) {
+	delivery := Delivery{
+		Message: Message{
+			ID:          d.MessageId,
+			ContentType: d.ContentType,
+			Headers:     map[string]string{},
+			Body:        d.Body,
+		},
+		RoutingKey:  d.RoutingKey,
+		Redelivered: d.Redelivered,
+		Attempt:     deliveryAttempt(d),
+	}
+
+	for k, v := range d.Headers {
+		if s, ok := v.(string); ok && k != attemptHeader {
+			delivery.Headers[k] = s
+		}
+	}
+
+	var ackErr error
+
+	disposition, _ := process(ctx, "rabbitmq", queue, delivery, opts, handle)
+
+	switch disposition {
+	case ack:
+		ackErr = d.Ack(false)
+	case retry:
+		ackErr = c.move(ctx, d, retryQueue(queue), delivery.Attempt+1, opts.retryDelay(delivery.Attempt))
+	case deadLetter:
+		ackErr = c.move(ctx, d, deadQueue(queue), delivery.Attempt, 0)
+	case drop:
+		ackErr = d.Nack(false, false)
+	}
+
+	if ackErr != nil {
+		slog.ErrorContext(ctx, "error acknowledging message", "queue", queue, "messageId", delivery.ID, "error", ackErr)
+	}
+}
+
+// deliveryAttempt returns the attempt number of d, from the header set when
+// it was retried. A message redelivered after its consumer failed to settle
+// it, for example because it crashed, counts as another attempt.
+func deliveryAttempt(d amqp.Delivery) int {
+	attempt := 1
+
+	if s, ok := d.Headers[attemptHeader].(string); ok {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 {
+			attempt = n
+		}
+	}
+
+	if d.Redelivered {
+		attempt++
+	}
+
+	return attempt
+}
+
+// move publishes a copy of d to queue, through the default exchange, with
+// attempt and an expiration of ttl unless it is zero, and then acknowledges
+// d. If the copy is not confirmed, d is requeued instead so it is not lost.
+func (c *Client) move(ctx context.Context, d amqp.Delivery, queue string, attempt int, ttl time.Duration) error {
+	// Only string headers are carried over, leaving out the x-death
+	// history RabbitMQ adds when the retry queue expires a message.
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		if s, ok := v.(string); ok {
+			headers[k] = s
+		}
+	}
+	headers[attemptHeader] = strconv.Itoa(attempt)
+
+	publishing := amqp.Publishing{
+		MessageId:    d.MessageId,
+		ContentType:  d.ContentType,
+		Headers:      headers,
+		Body:         d.Body,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    d.Timestamp,
+	}
+
+	if ttl > 0 {
+		publishing.Expiration = strconv.FormatInt(max(1, ttl.Milliseconds()), 10)
+	}
+
+	if err := c.send(ctx, "", queue, publishing); err != nil {
+		return errors.Join(fmt.Errorf("moving message to %s: %w", queue, err), d.Nack(false, true))
+	}
+
+	return d.Ack(false)
+}
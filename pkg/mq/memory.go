@@ -0,0 +1,191 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryBroker passes messages between goroutines of one process, for
+// tests. Messages are lost when the process exits.
+type MemoryBroker struct {
+	topology Topology
+	queues   map[string]*memoryQueue
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+// NewMemoryBroker returns a broker routing messages with topology.
+func NewMemoryBroker(topology Topology) *MemoryBroker {
+	b := &MemoryBroker{
+		topology: topology,
+		queues:   map[string]*memoryQueue{},
+		closing:  make(chan struct{}),
+	}
+
+	for _, queue := range topology.Queues {
+		b.queues[queue] = &memoryQueue{ready: make(chan struct{}, 1)}
+	}
+
+	return b
+}
+
+func (b *MemoryBroker) Close() error {
+	b.closeOnce.Do(func() { close(b.closing) })
+	return nil
+}
+
+// Publish queues a copy of msg in every queue bound to key.
+func (b *MemoryBroker) Publish(ctx context.Context, exchange, key string, msg Message) error {
+	select {
+	case <-b.closing:
+		return ErrClosed
+	default:
+	}
+
+	if !slices.Contains(b.topology.Exchanges, exchange) {
+		return fmt.Errorf("%w: exchange %s", ErrNotDeclared, exchange)
+	}
+
+	return publish(ctx, "memory", exchange, key, msg, func(ctx context.Context, msg Message) error {
+		for _, queue := range b.topology.route(exchange, key) {
+			d := Delivery{Message: msg, RoutingKey: key, Attempt: 1}
+			d.Headers = maps.Clone(msg.Headers)
+
+			b.queues[queue].push(d)
+		}
+
+		return nil
+	})
+}
+
+func (b *MemoryBroker) Consume(ctx context.Context, queue string, opts ConsumeOptions, handle Handler) error {
+	q, ok := b.queues[queue]
+	if !ok {
+		return fmt.Errorf("%w: queue %s", ErrNotDeclared, queue)
+	}
+
+	opts = opts.withDefaults()
+	handlerCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				d, ok := q.pop(ctx, b.closing)
+				if !ok {
+					return
+				}
+
+				disposition, _ := process(handlerCtx, "memory", queue, d, opts, handle)
+
+				switch disposition {
+				case retry:
+					delay := opts.retryDelay(d.Attempt)
+					d.Redelivered = true
+					d.Attempt++
+					time.AfterFunc(delay, func() { q.push(d) })
+				case deadLetter:
+					q.deadLetter(d)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	select {
+	case <-b.closing:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
+
+// Dead returns the messages of queue that failed every attempt.
+func (b *MemoryBroker) Dead(queue string) []Delivery {
+	q, ok := b.queues[queue]
+	if !ok {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return slices.Clone(q.dead)
+}
+
+type memoryQueue struct {
+	mu       sync.Mutex
+	messages []Delivery
+	dead     []Delivery
+	// ready holds a value while messages may be waiting.
+	ready chan struct{}
+}
+
+func (q *memoryQueue) push(d Delivery) {
+	q.mu.Lock()
+	q.messages = append(q.messages, d)
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+func (q *memoryQueue) deadLetter(d Delivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.dead = append(q.dead, d)
+}
+
+func (q *memoryQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes the oldest message, waiting for one until ctx is done or the
+// broker closes.
+func (q *memoryQueue) pop(ctx context.Context, closing <-chan struct{}) (Delivery, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return Delivery{}, false
+		case <-closing:
+			return Delivery{}, false
+		default:
+		}
+
+		q.mu.Lock()
+		if len(q.messages) > 0 {
+			d := q.messages[0]
+			q.messages = q.messages[1:]
+			more := len(q.messages) > 0
+			q.mu.Unlock()
+
+			if more {
+				// Wake another worker for the rest.
+				q.signal()
+			}
+
+			return d, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Delivery{}, false
+		case <-closing:
+			return Delivery{}, false
+		case <-q.ready:
+		}
+	}
+}
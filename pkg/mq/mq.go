@@ -1,51 +1,203 @@
+// Package mq publishes messages to topic exchanges and consumes them from
+// queues through a Broker. A Client talks to RabbitMQ, keeping its connection
+// alive across broker restarts; messages are persistent and confirmed by the
+// broker before Publish returns. PostgresBroker keeps messages in a table for
+// deployments without RabbitMQ, and MemoryBroker serves tests. Open returns
+// the RabbitMQ or Postgres broker selected by name.
 package mq
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func DeclareQueue(ch *amqp.Channel, name string) (*amqp.Queue, error) {
-	q, err := ch.QueueDeclare(
-		name,
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
-
-	if err != nil {
-		fmt.Printf("failed to declare %v queue", name)
-		return nil, err
-	}
+const (
+	defaultMinReconnectDelay = 500 * time.Millisecond
+	defaultMaxReconnectDelay = 30 * time.Second
+)
 
-	return &q, nil
-}
+var (
+	ErrClosed = errors.New("mq: client closed")
+	// ErrNotConfirmed is returned by Publish when the broker refuses a
+	// message.
+	ErrNotConfirmed = errors.New("mq: message not confirmed by the broker")
+	// ErrNotDeclared is returned when publishing to an exchange or consuming
+	// from a queue missing from the broker's topology.
+	ErrNotDeclared = errors.New("mq: not declared in the topology")
+	// ErrDecode wraps errors decoding a message body in JSON handlers.
+	ErrDecode = errors.New("mq: cannot decode message")
+)
 
 type MQConfig struct {
 	Username string
 	Password string
 	Host     string
 	Port     string
+
+	// MinReconnectDelay and MaxReconnectDelay bound the backoff between
+	// connection attempts. They default to 500ms and 30s.
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
 }
 
-func NewRabbitMQClient(config MQConfig) (*amqp.Connection, *amqp.Channel, error) {
-	conn, err := amqp.Dial(fmt.Sprintf("amqp://%v:%v@%v:%v/", config.Username, config.Password, config.Host, config.Port))
+func (config MQConfig) url() string {
+	u := url.URL{
+		Scheme: "amqp",
+		User:   url.UserPassword(config.Username, config.Password),
+		Host:   net.JoinHostPort(config.Host, config.Port),
+		Path:   "/",
+	}
+
+	return u.String()
+}
+
+// reconnectDelay returns how long to wait before connection attempt number
+// attempt, counting from zero. The delay doubles with each attempt up to the
+// maximum, with jitter so replicas don't reconnect in lockstep.
+func (config MQConfig) reconnectDelay(attempt int) time.Duration {
+	minDelay := config.MinReconnectDelay
+	if minDelay <= 0 {
+		minDelay = defaultMinReconnectDelay
+	}
+
+	maxDelay := config.MaxReconnectDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxReconnectDelay
+	}
+
+	delay := maxDelay
+	if attempt < 30 && minDelay<<attempt < maxDelay {
+		delay = minDelay << attempt
+	}
+
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// Ping opens and closes a connection to check that the broker is reachable
+// within timeout.
+func Ping(config MQConfig, timeout time.Duration) error {
+	conn, err := amqp.DialConfig(config.url(), amqp.Config{Dial: amqp.DefaultDial(timeout)})
 
 	if err != nil {
-		log.Printf("failed to open rabbit mq connection %v", err)
-		return nil, nil, err
+		return err
 	}
 
-	ch, err := conn.Channel()
+	return conn.Close()
+}
+
+// Message is a message as published.
+type Message struct {
+	// ID defaults to a random UUID.
+	ID          string
+	ContentType string
+	// Headers carry metadata such as the trace context.
+	Headers map[string]string
+	Body    []byte
+}
 
+// Delivery is a message as consumed.
+type Delivery struct {
+	Message
+	// RoutingKey is the key the message was published with.
+	RoutingKey string
+	// Redelivered is set when the message was delivered before without
+	// being acknowledged.
+	Redelivered bool
+	// Attempt counts the deliveries of the message, starting at 1.
+	Attempt int
+}
+
+// Publisher sends messages to an exchange, which routes them to the queues
+// bound to their routing key.
+type Publisher interface {
+	Publish(ctx context.Context, exchange, key string, msg Message) error
+}
+
+// Handler handles a delivery. Returning nil acknowledges it.
+type Handler func(ctx context.Context, d Delivery) error
+
+// PublishJSON publishes v encoded as JSON.
+func PublishJSON(ctx context.Context, p Publisher, exchange, key string, v any) error {
+	body, err := json.Marshal(v)
 	if err != nil {
-		log.Printf("failed to open rabbit mq channel %v", err)
-		return nil, nil, err
+		return err
+	}
+
+	return p.Publish(ctx, exchange, key, Message{ContentType: "application/json", Body: body})
+}
+
+// JSON returns a Handler decoding each body as a T before passing it to
+// handle. Bodies that do not decode are dropped with an error wrapping
+// ErrDecode.
+func JSON[T any](handle func(ctx context.Context, v T) error) Handler {
+	return func(ctx context.Context, d Delivery) error {
+		var v T
+
+		if err := json.Unmarshal(d.Body, &v); err != nil {
+			return Permanent(fmt.Errorf("%w: %w", ErrDecode, err))
+		}
+
+		return handle(ctx, v)
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying, so the message that caused it
+// is dropped rather than retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
 	}
 
-	return conn, ch, nil
+	return &permanentError{err: err}
+}
+
+type disposition int
+
+const (
+	ack disposition = iota
+	// retry delivers the message again after a backoff.
+	retry
+	// deadLetter sets the message aside after its last attempt, so it can be
+	// inspected and replayed rather than lost.
+	deadLetter
+	drop
+)
+
+// dispose decides what happens to a delivery after attempt number attempt,
+// counting from one, returned err. Failed messages are retried until
+// maxAttempts, then dead-lettered. Messages failing with a permanent error
+// are dropped, since retrying them cannot succeed.
+func dispose(err error, attempt, maxAttempts int) disposition {
+	var permanent *permanentError
+
+	switch {
+	case err == nil:
+		return ack
+	case errors.As(err, &permanent):
+		return drop
+	case attempt >= maxAttempts:
+		return deadLetter
+	default:
+		return retry
+	}
 }
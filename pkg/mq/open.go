@@ -0,0 +1,43 @@
+package mq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnknownBackend is returned by Open for a backend other than rabbitmq
+// or postgres.
+var ErrUnknownBackend = errors.New("mq: unknown backend")
+
+// Config selects the broker Open returns.
+type Config struct {
+	// Backend is rabbitmq or postgres.
+	Backend  string
+	RabbitMQ MQConfig
+	Postgres PostgresConfig
+	// ConnectTimeout bounds how long Open retries connecting to RabbitMQ.
+	// Zero retries until ctx is done.
+	ConnectTimeout time.Duration
+}
+
+// Open returns the broker selected by config.Backend, routing messages with
+// topology. The postgres broker keeps its messages in db.
+func Open(ctx context.Context, config Config, db *sql.DB, topology Topology) (Broker, error) {
+	switch config.Backend {
+	case "rabbitmq":
+		if config.ConnectTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, config.ConnectTimeout)
+			defer cancel()
+		}
+
+		return Dial(ctx, config.RabbitMQ, topology)
+	case "postgres":
+		return NewPostgresBroker(db, config.Postgres, topology), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownBackend, config.Backend)
+	}
+}
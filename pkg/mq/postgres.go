@@ -0,0 +1,234 @@
+package mq
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultLockTimeout  = 5 * time.Minute
+)
+
+type PostgresConfig struct {
+	// PollInterval is how long an idle worker waits before looking for new
+	// messages. It defaults to 1s.
+	PollInterval time.Duration
+	// LockTimeout is how long a claimed message stays hidden from other
+	// workers. A message still unacknowledged after it, for example because
+	// its consumer crashed, is delivered again. It defaults to 5m.
+	LockTimeout time.Duration
+}
+
+// PostgresBroker keeps messages in the mq_messages table, for deployments
+// without RabbitMQ. Workers claim messages with FOR UPDATE SKIP LOCKED, so
+// any number of consumer processes can share a queue. A failed message is
+// hidden until its retry delay passes, and one that failed its last attempt
+// is kept with dead_at and last_error set instead of being deleted.
+type PostgresBroker struct {
+	db       *sql.DB
+	config   PostgresConfig
+	topology Topology
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+// NewPostgresBroker returns a broker routing messages with topology. The
+// mq_messages table is created by the migrations.
+func NewPostgresBroker(db *sql.DB, config PostgresConfig, topology Topology) *PostgresBroker {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = defaultLockTimeout
+	}
+
+	return &PostgresBroker{db: db, config: config, topology: topology, closing: make(chan struct{})}
+}
+
+// Close stops the consumers. It does not close db.
+func (b *PostgresBroker) Close() error {
+	b.closeOnce.Do(func() { close(b.closing) })
+	return nil
+}
+
+// Publish inserts a copy of msg for every queue bound to key, in one
+// transaction.
+func (b *PostgresBroker) Publish(ctx context.Context, exchange, key string, msg Message) error {
+	select {
+	case <-b.closing:
+		return ErrClosed
+	default:
+	}
+
+	if !slices.Contains(b.topology.Exchanges, exchange) {
+		return fmt.Errorf("%w: exchange %s", ErrNotDeclared, exchange)
+	}
+
+	return publish(ctx, "postgresql", exchange, key, msg, func(ctx context.Context, msg Message) error {
+		headers, err := json.Marshal(msg.Headers)
+		if err != nil {
+			return err
+		}
+
+		tx, err := b.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("publishing message: %w", err)
+		}
+		defer tx.Rollback()
+
+		for _, queue := range b.topology.route(exchange, key) {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO mq_messages (queue, message_id, routing_key, content_type, headers, body)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, queue, msg.ID, key, msg.ContentType, headers, msg.Body)
+
+			if err != nil {
+				return fmt.Errorf("publishing message: %w", err)
+			}
+		}
+
+		return tx.Commit()
+	})
+}
+
+func (b *PostgresBroker) Consume(ctx context.Context, queue string, opts ConsumeOptions, handle Handler) error {
+	if !slices.Contains(b.topology.Queues, queue) {
+		return fmt.Errorf("%w: queue %s", ErrNotDeclared, queue)
+	}
+
+	opts = opts.withDefaults()
+	var wg sync.WaitGroup
+
+	for range opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.work(ctx, queue, opts, handle)
+		}()
+	}
+
+	wg.Wait()
+
+	select {
+	case <-b.closing:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
+
+// work handles messages from queue one at a time until ctx is done or the
+// broker closes.
+func (b *PostgresBroker) work(ctx context.Context, queue string, opts ConsumeOptions, handle Handler) {
+	handlerCtx := context.WithoutCancel(ctx)
+
+	for {
+		id, d, err := b.claim(ctx, queue)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if !errors.Is(err, sql.ErrNoRows) {
+				slog.WarnContext(ctx, "error claiming message", "queue", queue, "error", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.closing:
+				return
+			case <-time.After(b.config.PollInterval):
+			}
+
+			continue
+		}
+
+		disposition, handleErr := process(handlerCtx, "postgresql", queue, d, opts, handle)
+
+		switch disposition {
+		case ack, drop:
+			_, err = b.db.ExecContext(handlerCtx, "DELETE FROM mq_messages WHERE id = $1", id)
+		case retry:
+			_, err = b.db.ExecContext(handlerCtx, `
+				UPDATE mq_messages SET visible_at = now() + make_interval(secs => $2), last_error = $3
+				WHERE id = $1
+			`, id, opts.retryDelay(d.Attempt).Seconds(), handleErr.Error())
+		case deadLetter:
+			_, err = b.db.ExecContext(handlerCtx, "UPDATE mq_messages SET dead_at = now(), last_error = $2 WHERE id = $1",
+				id, handleErr.Error())
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "error acknowledging message", "queue", queue, "messageId", d.ID, "error", err)
+		}
+
+		select {
+		case <-b.closing:
+			return
+		default:
+		}
+	}
+}
+
+// claim hides the oldest visible message in queue that is not dead from
+// other workers for LockTimeout and returns it, or sql.ErrNoRows if there is
+// none.
+func (b *PostgresBroker) claim(ctx context.Context, queue string) (int64, Delivery, error) {
+	var id int64
+	var headers []byte
+	var d Delivery
+
+	err := b.db.QueryRowContext(ctx, `
+		UPDATE mq_messages SET
+			attempts = attempts + 1,
+			visible_at = now() + make_interval(secs => $2)
+		WHERE id = (
+			SELECT id FROM mq_messages
+			WHERE queue = $1 AND dead_at IS NULL AND visible_at <= now()
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, message_id, routing_key, content_type, headers, body, attempts
+	`, queue, b.config.LockTimeout.Seconds()).Scan(&id, &d.ID, &d.RoutingKey, &d.ContentType, &headers, &d.Body, &d.Attempt)
+
+	if err != nil {
+		return 0, Delivery{}, err
+	}
+
+	d.Headers = decodeHeaders(ctx, queue, d.ID, headers)
+	d.Redelivered = d.Attempt > 1
+
+	return id, d, nil
+}
+
+// decodeHeaders decodes the stored headers of a message. Headers only carry
+// metadata, so ones that do not decode are replaced with none and the message
+// is still handled; failing the claim would leave the row to be claimed and
+// fail again forever.
+func decodeHeaders(ctx context.Context, queue, messageId string, raw []byte) map[string]string {
+	var headers map[string]string
+
+	if err := json.Unmarshal(raw, &headers); err != nil {
+		slog.WarnContext(ctx, "ignoring undecodable message headers", "queue", queue, "messageId", messageId, "error", err)
+		return map[string]string{}
+	}
+
+	if headers == nil {
+		return map[string]string{}
+	}
+
+	return headers
+}
@@ -0,0 +1,285 @@
+// Package openapi turns the Swagger 2 document swag generates from the
+// handler annotations into an OpenAPI 3.1 document, so the annotations stay
+// the single source of truth for both.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const problemSchema = "#/components/schemas/types.Problem"
+
+type swagger struct {
+	Info        map[string]any               `json:"info"`
+	BasePath    string                       `json:"basePath"`
+	Paths       map[string]map[string]swagOp `json:"paths"`
+	Definitions map[string]any               `json:"definitions"`
+}
+
+type swagOp struct {
+	Summary     string                  `json:"summary"`
+	Description string                  `json:"description"`
+	Tags        []string                `json:"tags"`
+	Consumes    []string                `json:"consumes"`
+	Produces    []string                `json:"produces"`
+	Parameters  []map[string]any        `json:"parameters"`
+	Responses   map[string]swagResponse `json:"responses"`
+}
+
+type swagResponse struct {
+	Description string                    `json:"description"`
+	Schema      any                       `json:"schema"`
+	Headers     map[string]map[string]any `json:"headers"`
+}
+
+// Convert converts a Swagger 2 JSON document into an indented OpenAPI 3.1
+// JSON document. The base path becomes the only server.
+func Convert(swagger2 []byte) ([]byte, error) {
+	var doc swagger
+
+	if err := json.Unmarshal(swagger2, &doc); err != nil {
+		return nil, fmt.Errorf("parsing swagger document: %w", err)
+	}
+
+	basePath := strings.TrimSuffix(doc.BasePath, "/")
+	if basePath == "" {
+		basePath = "/"
+	}
+
+	paths := make(map[string]any, len(doc.Paths))
+
+	for path, ops := range doc.Paths {
+		item := make(map[string]any, len(ops))
+
+		for method, op := range ops {
+			item[method] = convertOperation(op)
+		}
+
+		paths[path] = item
+	}
+
+	schemas := make(map[string]any, len(doc.Definitions))
+	for name, schema := range doc.Definitions {
+		schemas[name] = rewriteRefs(schema)
+	}
+
+	out := map[string]any{
+		"openapi":    "3.1.0",
+		"info":       doc.Info,
+		"servers":    []any{map[string]any{"url": basePath}},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+
+	b, err := json.MarshalIndent(out, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+func convertOperation(op swagOp) map[string]any {
+	out := map[string]any{}
+
+	if op.Summary != "" {
+		out["summary"] = op.Summary
+	}
+
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+
+	if len(op.Tags) > 0 {
+		out["tags"] = op.Tags
+	}
+
+	var parameters []any
+
+	for _, p := range op.Parameters {
+		if p["in"] == "body" {
+			body := map[string]any{
+				"content": contentOf(orDefault(op.Consumes), p["schema"]),
+			}
+
+			if d, ok := p["description"]; ok {
+				body["description"] = d
+			}
+
+			if r, ok := p["required"]; ok {
+				body["required"] = r
+			}
+
+			out["requestBody"] = body
+			continue
+		}
+
+		param := map[string]any{"name": p["name"], "in": p["in"]}
+		schema := map[string]any{}
+
+		for k, v := range p {
+			switch k {
+			case "name", "in":
+			case "description", "required":
+				param[k] = v
+			default:
+				schema[k] = v
+			}
+		}
+
+		param["schema"] = schema
+		parameters = append(parameters, param)
+	}
+
+	if len(parameters) > 0 {
+		out["parameters"] = parameters
+	}
+
+	responses := make(map[string]any, len(op.Responses))
+
+	for code, r := range op.Responses {
+		response := map[string]any{"description": r.Description}
+
+		if r.Schema != nil {
+			schema := rewriteRefs(r.Schema)
+
+			if ref, _ := schema.(map[string]any)["$ref"].(string); ref == problemSchema {
+				response["content"] = contentOf([]string{"application/problem+json"}, schema)
+			} else {
+				response["content"] = contentOf(orDefault(op.Produces), schema)
+			}
+		}
+
+		if len(r.Headers) > 0 {
+			headers := make(map[string]any, len(r.Headers))
+
+			for name, h := range r.Headers {
+				header := map[string]any{}
+				schema := map[string]any{}
+
+				for k, v := range h {
+					if k == "description" {
+						header[k] = v
+					} else {
+						schema[k] = v
+					}
+				}
+
+				header["schema"] = schema
+				headers[name] = header
+			}
+
+			response["headers"] = headers
+		}
+
+		responses[code] = response
+	}
+
+	out["responses"] = responses
+
+	return out
+}
+
+func contentOf(mediaTypes []string, schema any) map[string]any {
+	content := make(map[string]any, len(mediaTypes))
+
+	for _, mt := range mediaTypes {
+		content[mt] = map[string]any{"schema": rewriteRefs(schema)}
+	}
+
+	return content
+}
+
+func orDefault(mediaTypes []string) []string {
+	if len(mediaTypes) == 0 {
+		return []string{"application/json"}
+	}
+
+	return mediaTypes
+}
+
+// rewriteRefs points Swagger 2 definition references at OpenAPI 3
+// components.
+func rewriteRefs(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+
+		for k, val := range v {
+			if s, ok := val.(string); ok && k == "$ref" {
+				out[k] = strings.Replace(s, "#/definitions/", "#/components/schemas/", 1)
+				continue
+			}
+
+			out[k] = rewriteRefs(val)
+		}
+
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = rewriteRefs(val)
+		}
+
+		return out
+	}
+
+	return v
+}
+
+// Document is the part of an OpenAPI 3 document needed to check it against
+// the routes and types it describes.
+type Document struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+type Schema struct {
+	Ref        string            `json:"$ref"`
+	Type       string            `json:"type"`
+	Items      *Schema           `json:"items"`
+	Properties map[string]Schema `json:"properties"`
+}
+
+// Parse reads an OpenAPI 3 JSON document.
+func Parse(b []byte) (*Document, error) {
+	var doc Document
+
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parsing openapi document: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// Routes returns every operation as a ServeMux-style pattern such as
+// "GET /books/{id}", sorted.
+func (d *Document) Routes() []string {
+	var routes []string
+
+	for path, ops := range d.Paths {
+		for method := range ops {
+			routes = append(routes, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+
+	return routes
+}
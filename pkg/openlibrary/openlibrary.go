@@ -0,0 +1,236 @@
+// Package openlibrary looks up bibliographic metadata by ISBN from an Open
+// Library compatible HTTP API (the /api/books endpoint with jscmd=data).
+package openlibrary
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gfteix/book_loan_system/types"
+)
+
+var ErrNotFound = fmt.Errorf("no metadata found for isbn")
+
+type Config struct {
+	BaseURL  string
+	Timeout  time.Duration
+	CacheTTL time.Duration
+	// CacheSize caps the number of cached lookups; the least recently used
+	// is evicted first. Defaults to 10000.
+	CacheSize int
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	cacheTTL   time.Duration
+	cacheSize  int
+
+	mu    sync.Mutex
+	cache map[string]*list.Element
+	// recent orders the entries in cache, most recently used first.
+	recent *list.List
+}
+
+type cacheEntry struct {
+	isbn      string
+	book      *types.Book
+	err       error
+	expiresAt time.Time
+}
+
+func NewClient(config Config) *Client {
+	if config.CacheSize <= 0 {
+		config.CacheSize = 10000
+	}
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(config.BaseURL, "/"),
+		httpClient: &http.Client{Timeout: config.Timeout},
+		cacheTTL:   config.CacheTTL,
+		cacheSize:  config.CacheSize,
+		cache:      make(map[string]*list.Element),
+		recent:     list.New(),
+	}
+}
+
+type bookData struct {
+	Title         string `json:"title"`
+	Subtitle      string `json:"subtitle"`
+	NumberOfPages int    `json:"number_of_pages"`
+	Notes         any    `json:"notes"`
+	Authors       []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Subjects []struct {
+		Name string `json:"name"`
+	} `json:"subjects"`
+	Cover struct {
+		Small  string `json:"small"`
+		Medium string `json:"medium"`
+		Large  string `json:"large"`
+	} `json:"cover"`
+}
+
+// LookupISBN returns a book prefilled with the metadata known for isbn. The
+// isbn is expected to be normalized already. Both hits and misses are cached
+// for the configured TTL; upstream failures are not.
+func (c *Client) LookupISBN(ctx context.Context, isbn string) (*types.Book, error) {
+	if entry, ok := c.cached(isbn); ok {
+		return entry.book, entry.err
+	}
+
+	book, err := c.fetch(ctx, isbn)
+
+	if err == nil || err == ErrNotFound {
+		c.store(isbn, book, err)
+	}
+
+	return book, err
+}
+
+func (c *Client) fetch(ctx context.Context, isbn string) (*types.Book, error) {
+	key := "ISBN:" + isbn
+
+	query := url.Values{}
+	query.Set("bibkeys", key)
+	query.Set("format", "json")
+	query.Set("jscmd", "data")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/books?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("open library returned status %d", res.StatusCode)
+	}
+
+	var body map[string]bookData
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding open library response: %w", err)
+	}
+
+	data, ok := body[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return toBook(isbn, data), nil
+}
+
+func toBook(isbn string, data bookData) *types.Book {
+	book := &types.Book{
+		Title:         data.Title,
+		ISBN:          isbn,
+		NumberOfPages: data.NumberOfPages,
+	}
+
+	if data.Subtitle != "" {
+		book.Title = fmt.Sprintf("%s: %s", data.Title, data.Subtitle)
+	}
+
+	authors := make([]string, 0, len(data.Authors))
+	for _, a := range data.Authors {
+		authors = append(authors, a.Name)
+		book.Authors = append(book.Authors, types.Author{Name: a.Name})
+	}
+	book.Author = strings.Join(authors, ", ")
+
+	for _, s := range data.Subjects {
+		book.Subjects = append(book.Subjects, types.Subject{Name: s.Name})
+	}
+
+	// notes is either a plain string or {"type": "/type/text", "value": "..."}
+	switch notes := data.Notes.(type) {
+	case string:
+		book.Description = notes
+	case map[string]any:
+		if v, ok := notes["value"].(string); ok {
+			book.Description = v
+		}
+	}
+
+	switch {
+	case data.Cover.Large != "":
+		book.CoverURL = data.Cover.Large
+	case data.Cover.Medium != "":
+		book.CoverURL = data.Cover.Medium
+	default:
+		book.CoverURL = data.Cover.Small
+	}
+
+	return book
+}
+
+func (c *Client) cached(isbn string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.cache[isbn]
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	entry := *elem.Value.(*cacheEntry)
+
+	if time.Now().After(entry.expiresAt) {
+		c.recent.Remove(elem)
+		delete(c.cache, isbn)
+		return cacheEntry{}, false
+	}
+
+	c.recent.MoveToFront(elem)
+
+	if entry.book != nil {
+		book := *entry.book
+		entry.book = &book
+	}
+
+	return entry, true
+}
+
+func (c *Client) store(isbn string, book *types.Book, err error) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var stored *types.Book
+	if book != nil {
+		copied := *book
+		stored = &copied
+	}
+
+	entry := &cacheEntry{isbn: isbn, book: stored, err: err, expiresAt: time.Now().Add(c.cacheTTL)}
+
+	if elem, ok := c.cache[isbn]; ok {
+		elem.Value = entry
+		c.recent.MoveToFront(elem)
+		return
+	}
+
+	c.cache[isbn] = c.recent.PushFront(entry)
+
+	if c.recent.Len() > c.cacheSize {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.cache, oldest.Value.(*cacheEntry).isbn)
+	}
+}
@@ -0,0 +1,145 @@
+package openlibrary
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const crimeAndPunishment = `{
+  "ISBN:9780143058144": {
+    "title": "Crime and Punishment",
+    "number_of_pages": 671,
+    "notes": {"type": "/type/text", "value": "A psychological novel"},
+    "authors": [{"name": "Fyodor Dostoyevsky"}],
+    "cover": {"small": "https://covers.example/s.jpg", "large": "https://covers.example/l.jpg"}
+  }
+}`
+
+func newMockServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.URL.Path != "/api/books" {
+			t.Errorf("unexpected path %v", r.URL.Path)
+		}
+
+		if r.URL.Query().Get("bibkeys") == "ISBN:9780143058144" {
+			w.Write([]byte(crimeAndPunishment))
+			return
+		}
+
+		w.Write([]byte(`{}`))
+	}))
+}
+
+func TestLookupISBN(t *testing.T) {
+	var requests atomic.Int32
+	server := newMockServer(t, &requests)
+	defer server.Close()
+
+	client := NewClient(Config{BaseURL: server.URL, Timeout: time.Second, CacheTTL: time.Minute})
+
+	t.Run("should prefill a book from the response", func(t *testing.T) {
+		book, err := client.LookupISBN(context.Background(), "9780143058144")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if book.Title != "Crime and Punishment" || book.Author != "Fyodor Dostoyevsky" || book.NumberOfPages != 671 {
+			t.Errorf("unexpected book %+v", book)
+		}
+
+		if book.Description != "A psychological novel" {
+			t.Errorf("expected description from notes, got %v", book.Description)
+		}
+
+		if book.CoverURL != "https://covers.example/l.jpg" {
+			t.Errorf("expected large cover, got %v", book.CoverURL)
+		}
+	})
+
+	t.Run("should serve repeated lookups from cache", func(t *testing.T) {
+		requests.Store(0)
+
+		for i := 0; i < 3; i++ {
+			if _, err := client.LookupISBN(context.Background(), "9780143058144"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := requests.Load(); got != 0 {
+			t.Errorf("expected cached lookups, got %d requests", got)
+		}
+	})
+
+	t.Run("should return ErrNotFound for unknown isbns", func(t *testing.T) {
+		_, err := client.LookupISBN(context.Background(), "9780140449242")
+
+		if err != ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should not cache upstream failures", func(t *testing.T) {
+		var failures atomic.Int32
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			failures.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer failing.Close()
+
+		client := NewClient(Config{BaseURL: failing.URL, Timeout: time.Second, CacheTTL: time.Minute})
+
+		for i := 0; i < 2; i++ {
+			if _, err := client.LookupISBN(context.Background(), "9780143058144"); err == nil {
+				t.Fatal("expected an error")
+			}
+		}
+
+		if got := failures.Load(); got != 2 {
+			t.Errorf("expected 2 upstream requests, got %d", got)
+		}
+	})
+
+	t.Run("should evict the least recently used lookup when full", func(t *testing.T) {
+		var requests atomic.Int32
+		server := newMockServer(t, &requests)
+		defer server.Close()
+
+		client := NewClient(Config{BaseURL: server.URL, Timeout: time.Second, CacheTTL: time.Minute, CacheSize: 2})
+
+		lookup := func(isbn string) {
+			t.Helper()
+
+			if _, err := client.LookupISBN(context.Background(), isbn); err != nil && err != ErrNotFound {
+				t.Fatal(err)
+			}
+		}
+
+		lookup("9780143058144")
+		lookup("9780140449242")
+		lookup("9780143058144")
+		lookup("9780199232765")
+
+		if got := len(client.cache); got != 2 {
+			t.Errorf("expected 2 cached lookups, got %d", got)
+		}
+
+		requests.Store(0)
+		lookup("9780143058144")
+
+		if got := requests.Load(); got != 0 {
+			t.Errorf("expected the recently used lookup to stay cached, got %d requests", got)
+		}
+
+		lookup("9780140449242")
+
+		if got := requests.Load(); got != 1 {
+			t.Errorf("expected the least recently used lookup to be evicted, got %d requests", got)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+// Package principal identifies who is making a request. The API has no
+// authentication of its own, so identity comes from headers set by a trusted
+// proxy or gateway, or else from the connection.
+package principal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+	UserIDHeader = "X-User-ID"
+)
+
+// FromRequest returns "key:<hash>", "user:<id>" or "ip:<address>". With
+// trustProxy, the X-API-Key and X-User-ID headers and X-Forwarded-For are
+// used in that order; otherwise only the connection's IP is. API keys are
+// hashed so they are never stored.
+func FromRequest(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			return "key:" + hex.EncodeToString(sum[:16])
+		}
+
+		if user := r.Header.Get(UserIDHeader); user != "" {
+			return "user:" + user
+		}
+
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip, _, _ := strings.Cut(forwarded, ",")
+			return "ip:" + strings.TrimSpace(ip)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often stores forget buckets that have refilled.
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	bucket
+	expires time.Time
+}
+
+// MemoryStore keeps buckets in process memory. Limits are per replica.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rule Rule) (Result, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: bucket{tokens: float64(rule.Limit), updated: now}}
+		s.buckets[key] = b
+	}
+
+	result := b.take(rule, now)
+	b.expires = b.fullAt(rule)
+
+	return result, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.expires) {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.lastSweep = now
+}
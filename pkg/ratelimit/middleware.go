@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gfteix/book_loan_system/pkg/principal"
+	"github.com/gfteix/book_loan_system/pkg/utils"
+)
+
+type Limiter struct {
+	store      Store
+	policy     Policy
+	trustProxy bool
+}
+
+// New returns a limiter drawing from store. Clients are identified with
+// principal.FromRequest.
+func New(store Store, policy Policy, trustProxy bool) *Limiter {
+	return &Limiter{store: store, policy: policy, trustProxy: trustProxy}
+}
+
+// Middleware limits requests per client and route pattern before serving
+// them with next. Patterns are looked up in mux, so next may be other
+// middleware around mux, such as idempotency replays, and still be limited.
+// Requests over the limit get 429 with Retry-After. If the store fails,
+// requests are let through.
+func (l *Limiter) Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		rule := l.policy.Rule(pattern)
+
+		if pattern == "" || rule.Unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := l.store.Take(r.Context(), pattern+"|"+principal.FromRequest(r, l.trustProxy), rule)
+		if err != nil {
+			slog.WarnContext(r.Context(), "error on rate limit Take, allowing request", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(rule.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			seconds := int(math.Max(1, math.Ceil(result.RetryAfter.Seconds())))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			utils.WriteError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit of %s exceeded, retry in %ds", rule, seconds))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so replicas
+// share limits. Each take locks the bucket's row for the duration of a short
+// transaction and uses the database clock.
+type PostgresStore struct {
+	db        *sql.DB
+	lastSweep atomic.Int64
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, rule Rule) (Result, error) {
+	s.maybeSweep(ctx)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at, expires_at)
+		VALUES ($1, $2, now(), now())
+		ON CONFLICT (key) DO NOTHING
+	`, key, rule.Limit)
+
+	if err != nil {
+		return Result{}, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	var b bucket
+	var now time.Time
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT tokens, updated_at, now() FROM rate_limit_buckets WHERE key = $1 FOR UPDATE
+	`, key).Scan(&b.tokens, &b.updated, &now)
+
+	if err != nil {
+		return Result{}, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	result := b.take(rule, now)
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3, expires_at = $4 WHERE key = $1
+	`, key, b.tokens, b.updated, b.fullAt(rule))
+
+	if err != nil {
+		return Result{}, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("taking rate limit token: %w", err)
+	}
+
+	return result, nil
+}
+
+// maybeSweep deletes buckets that have refilled, at most once per
+// sweepInterval per replica.
+func (s *PostgresStore) maybeSweep(ctx context.Context) {
+	now := time.Now()
+	last := s.lastSweep.Load()
+
+	if now.Sub(time.Unix(0, last)) < sweepInterval || !s.lastSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE expires_at < now()"); err != nil {
+		slog.WarnContext(ctx, "error sweeping rate limit buckets", "error", err)
+	}
+}
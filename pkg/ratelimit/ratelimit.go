@@ -0,0 +1,157 @@
+// Package ratelimit limits requests per client and route with token buckets.
+// Buckets live in a Store: in memory for a single replica, or in Postgres so
+// every replica draws from the same buckets.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule allows Limit requests per Period, refilled continuously, with bursts
+// of up to Limit. The zero Rule is unlimited.
+type Rule struct {
+	Limit  int
+	Period time.Duration
+}
+
+func (r Rule) Unlimited() bool {
+	return r.Limit <= 0 || r.Period <= 0
+}
+
+func (r Rule) String() string {
+	if r.Unlimited() {
+		return "unlimited"
+	}
+
+	return fmt.Sprintf("%d/%s", r.Limit, r.Period)
+}
+
+// rate is the number of tokens added per second.
+func (r Rule) rate() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// ParseRule parses "limit/period" such as "100/1m". An empty string or "0"
+// is unlimited.
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Rule{}, nil
+	}
+
+	limit, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("rate limit %q: expected limit/period", s)
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: invalid limit", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Rule{}, fmt.Errorf("rate limit %q: invalid period", s)
+	}
+
+	return Rule{Limit: n, Period: d}, nil
+}
+
+// Policy picks the rule for a route. Routes are ServeMux patterns such as
+// "POST /users"; any other route gets Default.
+type Policy struct {
+	Default Rule
+	Routes  map[string]Rule
+}
+
+// ParsePolicy builds a policy from a default rule and a comma-separated
+// list of pattern=rule overrides, for example
+// "POST /users=5/1h,GET /books=300/1m".
+func ParsePolicy(defaultRule, routes string) (Policy, error) {
+	def, err := ParseRule(defaultRule)
+	if err != nil {
+		return Policy{}, err
+	}
+
+	policy := Policy{Default: def, Routes: map[string]Rule{}}
+
+	for _, entry := range strings.Split(routes, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		pattern, rule, ok := strings.Cut(entry, "=")
+		if !ok {
+			return Policy{}, fmt.Errorf("route rate limit %q: expected pattern=limit/period", entry)
+		}
+
+		r, err := ParseRule(rule)
+		if err != nil {
+			return Policy{}, err
+		}
+
+		policy.Routes[strings.TrimSpace(pattern)] = r
+	}
+
+	return policy, nil
+}
+
+// Rule returns the rule for pattern.
+func (p Policy) Rule(pattern string) Rule {
+	if r, ok := p.Routes[pattern]; ok {
+		return r
+	}
+
+	return p.Default
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until a token is available when not Allowed.
+	RetryAfter time.Duration
+}
+
+// Store takes a token from the bucket named key, creating it full if it
+// does not exist.
+type Store interface {
+	Take(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// bucket is the state every store keeps per key.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b for the time elapsed until now and takes a token if one is
+// available.
+func (b *bucket) take(rule Rule, now time.Time) Result {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(rule.Limit), b.tokens+elapsed*rule.rate())
+		b.updated = now
+	}
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / rule.rate()
+		return Result{RetryAfter: time.Duration(math.Ceil(wait * float64(time.Second)))}
+	}
+
+	b.tokens--
+
+	return Result{Allowed: true, Remaining: int(b.tokens)}
+}
+
+// fullAt is when b will have refilled completely, after which it can be
+// forgotten.
+func (b *bucket) fullAt(rule Rule) time.Time {
+	missing := float64(rule.Limit) - b.tokens
+	return b.updated.Add(time.Duration(missing / rule.rate() * float64(time.Second)))
+}
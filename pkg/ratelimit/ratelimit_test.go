@@ -0,0 +1,219 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/principal"
+)
+
+func TestParsePolicy(t *testing.T) {
+	t.Run("should parse the default and route rules", func(t *testing.T) {
+		policy, err := ParsePolicy("100/1m", "POST /users=5/1h, GET /books=0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := policy.Rule("GET /loans"); got != (Rule{Limit: 100, Period: time.Minute}) {
+			t.Errorf("expected the default rule, got %v", got)
+		}
+
+		if got := policy.Rule("POST /users"); got != (Rule{Limit: 5, Period: time.Hour}) {
+			t.Errorf("expected 5/1h, got %v", got)
+		}
+
+		if !policy.Rule("GET /books").Unlimited() {
+			t.Errorf("expected GET /books to be unlimited")
+		}
+	})
+
+	t.Run("should reject malformed rules", func(t *testing.T) {
+		for _, tc := range [][2]string{{"100", ""}, {"x/1m", ""}, {"10/soon", ""}, {"", "POST /users"}} {
+			if _, err := ParsePolicy(tc[0], tc[1]); err == nil {
+				t.Errorf("expected an error for %q %q", tc[0], tc[1])
+			}
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	rule := Rule{Limit: 2, Period: time.Minute}
+
+	take := func() Result {
+		result, err := store.Take(context.Background(), "key", rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	t.Run("should allow a burst up to the limit", func(t *testing.T) {
+		if r := take(); !r.Allowed || r.Remaining != 1 {
+			t.Errorf("expected allowed with 1 remaining, got %+v", r)
+		}
+
+		if r := take(); !r.Allowed || r.Remaining != 0 {
+			t.Errorf("expected allowed with 0 remaining, got %+v", r)
+		}
+	})
+
+	t.Run("should reject with the time until the next token", func(t *testing.T) {
+		r := take()
+		if r.Allowed {
+			t.Fatal("expected the request to be rejected")
+		}
+
+		if r.RetryAfter != 30*time.Second {
+			t.Errorf("expected to retry after 30s, got %s", r.RetryAfter)
+		}
+	})
+
+	t.Run("should refill over time", func(t *testing.T) {
+		now = now.Add(30 * time.Second)
+
+		if r := take(); !r.Allowed {
+			t.Errorf("expected a token after 30s, got %+v", r)
+		}
+	})
+
+	t.Run("should forget buckets once they have refilled", func(t *testing.T) {
+		now = now.Add(2 * time.Minute)
+		store.Take(context.Background(), "other", rule)
+
+		if _, ok := store.buckets["key"]; ok {
+			t.Error("expected the refilled bucket to be swept")
+		}
+	})
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, rule Rule) (Result, error) {
+	return Result{}, errors.New("connection refused")
+}
+
+func TestMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("GET /books", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	policy := Policy{
+		Default: Rule{Limit: 100, Period: time.Minute},
+		Routes: map[string]Rule{
+			"POST /users":  {Limit: 1, Period: time.Hour},
+			"GET /healthz": {},
+		},
+	}
+
+	do := func(h http.Handler, method, path, ip string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		for k, v := range header {
+			req.Header.Set(k, v[0])
+		}
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	t.Run("should return 429 with Retry-After once the route limit is reached", func(t *testing.T) {
+		h := New(NewMemoryStore(), policy, false).Middleware(mux, mux)
+
+		if rr := do(h, http.MethodPost, "/users", "10.0.0.1", nil); rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		rr := do(h, http.MethodPost, "/users", "10.0.0.1", nil)
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected status code %d, got %d", http.StatusTooManyRequests, rr.Code)
+		}
+
+		if rr.Header().Get("Retry-After") != "3600" {
+			t.Errorf("expected Retry-After 3600, got %q", rr.Header().Get("Retry-After"))
+		}
+
+		if rr.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("expected a problem response, got %q", rr.Header().Get("Content-Type"))
+		}
+
+		if rr := do(h, http.MethodGet, "/books", "10.0.0.1", nil); rr.Code != http.StatusOK {
+			t.Errorf("expected other routes to have their own bucket, got %d", rr.Code)
+		}
+
+		if rr := do(h, http.MethodPost, "/users", "10.0.0.2", nil); rr.Code != http.StatusCreated {
+			t.Errorf("expected other clients to have their own bucket, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should not limit unlimited routes", func(t *testing.T) {
+		h := New(NewMemoryStore(), Policy{Default: Rule{Limit: 1, Period: time.Hour}, Routes: policy.Routes}, false).Middleware(mux, mux)
+
+		for range 3 {
+			if rr := do(h, http.MethodGet, "/healthz", "10.0.0.1", nil); rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "" {
+				t.Fatalf("expected an unlimited 200, got %d with limit %q", rr.Code, rr.Header().Get("RateLimit-Limit"))
+			}
+		}
+	})
+
+	t.Run("should identify clients by API key and user only behind a trusted proxy", func(t *testing.T) {
+		keyA := http.Header{principal.APIKeyHeader: {"a"}}
+		keyB := http.Header{principal.APIKeyHeader: {"b"}}
+
+		trusted := New(NewMemoryStore(), policy, true).Middleware(mux, mux)
+		do(trusted, http.MethodPost, "/users", "10.0.0.1", keyA)
+
+		if rr := do(trusted, http.MethodPost, "/users", "10.0.0.1", keyB); rr.Code != http.StatusCreated {
+			t.Errorf("expected another API key to have its own bucket, got %d", rr.Code)
+		}
+
+		if rr := do(trusted, http.MethodPost, "/users", "10.0.0.1", http.Header{principal.UserIDHeader: {"u1"}}); rr.Code != http.StatusCreated {
+			t.Errorf("expected a user to have their own bucket, got %d", rr.Code)
+		}
+
+		untrusted := New(NewMemoryStore(), policy, false).Middleware(mux, mux)
+		do(untrusted, http.MethodPost, "/users", "10.0.0.1", keyA)
+
+		if rr := do(untrusted, http.MethodPost, "/users", "10.0.0.1", keyB); rr.Code != http.StatusTooManyRequests {
+			t.Errorf("expected the API key to be ignored, got %d", rr.Code)
+		}
+	})
+
+	t.Run("should limit requests before they reach next", func(t *testing.T) {
+		served := 0
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served++
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		h := New(NewMemoryStore(), policy, false).Middleware(mux, next)
+
+		for range 3 {
+			do(h, http.MethodPost, "/users", "10.0.0.1", nil)
+		}
+
+		if served != 1 {
+			t.Errorf("expected next to serve 1 request, got %d", served)
+		}
+	})
+
+	t.Run("should let requests through when the store fails", func(t *testing.T) {
+		h := New(failingStore{}, policy, false).Middleware(mux, mux)
+
+		if rr := do(h, http.MethodPost, "/users", "10.0.0.1", nil); rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+	})
+}
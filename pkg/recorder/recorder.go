@@ -0,0 +1,34 @@
+// Package recorder wraps an http.ResponseWriter to remember the status code
+// written, for middleware that logs, measures or traces responses.
+package recorder
+
+import "net/http"
+
+// Recorder passes writes through to the wrapped writer and keeps the last
+// status set with WriteHeader, or 200 if none was.
+type Recorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+func New(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	r.Status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush flushes the underlying writer if it supports it, so streamed
+// responses such as exports are not held back.
+func (r *Recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,40 @@
+package recorder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Run("should default to 200", func(t *testing.T) {
+		rec := New(httptest.NewRecorder())
+		rec.Write([]byte("ok"))
+
+		if rec.Status != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, rec.Status)
+		}
+	})
+
+	t.Run("should record and pass on the status", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		rec := New(w)
+		rec.WriteHeader(http.StatusTeapot)
+
+		if rec.Status != http.StatusTeapot || w.Code != http.StatusTeapot {
+			t.Errorf("expected status %d, got %d recorded and %d written", http.StatusTeapot, rec.Status, w.Code)
+		}
+	})
+
+	t.Run("should flush through http.ResponseController", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		if err := http.NewResponseController(New(w)).Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !w.Flushed {
+			t.Error("expected the underlying writer to be flushed")
+		}
+	})
+}
@@ -0,0 +1,219 @@
+// Package smtptest runs an SMTP server in process that keeps every message it
+// receives, so tests can assert on the emails sent without a mail server.
+package smtptest
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is an email as received by the server.
+type Message struct {
+	From string
+	To   []string
+	// Data is the message as sent, headers and body.
+	Data []byte
+}
+
+// Subject returns the message's Subject header.
+func (m Message) Subject() string {
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Data))
+	if err != nil {
+		return ""
+	}
+
+	return msg.Header.Get("Subject")
+}
+
+// Body returns the message without its headers.
+func (m Message) Body() string {
+	_, body, _ := strings.Cut(string(m.Data), "\r\n\r\n")
+	return body
+}
+
+// Server accepts any message on a local port. It supports no extensions and
+// no authentication.
+type Server struct {
+	// Addr is the host:port the server listens on.
+	Addr string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	conns    map[net.Conn]bool
+	messages []Message
+	received chan struct{} // closed and replaced on each message
+}
+
+// NewServer starts a server on a random local port. It panics if it cannot
+// listen, like httptest.NewServer.
+func NewServer() *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("smtptest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{Addr: l.Addr().String(), listener: l, conns: map[net.Conn]bool{}, received: make(chan struct{})}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Close stops the server, closing open sessions.
+func (s *Server) Close() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Messages returns the messages received so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
+
+// Wait returns the messages received once there are at least n, or what was
+// received when ctx is done along with its error.
+func (s *Server) Wait(ctx context.Context, n int) ([]Message, error) {
+	for {
+		s.mu.Lock()
+		messages, received := append([]Message(nil), s.messages...), s.received
+		s.mu.Unlock()
+
+		if len(messages) >= n {
+			return messages, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return messages, ctx.Err()
+		case <-received:
+		}
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+
+			s.session(textproto.NewConn(conn))
+		}()
+	}
+}
+
+// session speaks just enough SMTP for net/smtp.SendMail.
+func (s *Server) session(conn *textproto.Conn) {
+	var msg Message
+
+	reply := func(code int, text string) bool {
+		return conn.PrintfLine("%d %s", code, text) == nil
+	}
+
+	if !reply(220, "smtptest ready") {
+		return
+	}
+
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		var ok bool
+
+		switch strings.ToUpper(verb) {
+		case "HELO", "EHLO":
+			ok = reply(250, "smtptest")
+		case "MAIL":
+			msg = Message{From: address(arg)}
+			ok = reply(250, "OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			ok = reply(250, "OK")
+		case "DATA":
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+
+			msg.Data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+			s.add(msg)
+			msg = Message{}
+
+			ok = reply(250, "OK")
+		case "RSET":
+			msg = Message{}
+			ok = reply(250, "OK")
+		case "NOOP":
+			ok = reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			ok = reply(502, "command not implemented")
+		}
+
+		if !ok {
+			return
+		}
+	}
+}
+
+func (s *Server) add(msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, msg)
+	close(s.received)
+	s.received = make(chan struct{})
+}
+
+// address returns the address in a MAIL FROM:<...> or RCPT TO:<...>
+// argument.
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+
+	return strings.Trim(addr, "<>")
+}
@@ -0,0 +1,57 @@
+package smtptest
+
+import (
+	"context"
+	"net/smtp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("should capture messages sent with net/smtp", func(t *testing.T) {
+		s := NewServer()
+		defer s.Close()
+
+		to := []string{"ana@example.com", "bo@example.com"}
+		body := "Subject: Loan Expired\r\n\r\nPlease return the book.\r\n.Leading dot\r\n"
+
+		if err := smtp.SendMail(s.Addr, nil, "library@example.com", to, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		messages, err := s.Wait(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m := messages[0]
+
+		if m.From != "library@example.com" || !slices.Equal(m.To, to) {
+			t.Errorf("unexpected envelope from %q to %v", m.From, m.To)
+		}
+
+		if m.Subject() != "Loan Expired" {
+			t.Errorf("expected subject Loan Expired, got %q", m.Subject())
+		}
+
+		if want := "Please return the book.\r\n.Leading dot\r\n"; m.Body() != want {
+			t.Errorf("expected body %q, got %q", want, m.Body())
+		}
+	})
+
+	t.Run("should stop waiting when the context is done", func(t *testing.T) {
+		s := NewServer()
+		defer s.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if _, err := s.Wait(ctx, 1); err != context.DeadlineExceeded {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
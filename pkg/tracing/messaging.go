@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartPublish starts a producer span for a message sent through the
+// messaging system to exchange with routing key key, and writes its trace
+// context into headers, which must not be nil.
+func StartPublish(ctx context.Context, system, exchange, key string, headers map[string]string) (context.Context, trace.Span) {
+	ctx, span := Tracer().Start(ctx, "publish "+exchange,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(system),
+			semconv.MessagingDestinationName(exchange),
+			semconv.MessagingRabbitmqDestinationRoutingKey(key),
+			semconv.MessagingOperationTypePublish,
+		),
+	)
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+
+	return ctx, span
+}
+
+// StartConsume starts a consumer span for the message with messageId,
+// continuing the trace carried in its headers.
+func StartConsume(ctx context.Context, system, queue string, messageId string, headers map[string]string) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+
+	return Tracer().Start(ctx, "process "+queue,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(system),
+			semconv.MessagingDestinationName(queue),
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingMessageID(messageId),
+		),
+	)
+}
@@ -0,0 +1,80 @@
+// Package tracing configures OpenTelemetry tracing and propagates trace
+// context across HTTP requests and RabbitMQ messages.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// instrumentationName identifies the spans started by this module.
+const instrumentationName = "github.com/gfteix/book_loan_system"
+
+type Config struct {
+	// Exporter is one of none, stdout or otlp. The otlp exporter sends spans
+	// over HTTP and is configured by the OTEL_EXPORTER_OTLP_* variables.
+	Exporter string
+}
+
+// Setup installs the global tracer provider and W3C propagators for service
+// and returns a function that flushes pending spans. With the none exporter
+// spans are not recorded but trace context still propagates.
+func Setup(ctx context.Context, service string, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", config.Exporter)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(service, sdktrace.NewBatchSpanProcessor(exporter))
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// NewProvider returns a tracer provider for service that hands finished spans
+// to processor. Tests pass a tracetest.SpanRecorder to assert spans.
+func NewProvider(service string, processor sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	)
+}
+
+// Tracer returns the module's tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
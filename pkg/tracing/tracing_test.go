@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	if _, err := Setup(context.Background(), "test", Config{Exporter: ExporterNone}); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(NewProvider("test", recorder))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	router := http.NewServeMux()
+	router.HandleFunc("POST /loans", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Tracer().Start(r.Context(), "loans.CreateLoan")
+		span.End()
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/loans", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	Middleware(router).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	child, server := spans[0], spans[1]
+
+	t.Run("should name the server span after the route pattern", func(t *testing.T) {
+		if server.Name() != "POST /loans" {
+			t.Errorf("expected span POST /loans, got %v", server.Name())
+		}
+
+		if server.SpanKind() != trace.SpanKindServer {
+			t.Errorf("expected a server span, got %v", server.SpanKind())
+		}
+	})
+
+	t.Run("should continue the caller's trace", func(t *testing.T) {
+		if got := server.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected incoming trace id, got %v", got)
+		}
+
+		if child.Parent().SpanID() != server.SpanContext().SpanID() {
+			t.Errorf("expected handler span to be a child of the server span")
+		}
+	})
+
+	t.Run("should record the status code", func(t *testing.T) {
+		for _, attr := range server.Attributes() {
+			if attr.Key == semconv.HTTPResponseStatusCodeKey && attr.Value.AsInt64() == http.StatusCreated {
+				return
+			}
+		}
+
+		t.Errorf("expected status code attribute, got %v", server.Attributes())
+	})
+}
+
+func TestAMQPPropagation(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	headers := map[string]string{}
+	_, publish := StartPublish(context.Background(), "rabbitmq", "library.events", "loan.expired", headers)
+	publish.End()
+
+	if _, ok := headers["traceparent"]; !ok {
+		t.Fatalf("expected traceparent header, got %v", headers)
+	}
+
+	_, consume := StartConsume(context.Background(), "rabbitmq", "emails", "message-id", headers)
+	consume.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	producer, consumer := spans[0], spans[1]
+
+	if consumer.SpanContext().TraceID() != producer.SpanContext().TraceID() {
+		t.Errorf("expected the consumer to join the producer's trace")
+	}
+
+	if consumer.Parent().SpanID() != producer.SpanContext().SpanID() {
+		t.Errorf("expected the consumer span to be a child of the producer span")
+	}
+}
@@ -2,28 +2,136 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strings"
 
-	"github.com/go-playground/validator"
+	"github.com/gfteix/book_loan_system/pkg/apiversion"
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/types"
 )
 
-var Validate = validator.New()
+var Validate = newValidator()
 
+// MaxBodyBytes bounds the JSON bodies ParseJson accepts.
+const MaxBodyBytes = 1 << 20
+
+// ParseJson decodes a single JSON object from the request body into payload.
+// Unknown fields, trailing data and bodies over MaxBodyBytes are rejected.
+// Errors are errs validation errors, or KindTooLarge for oversized bodies.
 func ParseJson(r *http.Request, payload any) error {
 	if r.Body == nil {
-		return fmt.Errorf("missing request body")
+		return errs.Validation("missing request body", nil)
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(payload); err != nil {
+		return decodeError(err)
+	}
+
+	if decoder.More() {
+		return errs.Validation("request body must contain a single JSON object", nil)
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &errs.Error{Kind: errs.KindTooLarge, Message: fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit)}
+	case errors.As(err, &typeErr):
+		return errs.Field(typeErr.Field, fmt.Sprintf("must be a %s", typeErr.Type))
+	case errors.As(err, &syntaxErr):
+		return errs.Validation(fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset), nil)
+	case errors.Is(err, io.EOF):
+		return errs.Validation("request body must not be empty", nil)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errs.Validation("malformed JSON", nil)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return errs.Field(field, "unknown field")
 	}
 
-	return json.NewDecoder(r.Body).Decode(payload)
+	return errs.Validation(err.Error(), nil)
 }
 
 func WriteJSON(w http.ResponseWriter, status int, v any) error {
-	w.Header().Add("Contect-Type", "application/json")
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	return json.NewEncoder(w).Encode(v)
 }
 
+// WriteCreated writes v with 201 Created and a Location header pointing at
+// path under the current API version, such as /v1/loans/{id}.
+func WriteCreated(w http.ResponseWriter, path string, v any) error {
+	w.Header().Set("Location", apiversion.Prefix+path)
+	return WriteJSON(w, http.StatusCreated, v)
+}
+
+// WriteError writes err as an application/problem+json response with status.
+// Field details of a *errs.Error are listed under errors. The detail of a 500
+// is replaced with a generic message so internal errors are not leaked.
 func WriteError(w http.ResponseWriter, status int, err error) error {
-	return WriteJSON(w, status, map[string]string{"error": err.Error()})
+	problem := types.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+	}
+
+	if status == http.StatusInternalServerError {
+		problem.Detail = "internal server error"
+	}
+
+	var e *errs.Error
+	if errors.As(err, &e) {
+		if e.Kind != errs.KindInternal {
+			problem.Detail = e.Message
+		}
+		problem.Errors = fieldErrors(e.Fields)
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(problem)
+}
+
+// WriteProblem writes err with the status errs.HTTPStatus maps it to.
+func WriteProblem(w http.ResponseWriter, err error) error {
+	return WriteError(w, errs.HTTPStatus(err), err)
+}
+
+// WriteFieldErrors writes an error response that names the offending payload
+// fields, keyed by their JSON name.
+func WriteFieldErrors(w http.ResponseWriter, status int, err error, fields map[string]string) error {
+	return WriteError(w, status, errs.Validation(err.Error(), fields))
+}
+
+func fieldErrors(fields map[string]string) []types.FieldError {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]types.FieldError, 0, len(fields))
+	for _, name := range names {
+		list = append(list, types.FieldError{Field: name, Message: fields[name]})
+	}
+
+	return list
 }
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/types"
+)
+
+func TestParseJson(t *testing.T) {
+	parse := func(body string) error {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		var payload types.CreateUserPayload
+		return ParseJson(req, &payload)
+	}
+
+	t.Run("should accept a known payload", func(t *testing.T) {
+		if err := parse(`{"name": "Ana", "email": "ana@example.com"}`); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("should reject unknown fields", func(t *testing.T) {
+		err := parse(`{"name": "Ana", "admin": true}`)
+
+		var e *errs.Error
+		if !errors.As(err, &e) || e.Kind != errs.KindValidation || e.Fields["admin"] == "" {
+			t.Errorf("expected a field error for admin, got %v", err)
+		}
+	})
+
+	t.Run("should reject trailing data", func(t *testing.T) {
+		if errs.KindOf(parse(`{"name": "Ana"} {"name": "Bo"}`)) != errs.KindValidation {
+			t.Errorf("expected a validation error")
+		}
+	})
+
+	t.Run("should reject oversized bodies", func(t *testing.T) {
+		body := `{"name": "` + strings.Repeat("a", MaxBodyBytes) + `"}`
+
+		if got := errs.HTTPStatus(parse(body)); got != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, got)
+		}
+	})
+
+	t.Run("should name fields with the wrong type", func(t *testing.T) {
+		err := parse(`{"name": 1}`)
+
+		var e *errs.Error
+		if !errors.As(err, &e) || e.Fields["name"] == "" {
+			t.Errorf("expected a field error for name, got %v", err)
+		}
+	})
+}
+
+func TestValidateStruct(t *testing.T) {
+	t.Run("should check isbn checksums", func(t *testing.T) {
+		payload := types.CreateBookPayload{Title: "Crime and Punishment", ISBN: "9780143058145"}
+
+		var e *errs.Error
+		if err := ValidateStruct(payload); !errors.As(err, &e) || e.Fields["isbn"] == "" {
+			t.Errorf("expected an isbn field error, got %v", err)
+		}
+	})
+
+	t.Run("should report nested fields by json path", func(t *testing.T) {
+		payload := types.CreateBookPayload{
+			Title:         "Good Omens",
+			ISBN:          "9780060853983",
+			Authors:       []string{"Terry Pratchett", strings.Repeat("a", 201)},
+			NumberOfPages: -1,
+		}
+
+		var e *errs.Error
+		if err := ValidateStruct(payload); !errors.As(err, &e) {
+			t.Fatalf("expected a validation error, got %v", err)
+		}
+
+		if e.Fields["authors[1]"] == "" || e.Fields["numberOfPages"] != "must be 0 or greater" {
+			t.Errorf("unexpected fields %v", e.Fields)
+		}
+	})
+
+	t.Run("should order loan dates", func(t *testing.T) {
+		now := time.Now()
+		payload := types.CreateLoanPayload{
+			UserId:       "123e4567-e89b-12d3-a456-426614174000",
+			BookCopyId:   "123e4567-e89b-12d3-a456-426614174001",
+			Status:       "active",
+			LoanDate:     now,
+			ExpiringDate: now,
+		}
+
+		var e *errs.Error
+		if err := ValidateStruct(payload); !errors.As(err, &e) || e.Fields["expiringDate"] != "must be after loanDate" {
+			t.Errorf("expected a date ordering error, got %v", err)
+		}
+
+		payload.ExpiringDate = now.Add(time.Hour)
+		if err := ValidateStruct(payload); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestWriteError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	WriteProblem(rr, errs.Validation("invalid payload", map[string]string{"title": "is required", "isbn": "is required"}))
+
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("unexpected response %d %v", rr.Code, rr.Header())
+	}
+
+	var problem types.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(problem.Errors) != 2 || problem.Errors[0].Field != "isbn" || problem.Errors[1].Field != "title" {
+		t.Errorf("expected field errors sorted by name, got %v", problem.Errors)
+	}
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gfteix/book_loan_system/pkg/errs"
+	"github.com/gfteix/book_loan_system/pkg/isbn"
+	"github.com/go-playground/validator"
+	"github.com/google/uuid"
+)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their JSON name so errors match the payload.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// isbn accepts any ISBN-10 or ISBN-13 with a valid checksum, hyphens and
+	// spaces allowed, the same rule isbn.Normalize applies.
+	v.RegisterValidation("isbn", func(fl validator.FieldLevel) bool {
+		_, err := isbn.Normalize(fl.Field().String())
+		return err == nil
+	})
+
+	// uuid matches what the handlers accept in path parameters.
+	v.RegisterValidation("uuid", func(fl validator.FieldLevel) bool {
+		return uuid.Validate(fl.Field().String()) == nil
+	})
+
+	// after=Field requires a time to be strictly later than the named
+	// sibling time field.
+	v.RegisterValidation("after", func(fl validator.FieldLevel) bool {
+		t, ok := fl.Field().Interface().(time.Time)
+		if !ok {
+			return false
+		}
+
+		other := fl.Parent().FieldByName(fl.Param())
+		if !other.IsValid() {
+			return false
+		}
+
+		before, ok := other.Interface().(time.Time)
+		return ok && t.After(before)
+	})
+
+	return v
+}
+
+// ValidateStruct checks payload against its validate tags and returns an
+// errs validation error listing every offending field, or nil.
+func ValidateStruct(payload any) error {
+	err := Validate.Struct(payload)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	fields := make(map[string]string, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields[fieldPath(fe)] = fieldMessage(fe)
+	}
+
+	return errs.Validation("invalid payload", fields)
+}
+
+// fieldPath drops the struct name from the namespace, so Loan.userId
+// becomes userId and Book.authors[1] becomes authors[1].
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+
+	return fe.Field()
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "isbn":
+		return "must be a valid ISBN-10 or ISBN-13"
+	case "uuid":
+		return "must be a valid UUID"
+	case "url":
+		return "must be a valid URL"
+	case "after":
+		return fmt.Sprintf("must be after %s", jsonName(fe.Param()))
+	case "gte", "min":
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must have at least %s characters", fe.Param())
+		case reflect.Slice:
+			return fmt.Sprintf("must have at least %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be %s or greater", fe.Param())
+	case "lte", "max":
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must have at most %s characters", fe.Param())
+		case reflect.Slice:
+			return fmt.Sprintf("must have at most %s items", fe.Param())
+		}
+		return fmt.Sprintf("must be %s or less", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	}
+
+	return fmt.Sprintf("failed the %s check", fe.Tag())
+}
+
+// jsonName lower-cases the first letter of a Go field name, which matches
+// the JSON names used by the payloads.
+func jsonName(field string) string {
+	if field == "" {
+		return field
+	}
+
+	return strings.ToLower(field[:1]) + field[1:]
+}
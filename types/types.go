@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"net/http"
 	"time"
 )
 
@@ -13,15 +14,109 @@ type User struct {
 }
 
 type Book struct {
-	Id            string    `json:"id"`
-	Title         string    `json:"title"`
-	Description   string    `json:"description"`
-	ISBN          string    `json:"isbn"`
-	Author        string    `json:"author"`
-	NumberOfPages int       `json:"numberOfPages"`
-	CreatedAt     time.Time `json:"createdAt"`
+	Id            string            `json:"id"`
+	Title         string            `json:"title"`
+	Description   string            `json:"description"`
+	ISBN          string            `json:"isbn"`
+	Author        string            `json:"author"`
+	NumberOfPages int               `json:"numberOfPages"`
+	CoverURL      string            `json:"coverUrl,omitempty"`
+	WorkId        string            `json:"workId,omitempty"`
+	Edition       string            `json:"edition,omitempty"`
+	Authors       []Author          `json:"authors"`
+	Subjects      []Subject         `json:"subjects"`
+	Availability  *BookAvailability `json:"availability,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
 }
 
+// BookAvailability counts a book's copies by status. It is set on books read
+// from the catalog. ExpectedReturnDate is the earliest expiring date among the
+// book's open loans.
+type BookAvailability struct {
+	TotalCopies        int        `json:"totalCopies"`
+	AvailableCopies    int        `json:"availableCopies"`
+	OnLoanCopies       int        `json:"onLoanCopies"`
+	OnHoldCopies       int        `json:"onHoldCopies"`
+	ExpectedReturnDate *time.Time `json:"expectedReturnDate,omitempty"`
+}
+
+type Author struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	BookCount int    `json:"bookCount,omitempty"`
+}
+
+type Subject struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	BookCount int    `json:"bookCount,omitempty"`
+}
+
+// Work groups the editions (books) of the same title, so a patron who
+// accepts any edition can be served from whichever is on the shelf.
+type Work struct {
+	Id        string    `json:"id"`
+	Title     string    `json:"title"`
+	Editions  []Book    `json:"editions,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Series struct {
+	Id        string       `json:"id"`
+	Name      string       `json:"name"`
+	Works     []SeriesWork `json:"works"`
+	CreatedAt time.Time    `json:"createdAt"`
+}
+
+type SeriesWork struct {
+	WorkId   string `json:"workId"`
+	Title    string `json:"title"`
+	Position int    `json:"position"`
+}
+
+type WorkAvailability struct {
+	WorkId          string                `json:"workId"`
+	TotalCopies     int                   `json:"totalCopies"`
+	AvailableCopies int                   `json:"availableCopies"`
+	WaitingHolds    int                   `json:"waitingHolds"`
+	Editions        []EditionAvailability `json:"editions"`
+}
+
+type EditionAvailability struct {
+	BookId          string `json:"bookId"`
+	Title           string `json:"title"`
+	Edition         string `json:"edition,omitempty"`
+	ISBN            string `json:"isbn"`
+	TotalCopies     int    `json:"totalCopies"`
+	AvailableCopies int    `json:"availableCopies"`
+}
+
+type Hold struct {
+	Id         string    `json:"id"`
+	UserId     string    `json:"userId"`
+	BookId     string    `json:"bookId"`
+	WorkId     string    `json:"workId,omitempty"`
+	AnyEdition bool      `json:"anyEdition"`
+	Status     string    `json:"status"`
+	BookCopyId *string   `json:"bookCopyId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+const (
+	HoldStatusWaiting   = "waiting"
+	HoldStatusReady     = "ready"
+	HoldStatusCancelled = "cancelled"
+	HoldStatusFulfilled = "fulfilled"
+)
+
+// Book copy statuses are compared case-insensitively, as older rows were
+// written as "Available".
+const (
+	BookCopyStatusAvailable = "available"
+	BookCopyStatusOnHold    = "on_hold"
+	BookCopyStatusLent      = "lent"
+)
+
 type BookCopy struct {
 	Id        string    `json:"id"`
 	BookId    string    `json:"bookId"`
@@ -42,26 +137,72 @@ type Loan struct {
 	CreatedAt    time.Time  `json:"createdAt"`
 }
 
+// Router is the part of *http.ServeMux handlers register their routes on.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
 type UserRepository interface {
-	GetUsers() ([]User, error)
-	GetUserById(id string) (*User, error)
-	GetUserByEmail(id string) (*User, error)
-	CreateUser(user User) error
+	GetUsers(ctx context.Context) ([]User, error)
+	GetUserById(ctx context.Context, id string) (*User, error)
+	GetUserByEmail(ctx context.Context, id string) (*User, error)
+	CreateUser(ctx context.Context, user User) (*User, error)
+	StreamUsers(ctx context.Context, fn func(User) error) error
 }
 
 type BookRepository interface {
-	GetBookById(id string) (*Book, error)
-	GetBooks(filter map[string]string) ([]Book, error)
-	GetBookCopiesByBookId(id string) ([]BookCopy, error)
-	GetBookCopyById(id string) (*BookCopy, error)
-	CreateBook(book Book) error
-	CreateBookCopy(bookCopy BookCopy) error
+	GetBookById(ctx context.Context, id string) (*Book, error)
+	GetBooks(ctx context.Context, filter map[string]string) ([]Book, error)
+	GetBookCopiesByBookId(ctx context.Context, id string) ([]BookCopy, error)
+	GetBookCopyById(ctx context.Context, id string) (*BookCopy, error)
+	CreateBook(ctx context.Context, book Book) (*Book, error)
+	CreateBookCopy(ctx context.Context, bookCopy BookCopy) (*BookCopy, error)
+	StreamBooks(ctx context.Context, filter map[string]string, fn func(Book) error) error
+	StreamBookCopies(ctx context.Context, filter map[string]string, fn func(BookCopy) error) error
+}
+
+// BookLookup fetches bibliographic metadata for an ISBN from an external
+// catalog, returning a partially filled Book.
+type BookLookup interface {
+	LookupISBN(ctx context.Context, isbn string) (*Book, error)
+}
+
+type AuthorRepository interface {
+	GetAuthors(ctx context.Context, filter map[string]string) ([]Author, error)
+	GetAuthorById(ctx context.Context, id string) (*Author, error)
+	MergeAuthors(ctx context.Context, targetId string, sourceIds []string) (*Author, error)
+}
+
+type SubjectRepository interface {
+	GetSubjects(ctx context.Context, filter map[string]string) ([]Subject, error)
+	GetSubjectById(ctx context.Context, id string) (*Subject, error)
+}
+
+type WorkRepository interface {
+	GetWorks(ctx context.Context, filter map[string]string) ([]Work, error)
+	GetWorkById(ctx context.Context, id string) (*Work, error)
+	CreateWork(ctx context.Context, work Work) (*Work, error)
+	GetWorkAvailability(ctx context.Context, id string) (*WorkAvailability, error)
+	GetSeries(ctx context.Context) ([]Series, error)
+	GetSeriesById(ctx context.Context, id string) (*Series, error)
+	CreateSeries(ctx context.Context, series Series) (*Series, error)
+	SetSeriesWork(ctx context.Context, seriesId string, workId string, position int) error
+}
+
+type HoldRepository interface {
+	PlaceHold(ctx context.Context, hold Hold) (*Hold, error)
+	GetHold(ctx context.Context, id string) (*Hold, error)
+	GetHolds(ctx context.Context, filter map[string]string) ([]Hold, error)
+	CancelHold(ctx context.Context, id string) error
 }
 
 type LoanRepository interface {
-	CreateLoan(ctx context.Context, loan Loan) error
-	GetLoan(id string) (*Loan, error)
-	GetLoans(filters map[string]string) ([]Loan, error)
+	CreateLoan(ctx context.Context, loan Loan) (*Loan, error)
+	ReturnLoan(ctx context.Context, id string) (*Loan, error)
+	GetLoan(ctx context.Context, id string) (*Loan, error)
+	GetLoans(ctx context.Context, filters map[string]string) ([]Loan, error)
+	StreamLoans(ctx context.Context, filters map[string]string, fn func(Loan) error) error
 }
 
 type CreateUserPayload struct {
@@ -70,41 +211,87 @@ type CreateUserPayload struct {
 }
 
 type CreateBookPayload struct {
-	Title         string `json:"title"`
-	Description   string `json:"description"`
-	ISBN          string `json:"isbn"`
-	Author        string `json:"author"`
-	NumberOfPages int    `json:"numberOfPages"`
+	Title       string `json:"title" validate:"required,max=500"`
+	Description string `json:"description" validate:"max=10000"`
+	ISBN        string `json:"isbn" validate:"required,isbn"`
+	// Author is kept for single-author clients; Authors takes precedence.
+	Author        string   `json:"author" validate:"max=200"`
+	Authors       []string `json:"authors" validate:"max=50,dive,max=200"`
+	Subjects      []string `json:"subjects" validate:"max=50,dive,max=200"`
+	NumberOfPages int      `json:"numberOfPages" validate:"gte=0,lte=100000"`
+	CoverURL      string   `json:"coverUrl" validate:"omitempty,url"`
+	// WorkId files the book as an edition of an existing work; when empty a
+	// new work is created from the title.
+	WorkId  string `json:"workId" validate:"omitempty,uuid"`
+	Edition string `json:"edition" validate:"max=200"`
+}
+
+type LookupBookPayload struct {
+	ISBN string `json:"isbn"`
+}
+
+type MergeAuthorsPayload struct {
+	SourceIds []string `json:"sourceIds"`
+}
+
+type CreateWorkPayload struct {
+	Title string `json:"title"`
+}
+
+type CreateSeriesPayload struct {
+	Name string `json:"name"`
+}
+
+type SetSeriesWorkPayload struct {
+	Position int `json:"position"`
+}
+
+type PlaceHoldPayload struct {
+	UserId string `json:"userId"`
+	BookId string `json:"bookId"`
+	// AnyEdition lets the hold be filled by any edition of the book's work.
+	AnyEdition bool `json:"anyEdition"`
 }
 
 type CreateBookCopyPayload struct {
-	BookId    string `json:"bookId"`
-	Status    string `json:"status"`
-	Condition string `json:"condition"`
-	Location  string `json:"location"`
+	BookId string `json:"bookId" validate:"required,uuid"`
+	// Status defaults to available.
+	Status    string `json:"status" validate:"omitempty,oneof=available on_hold lent"`
+	Condition string `json:"condition" validate:"max=100"`
+	Location  string `json:"location" validate:"max=200"`
 }
 
 type CreateLoanPayload struct {
-	UserId       string    `json:"userId"`
-	BookCopyId   string    `json:"bookCopyId"`
-	Status       string    `json:"status"`
-	ExpiringDate time.Time `json:"expiringDate"`
-	LoanDate     time.Time `json:"loanDate"`
+	UserId       string    `json:"userId" validate:"required,uuid"`
+	BookCopyId   string    `json:"bookCopyId" validate:"required,uuid"`
+	Status       string    `json:"status" validate:"required,max=50"`
+	ExpiringDate time.Time `json:"expiringDate" validate:"required,after=LoanDate"`
+	LoanDate     time.Time `json:"loanDate" validate:"required"`
 }
 
-type EventPayload struct {
-	UserId string `json:"userId"`
-	LoanId string `json:"loanId"`
+// Problem is an RFC 7807 problem details body, served as
+// application/problem+json for every error response.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Errors lists the offending payload fields of a validation problem.
+	Errors []FieldError `json:"errors,omitempty"`
 }
 
-type Event struct {
-	Source  string       `json:"source"`
-	Time    string       `json:"time"`
-	EventId string       `json:"eventId"`
-	Type    string       `json:"type"`
-	Payload EventPayload `json:"payload"`
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
-type APIError struct {
-	Error string `json:"error"`
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+	HealthStatusDraining    = "draining"
+)
+
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
 }